@@ -0,0 +1,58 @@
+// breachbloom builds the bloom filter read by BREACHED_PASSWORDS_FILE from a Have I Been
+// Pwned SHA-1 download, either the single sorted file or a directory of range files.
+//
+//	go run ./cmd/breachbloom -in pwnedpasswords.txt -out breached.bloom -fp 0.001 -min-count 5
+package main
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"flag"
+	"log"
+	"os"
+
+	"github.com/akramboussanni/treenode/internal/breach"
+)
+
+func main() {
+	in := flag.String("in", "", "HIBP SHA-1 file sorted by hash, or directory of range files")
+	out := flag.String("out", "breached.bloom", "where to write the filter")
+	fp := flag.Float64("fp", 0.001, "false positive rate")
+	minCount := flag.Int("min-count", 1, "skip hashes seen fewer times than this")
+	flag.Parse()
+
+	if *in == "" || *fp <= 0 || *fp >= 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	// first pass sizes the filter, second pass fills it
+	var n uint64
+	if err := breach.ReadHashes(*in, *minCount, func([sha1.Size]byte) { n++ }); err != nil {
+		log.Fatalf("failed to read corpus: %v", err)
+	}
+
+	filter := breach.NewBloomFilter(n, *fp)
+	if err := breach.ReadHashes(*in, *minCount, filter.Add); err != nil {
+		log.Fatalf("failed to read corpus: %v", err)
+	}
+
+	f, err := os.Create(*out)
+	if err != nil {
+		log.Fatalf("failed to create %s: %v", *out, err)
+	}
+
+	w := bufio.NewWriter(f)
+	size, err := filter.WriteTo(w)
+	if err == nil {
+		err = w.Flush()
+	}
+	if err == nil {
+		err = f.Close()
+	}
+	if err != nil {
+		log.Fatalf("failed to write %s: %v", *out, err)
+	}
+
+	log.Printf("wrote %d hashes to %s (%d bytes)", n, *out, size)
+}
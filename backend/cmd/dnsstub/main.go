@@ -0,0 +1,192 @@
+// dnsstub is a minimal DNS server for local development. it answers TXT and CNAME
+// queries from records given on the command line, so custom domain verification can
+// be exercised offline by pointing DNS_RESOLVER at it.
+//
+//	go run ./cmd/dnsstub -addr 127.0.0.1:5353 \
+//		-txt _treenode.links.example.com=treenode-verification=... \
+//		-cname www.example.com=johndoe.treenode.app
+package main
+
+import (
+	"encoding/binary"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"net"
+	"strings"
+	"sync"
+)
+
+const (
+	typeA     = 1
+	typeCNAME = 5
+	typeTXT   = 16
+	typeAAAA  = 28
+
+	rcodeNXDomain = 3
+	ttl           = 60
+)
+
+type records struct {
+	mu    sync.Mutex
+	txt   map[string][]string
+	cname map[string]string
+}
+
+func (r *records) known(name string) bool {
+	_, hasTxt := r.txt[name]
+	_, hasCname := r.cname[name]
+	return hasTxt || hasCname
+}
+
+func recordFlag(add func(name, value string)) func(string) error {
+	return func(s string) error {
+		name, value, ok := strings.Cut(s, "=")
+		if !ok || name == "" || value == "" {
+			return errors.New("expected name=value")
+		}
+		add(canonical(name), value)
+		return nil
+	}
+}
+
+func canonical(name string) string {
+	return strings.ToLower(strings.TrimSuffix(name, "."))
+}
+
+func main() {
+	recs := &records{txt: map[string][]string{}, cname: map[string]string{}}
+
+	addr := flag.String("addr", "127.0.0.1:5353", "udp address to listen on")
+	flag.Func("txt", "TXT record as name=value, repeatable", recordFlag(func(name, value string) {
+		recs.txt[name] = append(recs.txt[name], value)
+	}))
+	flag.Func("cname", "CNAME record as name=target, repeatable", recordFlag(func(name, value string) {
+		recs.cname[name] = canonical(value)
+	}))
+	flag.Parse()
+
+	conn, err := net.ListenPacket("udp", *addr)
+	if err != nil {
+		log.Fatalf("failed to listen: %v", err)
+	}
+
+	log.Printf("dns stub serving %d TXT and %d CNAME names on %s", len(recs.txt), len(recs.cname), *addr)
+
+	buf := make([]byte, 1500)
+	for {
+		n, from, err := conn.ReadFrom(buf)
+		if err != nil {
+			log.Printf("read failed: %v", err)
+			continue
+		}
+
+		resp, err := recs.answer(buf[:n])
+		if err != nil {
+			log.Printf("bad query from %s: %v", from, err)
+			continue
+		}
+
+		conn.WriteTo(resp, from)
+	}
+}
+
+// answer builds the response to a single question query. CNAMEs are returned for
+// every query type on the name, like a real server would.
+func (r *records) answer(query []byte) ([]byte, error) {
+	if len(query) < 12 || binary.BigEndian.Uint16(query[4:6]) != 1 {
+		return nil, errors.New("expected one question")
+	}
+
+	name, end, err := readName(query, 12)
+	if err != nil {
+		return nil, err
+	}
+	if end+4 > len(query) {
+		return nil, errors.New("truncated question")
+	}
+	qtype := binary.BigEndian.Uint16(query[end : end+2])
+	question := query[12 : end+4]
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var answers [][]byte
+	if target, ok := r.cname[name]; ok {
+		answers = append(answers, resource(name, typeCNAME, encodeName(target)))
+	} else if qtype == typeTXT {
+		for _, value := range r.txt[name] {
+			answers = append(answers, resource(name, typeTXT, encodeTxt(value)))
+		}
+	}
+
+	// recursion desired is echoed, recursion available set
+	flags := uint16(0x8080) | binary.BigEndian.Uint16(query[2:4])&0x0100
+	if !r.known(name) {
+		flags |= rcodeNXDomain
+	}
+
+	resp := make([]byte, 12, 512)
+	copy(resp[0:2], query[0:2])
+	binary.BigEndian.PutUint16(resp[2:4], flags)
+	binary.BigEndian.PutUint16(resp[4:6], 1)
+	binary.BigEndian.PutUint16(resp[6:8], uint16(len(answers)))
+	resp = append(resp, question...)
+	for _, answer := range answers {
+		resp = append(resp, answer...)
+	}
+
+	log.Printf("%s type %d: %d answer(s)", name, qtype, len(answers))
+	return resp, nil
+}
+
+func readName(msg []byte, off int) (string, int, error) {
+	var labels []string
+	for {
+		if off >= len(msg) {
+			return "", 0, errors.New("truncated name")
+		}
+		length := int(msg[off])
+		off++
+		if length == 0 {
+			break
+		}
+		if length > 63 || off+length > len(msg) {
+			return "", 0, fmt.Errorf("bad label length %d", length)
+		}
+		labels = append(labels, string(msg[off:off+length]))
+		off += length
+	}
+	return canonical(strings.Join(labels, ".")), off, nil
+}
+
+func encodeName(name string) []byte {
+	var b []byte
+	for _, label := range strings.Split(name, ".") {
+		b = append(b, byte(len(label)))
+		b = append(b, label...)
+	}
+	return append(b, 0)
+}
+
+// encodeTxt splits the value into the 255 byte character strings TXT data is made of
+func encodeTxt(value string) []byte {
+	var b []byte
+	for len(value) > 255 {
+		b = append(b, 255)
+		b = append(b, value[:255]...)
+		value = value[255:]
+	}
+	b = append(b, byte(len(value)))
+	return append(b, value...)
+}
+
+func resource(name string, rtype uint16, data []byte) []byte {
+	b := encodeName(name)
+	b = binary.BigEndian.AppendUint16(b, rtype)
+	b = binary.BigEndian.AppendUint16(b, 1) // IN
+	b = binary.BigEndian.AppendUint32(b, ttl)
+	b = binary.BigEndian.AppendUint16(b, uint16(len(data)))
+	return append(b, data...)
+}
@@ -0,0 +1,134 @@
+// oidcstub is a minimal OpenID Connect provider for local development. it approves
+// every authorization request for a single configurable user and never talks to the
+// network, so the oidc login can be exercised offline.
+package main
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"sync"
+	"time"
+)
+
+type pendingCode struct {
+	clientID    string
+	redirectURI string
+	nonce       string
+	challenge   string
+}
+
+var (
+	mu    sync.Mutex
+	codes = map[string]pendingCode{}
+)
+
+func env(key, fallback string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return fallback
+}
+
+func randomString() string {
+	b := make([]byte, 24)
+	rand.Read(b)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func writeJSON(w http.ResponseWriter, code int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(v)
+}
+
+func main() {
+	port := env("OIDC_STUB_PORT", "9521")
+	issuer := env("OIDC_STUB_ISSUER", "http://localhost:"+port)
+	email := env("OIDC_STUB_EMAIL", "stub@example.com")
+	subject := "stub-" + base64.RawURLEncoding.EncodeToString([]byte(email))
+
+	http.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, 200, map[string]string{
+			"issuer":                 issuer,
+			"authorization_endpoint": issuer + "/authorize",
+			"token_endpoint":         issuer + "/token",
+			"userinfo_endpoint":      issuer + "/userinfo",
+		})
+	})
+
+	http.HandleFunc("/authorize", func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		if q.Get("response_type") != "code" || q.Get("code_challenge_method") != "S256" {
+			http.Error(w, "unsupported request", http.StatusBadRequest)
+			return
+		}
+
+		code := randomString()
+		mu.Lock()
+		codes[code] = pendingCode{clientID: q.Get("client_id"), redirectURI: q.Get("redirect_uri"), nonce: q.Get("nonce"), challenge: q.Get("code_challenge")}
+		mu.Unlock()
+
+		target, err := url.Parse(q.Get("redirect_uri"))
+		if err != nil {
+			http.Error(w, "invalid redirect_uri", http.StatusBadRequest)
+			return
+		}
+		values := target.Query()
+		values.Set("code", code)
+		values.Set("state", q.Get("state"))
+		target.RawQuery = values.Encode()
+		http.Redirect(w, r, target.String(), http.StatusFound)
+	})
+
+	http.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		code := r.PostForm.Get("code")
+
+		mu.Lock()
+		pending, ok := codes[code]
+		delete(codes, code)
+		mu.Unlock()
+
+		sum := sha256.Sum256([]byte(r.PostForm.Get("code_verifier")))
+		challenge := base64.RawURLEncoding.EncodeToString(sum[:])
+		if !ok || pending.redirectURI != r.PostForm.Get("redirect_uri") || subtle.ConstantTimeCompare([]byte(challenge), []byte(pending.challenge)) != 1 {
+			writeJSON(w, 400, map[string]string{"error": "invalid_grant"})
+			return
+		}
+
+		now := time.Now().Unix()
+		header, _ := json.Marshal(map[string]string{"alg": "none", "typ": "JWT"})
+		payload, _ := json.Marshal(map[string]any{
+			"iss":            issuer,
+			"sub":            subject,
+			"aud":            pending.clientID,
+			"iat":            now,
+			"exp":            now + 300,
+			"nonce":          pending.nonce,
+			"email":          email,
+			"email_verified": true,
+		})
+		idToken := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload) + "."
+
+		writeJSON(w, 200, map[string]any{
+			"access_token": randomString(),
+			"token_type":   "Bearer",
+			"expires_in":   300,
+			"id_token":     idToken,
+		})
+	})
+
+	http.HandleFunc("/userinfo", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, 200, map[string]any{"sub": subject, "email": email, "email_verified": true})
+	})
+
+	log.Printf("oidc stub listening on :%s as %s (issuer %s)", port, email, issuer)
+	log.Fatal(http.ListenAndServe(":"+port, nil))
+}
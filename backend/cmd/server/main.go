@@ -19,6 +19,11 @@
 // @name session
 // @description JWT session cookie for authenticated endpoints. Automatically set by login endpoint. Required for endpoints marked with @Security CookieAuth.
 
+// @securityDefinitions.apikey BearerAuth
+// @in header
+// @name Authorization
+// @description Personal access token for the /nodes/api endpoints, sent as "Bearer tn_...". Created under /auth/tokens and limited to the scopes chosen at creation.
+
 // @securityDefinitions.apikey RecaptchaToken
 // @in header
 // @name X-Recaptcha-Token
@@ -36,6 +41,9 @@
 // @tag.name Password Management
 // @tag.description Password reset, change, and recovery endpoints. Public endpoints have optional reCAPTCHA, authenticated endpoints require session cookie.
 
+// @tag.name Admin
+// @tag.description Moderation endpoints for accounts with the admin role. Every change is recorded in the audit log.
+
 package main
 
 import (
@@ -46,19 +54,27 @@ import (
 	"os"
 	"os/signal"
 	"strconv"
+	"strings"
 	"syscall"
 	"time"
 
 	"github.com/akramboussanni/treenode/config"
 	"github.com/akramboussanni/treenode/internal/api/routes"
 	"github.com/akramboussanni/treenode/internal/db"
+	"github.com/akramboussanni/treenode/internal/jwt"
+	"github.com/akramboussanni/treenode/internal/ogimage"
 	"github.com/akramboussanni/treenode/internal/repo"
+	"github.com/akramboussanni/treenode/internal/scheduler"
 	"github.com/akramboussanni/treenode/internal/utils"
 )
 
 func main() {
 	config.Init()
 
+	if err := jwt.Init(); err != nil {
+		log.Fatalf("Failed to load jwt keys: %v", err)
+	}
+
 	err := utils.InitSnowflake(1)
 	if err != nil {
 		panic(err)
@@ -68,7 +84,15 @@ func main() {
 	db.RunMigrations()
 
 	repos := repo.NewRepos(db.DB)
-	r := routes.SetupRouter(repos)
+	repos.OnNodeChange(ogimage.Invalidate)
+	promoteAdmins(repos.User)
+
+	jobs := scheduler.New(repos.JobLock)
+	jobs.AddMaintenanceJobs(repos)
+	jobsCtx, stopJobs := context.WithCancel(context.Background())
+	jobs.Start(jobsCtx)
+
+	r := routes.SetupRouter(repos, jobs)
 
 	port := strconv.Itoa(config.App.AppPort)
 	server := &http.Server{
@@ -100,11 +124,13 @@ func main() {
 	go func() {
 		<-quit
 		log.Println("shutting down server...")
+		stopJobs()
 		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 		defer cancel()
 		if err := server.Shutdown(ctx); err != nil {
 			log.Fatalf("server forced to shutdown: %v", err)
 		}
+		jobs.Wait()
 		log.Println("server exited gracefully")
 	}()
 
@@ -125,3 +151,27 @@ func main() {
 		}
 	}
 }
+
+// promoteAdmins gives the admin role to the accounts listed in ADMIN_EMAILS. roles are
+// never removed here, demote by editing user_role directly.
+func promoteAdmins(ur *repo.UserRepo) {
+	var emails []string
+	for _, email := range strings.Split(config.App.AdminEmails, ",") {
+		if email = strings.TrimSpace(email); email != "" {
+			emails = append(emails, email)
+		}
+	}
+
+	if len(emails) == 0 {
+		return
+	}
+
+	promoted, err := ur.PromoteAdmins(context.Background(), emails)
+	if err != nil {
+		log.Fatalf("failed to promote admins: %v", err)
+	}
+
+	if promoted > 0 {
+		log.Printf("promoted %d account(s) to admin", promoted)
+	}
+}
@@ -3,23 +3,68 @@ package config
 import (
 	"encoding/base64"
 	"log"
+	"net/url"
+	"strings"
 
 	"github.com/akramboussanni/treenode/internal/applog"
+	"github.com/akramboussanni/treenode/internal/breach"
+	"github.com/akramboussanni/treenode/internal/dnsverify"
 	"github.com/akramboussanni/treenode/internal/mailer"
+	"github.com/akramboussanni/treenode/internal/oidc"
 	"github.com/joho/godotenv"
 )
 
 type AppConfig struct {
 	AppPort            int    `env:"APP_PORT" default:"9520"`
 	JwtSecret          string `env:"JWT_SECRET" panic:"true"`
+	JwtSigningKeyFile  string `env:"JWT_SIGNING_KEY_FILE"`             // ed25519 or P-256 private key PEM, HS256 with JWT_SECRET when unset
+	JwtVerifyKeyFiles  string `env:"JWT_VERIFY_KEY_FILES"`             // comma separated, retired keys still accepted for verification
+	JwtIssuer          string `env:"JWT_ISSUER"`                       // iss claim, "treenode" when unset
+	JwtAudience        string `env:"JWT_AUDIENCE"`                     // aud claim, "treenode" when unset
+	JwtLeeway          int64  `env:"JWT_LEEWAY" default:"30"`          // sec, tolerated clock skew on exp/nbf/iat
+	JwtAcceptLegacy    bool   `env:"JWT_ACCEPT_LEGACY" default:"true"` // accept padded tokens without iss/aud from older releases
+	JwtAcceptHS256     bool   `env:"JWT_ACCEPT_HS256" default:"false"` // keep accepting JWT_SECRET tokens after JWT_SIGNING_KEY_FILE is set
 	DbConnectionString string `env:"DB_CONNECTION_STRING" panic:"warn"`
 	TrustIpHeaders     bool   `env:"TRUST_PROXY_IP_HEADERS" default:"false"`
 
-	LockoutCount         int   `env:"LOCKOUT_COUNT" default:"5"`
-	LockoutDuration      int64 `env:"LOCKOUT_DURATION" default:"3600"`       // sec (1h)
-	FailedLoginBacktrack int64 `env:"FAILED_LOGIN_BACKTRACK" default:"1800"` // sec (30min)
-	ForgotPasswordExpiry int64 `env:"FORGOT_PASSWORD_EXPIRY" default:"3600"` // sec (1h)
-	EmailConfirmExpiry   int64 `env:"EMAIL_CONFIRM_EXPIRY" default:"86400"`  // sec (24h)
+	LockoutCount           int    `env:"LOCKOUT_COUNT" default:"5"`
+	LockoutDuration        int64  `env:"LOCKOUT_DURATION" default:"3600"`          // sec (1h), doubled for every lockout in the backoff window
+	LockoutMaxDuration     int64  `env:"LOCKOUT_MAX_DURATION" default:"86400"`     // sec (24h)
+	LockoutBackoffWindow   int64  `env:"LOCKOUT_BACKOFF_WINDOW" default:"86400"`   // sec (24h)
+	IpLockoutCount         int    `env:"IP_LOCKOUT_COUNT" default:"20"`            // failures from one address (IPv6 /64) across all accounts
+	AccountLockoutCount    int    `env:"ACCOUNT_LOCKOUT_COUNT" default:"30"`       // failures on one account across all addresses
+	AccountLockoutDuration int64  `env:"ACCOUNT_LOCKOUT_DURATION" default:"86400"` // sec (24h), unless unlocked by email
+	AccountUnlockUrl       string `env:"ACCOUNT_UNLOCK_URL"`                       // frontend page for the unlock email, no link is sent when unset
+	FailedLoginBacktrack   int64  `env:"FAILED_LOGIN_BACKTRACK" default:"1800"`    // sec (30min)
+	ForgotPasswordExpiry   int64  `env:"FORGOT_PASSWORD_EXPIRY" default:"3600"`    // sec (1h)
+	EmailConfirmExpiry     int64  `env:"EMAIL_CONFIRM_EXPIRY" default:"86400"`     // sec (24h)
+
+	PasswordPolicy        string `env:"PASSWORD_POLICY"`               // basic or strong, basic when unset
+	BreachedPasswordsFile string `env:"BREACHED_PASSWORDS_FILE"`       // HIBP SHA-1 file or range directory, or a cmd/breachbloom filter
+	PasswordHash          string `env:"PASSWORD_HASH"`                 // argon2id or bcrypt, argon2id when unset. older hashes are upgraded on login
+	Argon2Memory          int    `env:"ARGON2_MEMORY" default:"19456"` // KiB (19MiB)
+	Argon2Time            int    `env:"ARGON2_TIME" default:"2"`
+	Argon2Parallelism     int    `env:"ARGON2_PARALLELISM" default:"1"`
+	BcryptCost            int    `env:"BCRYPT_COST" default:"10"`
+
+	AccountDeletionGrace int64 `env:"ACCOUNT_DELETION_GRACE" default:"604800"` // sec (7d), 0 deletes immediately
+
+	SchedulerEnabled     bool  `env:"SCHEDULER_ENABLED" default:"true"`         // background cleanup jobs, safe to leave on for every instance. accounts are purged either way
+	SecurityLogRetention int64 `env:"SECURITY_LOG_RETENTION" default:"2592000"` // sec (30d), failed logins and ended lockouts
+
+	PlatformHost          string `env:"PLATFORM_HOST"`                           // host serving node subdomains, custom domains may CNAME to <subdomain>.<host>
+	HostRouting           bool   `env:"HOST_ROUTING" default:"false"`            // serve node pages on <subdomain>.<PLATFORM_HOST> and verified custom domains
+	ApiHosts              string `env:"API_HOSTS"`                               // comma separated hosts serving the API besides PLATFORM_HOST
+	PublicApiUrl          string `env:"PUBLIC_API_URL"`                          // address clients reach the API at, for absolute links in pages
+	DnsResolver           string `env:"DNS_RESOLVER"`                            // host:port of the DNS server for domain checks, system resolver when unset
+	DomainRecheckInterval int64  `env:"DOMAIN_RECHECK_INTERVAL" default:"86400"` // sec (24h)
+	DomainRecheckFailures int    `env:"DOMAIN_RECHECK_FAILURES" default:"3"`     // failed rechecks in a row before a domain is unverified
+	SubdomainHold         int64  `env:"SUBDOMAIN_HOLD" default:"2592000"`        // sec (30d) a renamed node's old subdomain can't be taken by another node
+
+	AdminEmails string `env:"ADMIN_EMAILS"` // comma separated, confirmed accounts promoted to admin at startup
+
+	MagicLinkEnabled bool  `env:"MAGIC_LINK_ENABLED" default:"false"`
+	MagicLinkExpiry  int64 `env:"MAGIC_LINK_EXPIRY" default:"900"` // sec (15min)
 
 	RecaptchaEnabled   bool    `env:"RECAPTCHA_V3_ENABLED" default:"false"`
 	RecaptchaSecret    string  `env:"RECAPTCHA_V3_SECRET"`
@@ -32,9 +77,35 @@ type AppConfig struct {
 	TLSCertFile string `env:"TLS_CERT_FILE"`
 	TLSKeyFile  string `env:"TLS_KEY_FILE"`
 
-	JwtExpirations map[string]int64 `env:"JWT_EXPIRATIONS" default:"{\"credential\":900,\"refresh\":129600}"` // 15min, 36h
+	JwtExpirations map[string]int64 `env:"JWT_EXPIRATIONS" default:"{\"credential\":900,\"refresh\":129600,\"mfa\":300,\"preview\":604800}"` // 15min, 36h, 5min, 7d
+
+	OidcProviders     map[string]oidc.ProviderConfig `env:"OIDC_PROVIDERS" panic:"warn"`
+	OidcFrontendURL   string                         `env:"OIDC_FRONTEND_URL"`                 // where the browser lands after an oidc login
+	OidcStateDuration int64                          `env:"OIDC_STATE_DURATION" default:"600"` // sec (10min)
+}
+
+var defaultJwtExpirations = map[string]int64{
+	"credential": 900,
+	"refresh":    129600,
+	"mfa":        300,
+	"preview":    604800,
 }
 
+const defaultJwtIdentifier = "treenode"
+
+// password hashing algorithms
+const (
+	PasswordHashArgon2id = "argon2id"
+	PasswordHashBcrypt   = "bcrypt"
+)
+
+// password policies. basic needs 8 characters with a lowercase letter, an uppercase
+// letter and a digit, strong needs 12 and a symbol on top.
+const (
+	PasswordPolicyBasic  = "basic"
+	PasswordPolicyStrong = "strong"
+)
+
 var App AppConfig
 var JwtSecretBytes []byte
 
@@ -53,7 +124,66 @@ func Init() {
 		panic("JWT_SECRET must be at least 32 bytes when decoded")
 	}
 
+	// older JWT_EXPIRATIONS values predate some token types
+	if App.JwtExpirations == nil {
+		App.JwtExpirations = map[string]int64{}
+	}
+	for k, v := range defaultJwtExpirations {
+		if _, ok := App.JwtExpirations[k]; !ok {
+			App.JwtExpirations[k] = v
+		}
+	}
+
+	if App.JwtIssuer == "" {
+		App.JwtIssuer = defaultJwtIdentifier
+	}
+	if App.JwtAudience == "" {
+		App.JwtAudience = defaultJwtIdentifier
+	}
+
+	switch App.PasswordPolicy {
+	case "":
+		App.PasswordPolicy = PasswordPolicyBasic
+	case PasswordPolicyBasic, PasswordPolicyStrong:
+	default:
+		log.Fatalf("invalid PASSWORD_POLICY %q, expected basic or strong", App.PasswordPolicy)
+	}
+
+	switch App.PasswordHash {
+	case "":
+		App.PasswordHash = PasswordHashArgon2id
+	case PasswordHashArgon2id, PasswordHashBcrypt:
+	default:
+		log.Fatalf("invalid PASSWORD_HASH %q, expected argon2id or bcrypt", App.PasswordHash)
+	}
+
+	if App.Argon2Time < 1 || App.Argon2Parallelism < 1 || App.Argon2Parallelism > 255 || App.Argon2Memory < 8*App.Argon2Parallelism {
+		log.Fatal("invalid argon2 parameters, ARGON2_MEMORY must be at least 8 KiB per thread and ARGON2_PARALLELISM within 1-255")
+	}
+	if App.BcryptCost < 4 || App.BcryptCost > 31 {
+		log.Fatal("invalid BCRYPT_COST, expected 4-31")
+	}
+
+	App.PlatformHost = strings.ToLower(strings.TrimSuffix(App.PlatformHost, "."))
+	App.PublicApiUrl = strings.TrimSuffix(App.PublicApiUrl, "/")
+	if App.PublicApiUrl != "" {
+		u, err := url.Parse(App.PublicApiUrl)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			log.Fatalf("invalid PUBLIC_API_URL %q, expected an absolute http(s) url", App.PublicApiUrl)
+		}
+	}
+	if App.HostRouting && App.PlatformHost == "" {
+		log.Fatal("HOST_ROUTING is true but PLATFORM_HOST is not set")
+	}
+
 	// services
+	oidc.Init(App.OidcProviders)
+	dnsverify.Init(App.DnsResolver)
+
+	if err := breach.Init(App.BreachedPasswordsFile); err != nil {
+		log.Fatalf("Failed to load breached passwords: %v", err)
+	}
+
 	if err := mailer.Init(DeconstructConfigObject[mailer.MailerConfig]()); err != nil {
 		log.Fatalf("Failed to initialize mailer: %v", err)
 	}
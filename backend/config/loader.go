@@ -108,6 +108,18 @@ func parseMapField(field reflect.Value, envTag string, shouldPanic string, defau
 			return
 		}
 		field.Set(reflect.ValueOf(parsed))
+	case reflect.Struct:
+		parsed := reflect.New(fieldType)
+		if err := json.Unmarshal([]byte(envValue), parsed.Interface()); err != nil {
+			switch shouldPanic {
+			case "true":
+				panic("failed to parse map config field \"" + envTag + "\": " + err.Error())
+			case "warn":
+				log.Println("failed to parse map config field \"" + envTag + "\": " + err.Error())
+			}
+			return
+		}
+		field.Set(parsed.Elem())
 	default:
 		panic("unsupported map value type: " + valueType.Kind().String())
 	}
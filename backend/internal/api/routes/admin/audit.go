@@ -0,0 +1,59 @@
+package admin
+
+import (
+	"net/http"
+
+	"github.com/akramboussanni/treenode/internal/api"
+	"github.com/akramboussanni/treenode/internal/applog"
+	"github.com/akramboussanni/treenode/internal/model"
+	"github.com/akramboussanni/treenode/internal/utils"
+)
+
+// @Summary Get audit log
+// @Description List admin actions newest first, optionally only those on one user or node
+// @Tags Admin
+// @Produce json
+// @Security CookieAuth
+// @Param target_type query string false "Only entries on this kind of target" Enums(user, node)
+// @Param target_id query string false "Only entries on this target, requires target_type"
+// @Param limit query int false "Page size, 50 by default and at most 200"
+// @Param offset query int false "Number of entries to skip"
+// @Success 200 {array} model.AuditEntry "Audit entries"
+// @Failure 400 {object} api.ErrorResponse "Invalid target filter"
+// @Failure 401 {object} api.ErrorResponse "Unauthorized - invalid or missing session cookie"
+// @Failure 403 {object} api.ErrorResponse "Not an administrator"
+// @Failure 429 {object} api.ErrorResponse "Rate limit exceeded (60 requests per minute)"
+// @Failure 500 {object} api.ErrorResponse "Internal server error"
+// @Router /admin/audit [get]
+func (ar *AdminRouter) HandleGetAuditLog(w http.ResponseWriter, r *http.Request) {
+	limit, offset := pagination(r)
+
+	targetType := r.URL.Query().Get("target_type")
+	var targetID int64
+	if targetType != "" {
+		if targetType != model.AuditTargetUser && targetType != model.AuditTargetNode {
+			api.WriteMessage(w, 400, "error", "invalid target type")
+			return
+		}
+
+		id, err := utils.ParseID(r.URL.Query().Get("target_id"))
+		if err != nil {
+			api.WriteMessage(w, 400, "error", "invalid target id")
+			return
+		}
+		targetID = id
+	}
+
+	entries, err := ar.AuditRepo.GetEntries(r.Context(), targetType, targetID, limit, offset)
+	if err != nil {
+		applog.Error("Failed to get audit log:", err)
+		api.WriteInternalError(w)
+		return
+	}
+
+	if entries == nil {
+		entries = []model.AuditEntry{}
+	}
+
+	api.WriteJSON(w, 200, entries)
+}
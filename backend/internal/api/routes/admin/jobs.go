@@ -0,0 +1,25 @@
+package admin
+
+import (
+	"net/http"
+
+	"github.com/akramboussanni/treenode/config"
+	"github.com/akramboussanni/treenode/internal/api"
+)
+
+// @Summary Get background jobs
+// @Description List the maintenance jobs with their run counters on the instance that served the request. Counters reset on restart, and turns another instance took show up as skipped.
+// @Tags Admin
+// @Produce json
+// @Security CookieAuth
+// @Success 200 {object} JobsResponse "Background jobs"
+// @Failure 401 {object} api.ErrorResponse "Unauthorized - invalid or missing session cookie"
+// @Failure 403 {object} api.ErrorResponse "Not an administrator"
+// @Failure 429 {object} api.ErrorResponse "Rate limit exceeded (60 requests per minute)"
+// @Router /admin/jobs [get]
+func (ar *AdminRouter) HandleGetJobs(w http.ResponseWriter, r *http.Request) {
+	api.WriteJSON(w, 200, JobsResponse{
+		Enabled: config.App.SchedulerEnabled,
+		Jobs:    ar.Scheduler.Stats(),
+	})
+}
@@ -0,0 +1,65 @@
+package admin
+
+import (
+	"github.com/akramboussanni/treenode/internal/model"
+	"github.com/akramboussanni/treenode/internal/scheduler"
+)
+
+// @Description Account as seen by administrators
+type AdminUser struct {
+	ID                  int64  `json:"id,string" example:"123456789"`
+	Username            string `json:"username" example:"johndoe"`
+	Email               string `json:"email" example:"john@example.com"`
+	PendingEmail        string `json:"pending_email" example:""`
+	Role                string `json:"role" example:"user"`
+	Status              string `json:"status" example:"active"`
+	StatusReason        string `json:"status_reason" example:""`
+	EmailConfirmed      bool   `json:"email_confirmed" example:"true"`
+	TotpEnabled         bool   `json:"totp_enabled" example:"false"`
+	CreatedAt           int64  `json:"created_at,string" example:"1640995200"`
+	DeletionScheduledAt int64  `json:"deletion_scheduled_at,string" example:"0"`
+}
+
+func newAdminUser(user *model.User) AdminUser {
+	return AdminUser{
+		ID:                  user.ID,
+		Username:            user.Username,
+		Email:               user.Email,
+		PendingEmail:        user.PendingEmail,
+		Role:                user.Role,
+		Status:              user.Status,
+		StatusReason:        user.StatusReason,
+		EmailConfirmed:      user.EmailConfirmed,
+		TotpEnabled:         user.TotpEnabled,
+		CreatedAt:           user.CreatedAt,
+		DeletionScheduledAt: user.DeletionScheduledAt,
+	}
+}
+
+// @Description Nodes a user owns or collaborates on
+type AdminUserNodesResponse struct {
+	Owned  []model.Node `json:"owned"`
+	Shared []model.Node `json:"shared"`
+}
+
+// @Description Clears lockouts for a user
+type UnlockRequest struct {
+	IPAddress string `json:"ip_address" example:"203.0.113.7" description:"Only unlock this address (or its IPv6 /64), every address when empty"`
+}
+
+// @Description Changes whether a user can sign in
+type StatusRequest struct {
+	Status string `json:"status" example:"disabled" binding:"required" enums:"active,disabled,banned"`
+	Reason string `json:"reason" example:"spam" maxLength:"500"`
+}
+
+// @Description Hides a node from public pages
+type TakedownRequest struct {
+	Reason string `json:"reason" example:"phishing links" binding:"required" maxLength:"500"`
+}
+
+// @Description Background jobs on this instance
+type JobsResponse struct {
+	Enabled bool                 `json:"enabled" example:"true"`
+	Jobs    []scheduler.JobStats `json:"jobs"`
+}
@@ -0,0 +1,91 @@
+package admin
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/akramboussanni/treenode/internal/api"
+	"github.com/akramboussanni/treenode/internal/applog"
+	"github.com/akramboussanni/treenode/internal/model"
+	"github.com/jmoiron/sqlx"
+)
+
+// @Summary Take down node
+// @Description Hide a node and its links from every public endpoint. The owner and collaborators keep access and can see the reason.
+// @Tags Admin
+// @Accept json
+// @Produce json
+// @Security CookieAuth
+// @Param nodeID path string true "Node ID"
+// @Param request body TakedownRequest true "Reason shown to the owner"
+// @Success 200 {object} api.SuccessResponse "Node taken down"
+// @Failure 400 {object} api.ErrorResponse "Invalid node ID, missing or too long reason"
+// @Failure 401 {object} api.ErrorResponse "Unauthorized - invalid or missing session cookie"
+// @Failure 403 {object} api.ErrorResponse "Not an administrator"
+// @Failure 404 {object} api.ErrorResponse "Node not found"
+// @Failure 429 {object} api.ErrorResponse "Rate limit exceeded (60 requests per minute)"
+// @Failure 500 {object} api.ErrorResponse "Internal server error"
+// @Router /admin/nodes/{nodeID}/takedown [post]
+func (ar *AdminRouter) HandleTakedownNode(w http.ResponseWriter, r *http.Request) {
+	req, err := api.DecodeJSON[TakedownRequest](w, r)
+	if err != nil {
+		applog.Error("Failed to decode takedown request:", err)
+		return
+	}
+
+	reason := strings.TrimSpace(req.Reason)
+	if reason == "" || len(reason) > maxReasonLength {
+		api.WriteMessage(w, 400, "error", "a reason of at most 500 characters is required")
+		return
+	}
+
+	node := ar.targetNode(w, r)
+	if node == nil {
+		return
+	}
+
+	takedown := func(tx *sqlx.Tx) error {
+		return ar.NodeRepo.SetNodeTakedownTx(r.Context(), tx, node.ID, time.Now().UTC().Unix(), reason)
+	}
+	if !ar.audit(w, r, model.AuditNodeTakedown, model.AuditTargetNode, node.ID, reason, takedown) {
+		return
+	}
+
+	api.WriteMessage(w, 200, "message", "node taken down")
+}
+
+// @Summary Restore node
+// @Description Make a taken down node public again
+// @Tags Admin
+// @Produce json
+// @Security CookieAuth
+// @Param nodeID path string true "Node ID"
+// @Success 200 {object} api.SuccessResponse "Node restored"
+// @Failure 400 {object} api.ErrorResponse "Invalid node ID or node not taken down"
+// @Failure 401 {object} api.ErrorResponse "Unauthorized - invalid or missing session cookie"
+// @Failure 403 {object} api.ErrorResponse "Not an administrator"
+// @Failure 404 {object} api.ErrorResponse "Node not found"
+// @Failure 429 {object} api.ErrorResponse "Rate limit exceeded (60 requests per minute)"
+// @Failure 500 {object} api.ErrorResponse "Internal server error"
+// @Router /admin/nodes/{nodeID}/restore [post]
+func (ar *AdminRouter) HandleRestoreNode(w http.ResponseWriter, r *http.Request) {
+	node := ar.targetNode(w, r)
+	if node == nil {
+		return
+	}
+
+	if node.TakenDownAt == 0 {
+		api.WriteMessage(w, 400, "error", "node is not taken down")
+		return
+	}
+
+	restore := func(tx *sqlx.Tx) error {
+		return ar.NodeRepo.SetNodeTakedownTx(r.Context(), tx, node.ID, 0, "")
+	}
+	if !ar.audit(w, r, model.AuditNodeRestore, model.AuditTargetNode, node.ID, node.TakedownReason, restore) {
+		return
+	}
+
+	api.WriteMessage(w, 200, "message", "node restored")
+}
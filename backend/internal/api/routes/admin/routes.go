@@ -0,0 +1,49 @@
+package admin
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/akramboussanni/treenode/internal/middleware"
+	"github.com/akramboussanni/treenode/internal/model"
+	"github.com/akramboussanni/treenode/internal/repo"
+	"github.com/akramboussanni/treenode/internal/scheduler"
+	"github.com/go-chi/chi/v5"
+)
+
+type AdminRouter struct {
+	UserRepo    *repo.UserRepo
+	TokenRepo   *repo.TokenRepo
+	LockoutRepo *repo.LockoutRepo
+	SessionRepo *repo.SessionRepo
+	NodeRepo    *repo.NodeRepo
+	AuditRepo   *repo.AuditRepo
+	Scheduler   *scheduler.Scheduler
+}
+
+func NewAdminRouter(userRepo *repo.UserRepo, tokenRepo *repo.TokenRepo, lockoutRepo *repo.LockoutRepo, sessionRepo *repo.SessionRepo, nodeRepo *repo.NodeRepo, auditRepo *repo.AuditRepo, jobs *scheduler.Scheduler) http.Handler {
+	ar := &AdminRouter{UserRepo: userRepo, TokenRepo: tokenRepo, LockoutRepo: lockoutRepo, SessionRepo: sessionRepo, NodeRepo: nodeRepo, AuditRepo: auditRepo, Scheduler: jobs}
+	r := chi.NewRouter()
+
+	r.Use(middleware.MaxBytesMiddleware(1 << 20))
+
+	//60/min+auth+admin
+	r.Group(func(r chi.Router) {
+		middleware.AddRatelimit(r, 60, 1*time.Minute)
+		middleware.AddAuth(r, ar.UserRepo, ar.TokenRepo, ar.SessionRepo)
+		middleware.AddRole(r, model.RoleAdmin)
+
+		r.Get("/users", ar.HandleSearchUsers)
+		r.Get("/users/{userID}", ar.HandleGetUser)
+		r.Get("/users/{userID}/nodes", ar.HandleGetUserNodes)
+		r.Post("/users/{userID}/confirm-email", ar.HandleConfirmEmail)
+		r.Post("/users/{userID}/unlock", ar.HandleUnlockUser)
+		r.Post("/users/{userID}/status", ar.HandleSetUserStatus)
+		r.Post("/nodes/{nodeID}/takedown", ar.HandleTakedownNode)
+		r.Post("/nodes/{nodeID}/restore", ar.HandleRestoreNode)
+		r.Get("/audit", ar.HandleGetAuditLog)
+		r.Get("/jobs", ar.HandleGetJobs)
+	})
+
+	return r
+}
@@ -0,0 +1,261 @@
+package admin
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/akramboussanni/treenode/internal/api"
+	"github.com/akramboussanni/treenode/internal/applog"
+	"github.com/akramboussanni/treenode/internal/model"
+	"github.com/akramboussanni/treenode/internal/utils"
+	"github.com/jmoiron/sqlx"
+)
+
+// @Summary Search users
+// @Description List accounts whose username or email contains the query, newest first. Without a query every account is listed.
+// @Tags Admin
+// @Produce json
+// @Security CookieAuth
+// @Param q query string false "Part of a username or email"
+// @Param limit query int false "Page size, 50 by default and at most 200"
+// @Param offset query int false "Number of accounts to skip"
+// @Success 200 {array} AdminUser "Matching accounts"
+// @Failure 401 {object} api.ErrorResponse "Unauthorized - invalid or missing session cookie"
+// @Failure 403 {object} api.ErrorResponse "Not an administrator"
+// @Failure 429 {object} api.ErrorResponse "Rate limit exceeded (60 requests per minute)"
+// @Failure 500 {object} api.ErrorResponse "Internal server error"
+// @Router /admin/users [get]
+func (ar *AdminRouter) HandleSearchUsers(w http.ResponseWriter, r *http.Request) {
+	limit, offset := pagination(r)
+	users, err := ar.UserRepo.SearchUsers(r.Context(), strings.TrimSpace(r.URL.Query().Get("q")), limit, offset)
+	if err != nil {
+		applog.Error("Failed to search users:", err)
+		api.WriteInternalError(w)
+		return
+	}
+
+	result := make([]AdminUser, 0, len(users))
+	for i := range users {
+		result = append(result, newAdminUser(&users[i]))
+	}
+
+	api.WriteJSON(w, 200, result)
+}
+
+// @Summary Get user
+// @Description Get an account with the moderation fields hidden from the user themselves
+// @Tags Admin
+// @Produce json
+// @Security CookieAuth
+// @Param userID path string true "User ID"
+// @Success 200 {object} AdminUser "The account"
+// @Failure 400 {string} string "Invalid user ID"
+// @Failure 401 {object} api.ErrorResponse "Unauthorized - invalid or missing session cookie"
+// @Failure 403 {object} api.ErrorResponse "Not an administrator"
+// @Failure 404 {object} api.ErrorResponse "User not found"
+// @Failure 429 {object} api.ErrorResponse "Rate limit exceeded (60 requests per minute)"
+// @Failure 500 {object} api.ErrorResponse "Internal server error"
+// @Router /admin/users/{userID} [get]
+func (ar *AdminRouter) HandleGetUser(w http.ResponseWriter, r *http.Request) {
+	user := ar.targetUser(w, r)
+	if user == nil {
+		return
+	}
+
+	api.WriteJSON(w, 200, newAdminUser(user))
+}
+
+// @Summary Get user's nodes
+// @Description List the nodes an account owns and the ones it collaborates on, including taken down nodes
+// @Tags Admin
+// @Produce json
+// @Security CookieAuth
+// @Param userID path string true "User ID"
+// @Success 200 {object} AdminUserNodesResponse "Owned and shared nodes"
+// @Failure 400 {string} string "Invalid user ID"
+// @Failure 401 {object} api.ErrorResponse "Unauthorized - invalid or missing session cookie"
+// @Failure 403 {object} api.ErrorResponse "Not an administrator"
+// @Failure 404 {object} api.ErrorResponse "User not found"
+// @Failure 429 {object} api.ErrorResponse "Rate limit exceeded (60 requests per minute)"
+// @Failure 500 {object} api.ErrorResponse "Internal server error"
+// @Router /admin/users/{userID}/nodes [get]
+func (ar *AdminRouter) HandleGetUserNodes(w http.ResponseWriter, r *http.Request) {
+	user := ar.targetUser(w, r)
+	if user == nil {
+		return
+	}
+
+	owned, err := ar.NodeRepo.GetNodesByOwnerID(r.Context(), user.ID)
+	if err != nil {
+		applog.Error("Failed to get owned nodes:", err)
+		api.WriteInternalError(w)
+		return
+	}
+
+	shared, err := ar.NodeRepo.GetSharedNodesByUserID(r.Context(), user.ID)
+	if err != nil {
+		applog.Error("Failed to get shared nodes:", err)
+		api.WriteInternalError(w)
+		return
+	}
+
+	if owned == nil {
+		owned = []model.Node{}
+	}
+	if shared == nil {
+		shared = []model.Node{}
+	}
+
+	api.WriteJSON(w, 200, AdminUserNodesResponse{Owned: owned, Shared: shared})
+}
+
+// @Summary Force-confirm email
+// @Description Mark an account's email address as confirmed without the confirmation link
+// @Tags Admin
+// @Produce json
+// @Security CookieAuth
+// @Param userID path string true "User ID"
+// @Success 200 {object} api.SuccessResponse "Email confirmed"
+// @Failure 400 {object} api.ErrorResponse "Invalid user ID or email already confirmed"
+// @Failure 401 {object} api.ErrorResponse "Unauthorized - invalid or missing session cookie"
+// @Failure 403 {object} api.ErrorResponse "Not an administrator"
+// @Failure 404 {object} api.ErrorResponse "User not found"
+// @Failure 429 {object} api.ErrorResponse "Rate limit exceeded (60 requests per minute)"
+// @Failure 500 {object} api.ErrorResponse "Internal server error"
+// @Router /admin/users/{userID}/confirm-email [post]
+func (ar *AdminRouter) HandleConfirmEmail(w http.ResponseWriter, r *http.Request) {
+	user := ar.targetUser(w, r)
+	if user == nil {
+		return
+	}
+
+	if user.EmailConfirmed {
+		api.WriteMessage(w, 400, "error", "email already confirmed")
+		return
+	}
+
+	confirm := func(tx *sqlx.Tx) error {
+		return ar.UserRepo.MarkUserConfirmedTx(r.Context(), tx, user.ID)
+	}
+	if !ar.audit(w, r, model.AuditUserConfirmEmail, model.AuditTargetUser, user.ID, user.Email, confirm) {
+		return
+	}
+
+	api.WriteMessage(w, 200, "message", "email confirmed")
+}
+
+// @Summary Unlock user
+// @Description Forgive an account's failed logins and lift its account-wide lockout, along with the lockout on one address (or IPv6 /64) or on all of them
+// @Tags Admin
+// @Accept json
+// @Produce json
+// @Security CookieAuth
+// @Param userID path string true "User ID"
+// @Param request body UnlockRequest true "Address to unlock, empty for all"
+// @Success 200 {object} api.SuccessResponse "Account unlocked"
+// @Failure 400 {string} string "Invalid user ID or request format"
+// @Failure 401 {object} api.ErrorResponse "Unauthorized - invalid or missing session cookie"
+// @Failure 403 {object} api.ErrorResponse "Not an administrator"
+// @Failure 404 {object} api.ErrorResponse "User not found"
+// @Failure 429 {object} api.ErrorResponse "Rate limit exceeded (60 requests per minute)"
+// @Failure 500 {object} api.ErrorResponse "Internal server error"
+// @Router /admin/users/{userID}/unlock [post]
+func (ar *AdminRouter) HandleUnlockUser(w http.ResponseWriter, r *http.Request) {
+	req, err := api.DecodeJSON[UnlockRequest](w, r)
+	if err != nil {
+		applog.Error("Failed to decode unlock request:", err)
+		return
+	}
+
+	user := ar.targetUser(w, r)
+	if user == nil {
+		return
+	}
+
+	var prefix string
+	details := "all addresses"
+	if ip := strings.TrimSpace(req.IPAddress); ip != "" {
+		prefix = utils.IPPrefix(ip)
+		details = prefix
+	}
+
+	unlock := func(tx *sqlx.Tx) error {
+		return ar.LockoutRepo.UnlockAccountTx(r.Context(), tx, user.ID, prefix)
+	}
+	if !ar.audit(w, r, model.AuditUserUnlock, model.AuditTargetUser, user.ID, details, unlock) {
+		return
+	}
+
+	api.WriteMessage(w, 200, "message", "account unlocked")
+}
+
+// @Summary Set user status
+// @Description Disable, ban or reactivate an account. Disabled and banned accounts are logged out everywhere and can't sign in or use personal access tokens. Banning also takes down every node the account owns, reactivating does not restore them.
+// @Tags Admin
+// @Accept json
+// @Produce json
+// @Security CookieAuth
+// @Param userID path string true "User ID"
+// @Param request body StatusRequest true "New status and reason"
+// @Success 200 {object} api.SuccessResponse "Status changed"
+// @Failure 400 {object} api.ErrorResponse "Invalid status, reason too long, or changing your own status"
+// @Failure 401 {object} api.ErrorResponse "Unauthorized - invalid or missing session cookie"
+// @Failure 403 {object} api.ErrorResponse "Not an administrator"
+// @Failure 404 {object} api.ErrorResponse "User not found"
+// @Failure 429 {object} api.ErrorResponse "Rate limit exceeded (60 requests per minute)"
+// @Failure 500 {object} api.ErrorResponse "Internal server error"
+// @Router /admin/users/{userID}/status [post]
+func (ar *AdminRouter) HandleSetUserStatus(w http.ResponseWriter, r *http.Request) {
+	req, err := api.DecodeJSON[StatusRequest](w, r)
+	if err != nil {
+		applog.Error("Failed to decode status request:", err)
+		return
+	}
+
+	if !model.IsValidStatus(req.Status) {
+		api.WriteMessage(w, 400, "error", "invalid status")
+		return
+	}
+
+	reason := strings.TrimSpace(req.Reason)
+	if len(reason) > maxReasonLength {
+		api.WriteMessage(w, 400, "error", "reason too long")
+		return
+	}
+
+	user := ar.targetUser(w, r)
+	if user == nil {
+		return
+	}
+
+	if actor, ok := utils.UserFromContext(r.Context()); ok && actor.ID == user.ID {
+		api.WriteMessage(w, 400, "error", "cannot change your own status")
+		return
+	}
+
+	// the status change, logging out, the ban takedown and the audit entry land together
+	setStatus := func(tx *sqlx.Tx) error {
+		if err := ar.UserRepo.SetUserStatusTx(r.Context(), tx, user.ID, req.Status, reason); err != nil {
+			return err
+		}
+
+		if req.Status != model.StatusActive {
+			if err := ar.UserRepo.ChangeJwtSessionIDTx(r.Context(), tx, user.ID, utils.GenerateSnowflakeID()); err != nil {
+				return err
+			}
+		}
+
+		if req.Status == model.StatusBanned {
+			return ar.NodeRepo.TakeDownNodesByOwnerIDTx(r.Context(), tx, user.ID, time.Now().UTC().Unix(), "owner banned")
+		}
+		return nil
+	}
+	details := fmt.Sprintf("%s -> %s: %s", user.Status, req.Status, reason)
+	if !ar.audit(w, r, model.AuditUserStatus, model.AuditTargetUser, user.ID, details, setStatus) {
+		return
+	}
+
+	api.WriteMessage(w, 200, "message", "status changed")
+}
@@ -0,0 +1,118 @@
+package admin
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/akramboussanni/treenode/internal/api"
+	"github.com/akramboussanni/treenode/internal/applog"
+	"github.com/akramboussanni/treenode/internal/model"
+	"github.com/akramboussanni/treenode/internal/utils"
+	"github.com/go-chi/chi/v5"
+	"github.com/jmoiron/sqlx"
+)
+
+const (
+	defaultPageSize = 50
+	maxPageSize     = 200
+	maxReasonLength = 500
+)
+
+// pagination reads ?limit= and ?offset=, falling back to the defaults on bad input
+func pagination(r *http.Request) (int, int) {
+	limit, err := strconv.Atoi(r.URL.Query().Get("limit"))
+	if err != nil || limit <= 0 {
+		limit = defaultPageSize
+	}
+	if limit > maxPageSize {
+		limit = maxPageSize
+	}
+
+	offset, err := strconv.Atoi(r.URL.Query().Get("offset"))
+	if err != nil || offset < 0 {
+		offset = 0
+	}
+
+	return limit, offset
+}
+
+// targetUser loads the user named in the path. on failure it writes the error response
+// and returns nil.
+func (ar *AdminRouter) targetUser(w http.ResponseWriter, r *http.Request) *model.User {
+	userID, err := utils.ParseID(chi.URLParam(r, "userID"))
+	if err != nil {
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return nil
+	}
+
+	user, err := ar.UserRepo.GetUserByID(r.Context(), userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		api.WriteMessage(w, 404, "error", "user not found")
+		return nil
+	}
+	if err != nil {
+		applog.Error("Failed to get user:", err)
+		api.WriteInternalError(w)
+		return nil
+	}
+
+	return user
+}
+
+// targetNode loads the node named in the path. on failure it writes the error response
+// and returns nil.
+func (ar *AdminRouter) targetNode(w http.ResponseWriter, r *http.Request) *model.Node {
+	nodeID, err := utils.ParseID(chi.URLParam(r, "nodeID"))
+	if err != nil {
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return nil
+	}
+
+	node, err := ar.NodeRepo.GetNodeByID(r.Context(), nodeID)
+	if errors.Is(err, sql.ErrNoRows) {
+		api.WriteMessage(w, 404, "error", "node not found")
+		return nil
+	}
+	if err != nil {
+		applog.Error("Failed to get node:", err)
+		api.WriteInternalError(w)
+		return nil
+	}
+
+	return node
+}
+
+// audit carries out an admin action and records it in one transaction, so the log shows
+// every action that happened and none that failed. on failure it writes the error
+// response and returns false.
+func (ar *AdminRouter) audit(w http.ResponseWriter, r *http.Request, action, targetType string, targetID int64, details string, run func(tx *sqlx.Tx) error) bool {
+	actor, ok := utils.UserFromContext(r.Context())
+	if !ok {
+		applog.Error("Failed to get user from context")
+		api.WriteInternalError(w)
+		return false
+	}
+
+	entry := &model.AuditEntry{
+		ID:         utils.GenerateSnowflakeID(),
+		ActorID:    actor.ID,
+		Action:     action,
+		TargetType: targetType,
+		TargetID:   targetID,
+		Details:    details,
+		IPAddress:  utils.GetClientIP(r),
+		CreatedAt:  time.Now().UTC().Unix(),
+	}
+
+	if err := ar.AuditRepo.Record(r.Context(), entry, run); err != nil {
+		applog.Error("Failed admin action", "action:", action, "target:", targetType, targetID, "err:", err)
+		api.WriteInternalError(w)
+		return false
+	}
+
+	applog.Info("Admin action", "actorID:", actor.ID, "action:", action, "target:", targetType, targetID)
+	return true
+}
@@ -1,10 +1,15 @@
 package auth
 
 import (
+	"context"
+	"fmt"
 	"net/http"
+	"time"
 
+	"github.com/akramboussanni/treenode/config"
 	"github.com/akramboussanni/treenode/internal/api"
 	"github.com/akramboussanni/treenode/internal/applog"
+	"github.com/akramboussanni/treenode/internal/model"
 	"github.com/akramboussanni/treenode/internal/utils"
 )
 
@@ -32,3 +37,309 @@ func (ar *AuthRouter) HandleProfile(w http.ResponseWriter, r *http.Request) {
 	applog.Info("Profile retrieved", "userID:", user.ID)
 	api.WriteJSON(w, 200, user)
 }
+
+// accounts without a password confirm destructive actions by having signed in recently
+const recentLoginWindow = 10 * 60 // sec
+
+// @Summary Export personal data
+// @Description Download everything stored about the current user as JSON: profile, linked identities, access tokens, login history, owned and shared nodes with their links and color stops, and invitations. Secrets such as token hashes and invitation tokens are left out.
+// @Tags Account
+// @Accept json
+// @Produce json
+// @Security CookieAuth
+// @Success 200 {object} AccountExport "Personal data archive"
+// @Failure 401 {object} api.ErrorResponse "Unauthorized - invalid or missing session cookie"
+// @Failure 429 {object} api.ErrorResponse "Rate limit exceeded (10 requests per minute)"
+// @Failure 500 {object} api.ErrorResponse "Internal server error"
+// @Router /auth/account/export [get]
+func (ar *AuthRouter) HandleExportAccount(w http.ResponseWriter, r *http.Request) {
+	applog.Info("HandleExportAccount called")
+	user, ok := utils.UserFromContext(r.Context())
+	if !ok {
+		applog.Error("Failed to get user from context")
+		api.WriteInternalError(w)
+		return
+	}
+
+	export, err := ar.buildAccountExport(r.Context(), user)
+	if err != nil {
+		applog.Error("Failed to build account export:", err)
+		api.WriteInternalError(w)
+		return
+	}
+
+	applog.Info("Account exported", "userID:", user.ID)
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"treenode-export-%d.json\"", user.ID))
+	api.WriteJSON(w, 200, export)
+}
+
+func (ar *AuthRouter) buildAccountExport(ctx context.Context, user *model.User) (*AccountExport, error) {
+	var err error
+	export := &AccountExport{ExportedAt: time.Now().UTC().Unix()}
+
+	profile := *user
+	utils.StripUnsafeFields(&profile)
+	export.Profile = &profile
+
+	if export.Identities, err = ar.IdentityRepo.GetIdentitiesByUserID(ctx, user.ID); err != nil {
+		return nil, err
+	}
+	if export.ApiTokens, err = ar.ApiTokenRepo.GetApiTokensByUserID(ctx, user.ID); err != nil {
+		return nil, err
+	}
+	if export.Sessions, err = ar.SessionRepo.GetSessionsByUserID(ctx, user.ID, 0); err != nil {
+		return nil, err
+	}
+	if export.FailedLogins, err = ar.LockoutRepo.GetFailedLoginsByUserID(ctx, user.ID); err != nil {
+		return nil, err
+	}
+
+	owned, err := ar.NodeRepo.GetNodesByOwnerID(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+	if export.OwnedNodes, err = ar.exportNodes(ctx, owned, true); err != nil {
+		return nil, err
+	}
+
+	shared, err := ar.NodeRepo.GetSharedNodesByUserID(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+	if export.SharedNodes, err = ar.exportNodes(ctx, shared, false); err != nil {
+		return nil, err
+	}
+
+	if export.Invitations, err = ar.InvitationRepo.GetInvitationsForUser(ctx, user.ID, user.Email); err != nil {
+		return nil, err
+	}
+	stripInvitationTokens(export.Invitations)
+
+	export.Identities = nonNil(export.Identities)
+	export.ApiTokens = nonNil(export.ApiTokens)
+	export.Sessions = nonNil(export.Sessions)
+	export.FailedLogins = nonNil(export.FailedLogins)
+	export.Invitations = nonNil(export.Invitations)
+
+	return export, nil
+}
+
+// nonNil keeps empty lists as [] in the export instead of null
+func nonNil[T any](s []T) []T {
+	if s == nil {
+		return []T{}
+	}
+	return s
+}
+
+func (ar *AuthRouter) exportNodes(ctx context.Context, nodes []model.Node, owned bool) ([]ExportedNode, error) {
+	result := make([]ExportedNode, 0, len(nodes))
+	for _, node := range nodes {
+		if err := ar.NodeRepo.LoadCollaborators(ctx, &node); err != nil {
+			return nil, err
+		}
+
+		links, err := ar.LinkRepo.GetLinksByNodeID(ctx, node.ID)
+		if err != nil {
+			return nil, err
+		}
+		for i := range links {
+			if err := ar.LinkRepo.LoadColorStops(ctx, &links[i]); err != nil {
+				return nil, err
+			}
+		}
+
+		exported := ExportedNode{Node: node, Links: nonNil(links)}
+		if owned {
+			if exported.Invitations, err = ar.InvitationRepo.GetInvitationsByNodeID(ctx, node.ID); err != nil {
+				return nil, err
+			}
+			stripInvitationTokens(exported.Invitations)
+		}
+		result = append(result, exported)
+	}
+	return result, nil
+}
+
+// invitation tokens still accept the invite, so they don't belong in an archive file
+func stripInvitationTokens(invitations []*model.Invitation) {
+	for _, invitation := range invitations {
+		invitation.Token = ""
+	}
+}
+
+// @Summary Delete account
+// @Description Schedule the current account for deletion after the grace period (ACCOUNT_DELETION_GRACE, 7 days by default). Requires the current password, or a login within the last 10 minutes for accounts without one. Owned nodes listed in transfers go to that collaborator, every other owned node is deleted along with its links. Collaborator access to other nodes is removed. The account keeps working until then and can be restored with /auth/account/restore. Calling this again replaces the transfers but keeps the original date.
+// @Tags Account
+// @Accept json
+// @Produce json
+// @Security CookieAuth
+// @Security RecaptchaToken
+// @Param request body DeleteAccountRequest true "Password confirmation and node transfers"
+// @Success 200 {object} DeleteAccountResponse "Deletion scheduled"
+// @Failure 400 {object} api.ErrorResponse "Invalid request or transfer"
+// @Failure 401 {object} api.ErrorResponse "Unauthorized - invalid password or missing session cookie"
+// @Failure 403 {object} api.ErrorResponse "Recent login required"
+// @Failure 429 {object} api.ErrorResponse "Rate limit exceeded (8 requests per hour)"
+// @Failure 500 {object} api.ErrorResponse "Internal server error"
+// @Router /auth/account [delete]
+func (ar *AuthRouter) HandleDeleteAccount(w http.ResponseWriter, r *http.Request) {
+	applog.Info("HandleDeleteAccount called")
+	req, err := api.DecodeJSON[DeleteAccountRequest](w, r)
+	if err != nil {
+		applog.Error("Failed to decode delete account request:", err)
+		return
+	}
+
+	user, ok := utils.UserFromContext(r.Context())
+	if !ok {
+		applog.Error("Failed to get user from context")
+		api.WriteInternalError(w)
+		return
+	}
+
+	if !ar.reconfirmIdentity(w, r, user, req.Password) {
+		return
+	}
+
+	seen := make(map[int64]bool, len(req.Transfers))
+	for i := range req.Transfers {
+		transfer := &req.Transfers[i]
+		if seen[transfer.NodeID] {
+			api.WriteMessage(w, 400, "error", "node listed more than once")
+			return
+		}
+		seen[transfer.NodeID] = true
+
+		node, err := ar.NodeRepo.GetNodeByID(r.Context(), transfer.NodeID)
+		if err != nil || node.OwnerID != user.ID {
+			api.WriteMessage(w, 400, "error", "can only transfer nodes you own")
+			return
+		}
+
+		if transfer.NewOwnerID == user.ID {
+			api.WriteMessage(w, 400, "error", "new owner must be a collaborator on the node")
+			return
+		}
+
+		hasAccess, err := ar.NodeRepo.CheckNodeAccess(r.Context(), node.ID, transfer.NewOwnerID)
+		if err != nil {
+			applog.Error("Failed to check node access:", err)
+			api.WriteInternalError(w)
+			return
+		}
+		if !hasAccess {
+			api.WriteMessage(w, 400, "error", "new owner must be a collaborator on the node")
+			return
+		}
+
+		transfer.UserID = user.ID
+	}
+
+	now := time.Now().UTC().Unix()
+	if config.App.AccountDeletionGrace <= 0 {
+		if err := ar.UserRepo.ScheduleDeletion(r.Context(), user.ID, now, req.Transfers); err != nil {
+			applog.Error("Failed to schedule account deletion:", err)
+			api.WriteInternalError(w)
+			return
+		}
+		if err := ar.UserRepo.PurgeUser(r.Context(), user.ID, now); err != nil {
+			applog.Error("Failed to delete account:", err)
+			api.WriteInternalError(w)
+			return
+		}
+
+		utils.ClearAllCookies(w)
+		applog.Info("Account deleted", "userID:", user.ID)
+		api.WriteMessage(w, 200, "message", "account deleted")
+		return
+	}
+
+	deleteAt := user.DeletionScheduledAt
+	if deleteAt == 0 {
+		deleteAt = now + config.App.AccountDeletionGrace
+	}
+
+	if err := ar.UserRepo.ScheduleDeletion(r.Context(), user.ID, deleteAt, req.Transfers); err != nil {
+		applog.Error("Failed to schedule account deletion:", err)
+		api.WriteInternalError(w)
+		return
+	}
+
+	transfers := req.Transfers
+	if transfers == nil {
+		transfers = []model.NodeTransfer{}
+	}
+
+	applog.Info("Account deletion scheduled", "userID:", user.ID, "deleteAt:", deleteAt, "transfers:", len(transfers))
+	api.WriteJSON(w, 200, DeleteAccountResponse{DeletionScheduledAt: deleteAt, Transfers: transfers})
+}
+
+// reconfirmIdentity checks the password again before a destructive action. accounts
+// that only sign in through a provider have no password, so they need a fresh session.
+func (ar *AuthRouter) reconfirmIdentity(w http.ResponseWriter, r *http.Request, user *model.User, password string) bool {
+	if user.PasswordHash != "" {
+		if !utils.ComparePassword(user.PasswordHash, password) {
+			applog.Warn("Incorrect current password", "userID:", user.ID)
+			api.WriteInvalidCredentials(w)
+			return false
+		}
+		return true
+	}
+
+	sessionID, ok := utils.SessionIDFromContext(r.Context())
+	if !ok {
+		api.WriteMessage(w, 403, "error", "please sign in again to confirm")
+		return false
+	}
+
+	session, err := ar.SessionRepo.GetSessionByID(r.Context(), sessionID)
+	if err != nil {
+		applog.Error("Failed to load session:", err)
+		api.WriteInternalError(w)
+		return false
+	}
+
+	if time.Now().UTC().Unix()-session.CreatedAt > recentLoginWindow {
+		api.WriteMessage(w, 403, "error", "please sign in again to confirm")
+		return false
+	}
+
+	return true
+}
+
+// @Summary Cancel account deletion
+// @Description Cancel a scheduled account deletion during the grace period. Node transfers chosen with the deletion request are discarded.
+// @Tags Account
+// @Accept json
+// @Produce json
+// @Security CookieAuth
+// @Success 200 {object} api.SuccessResponse "Account deletion cancelled"
+// @Failure 400 {object} api.ErrorResponse "Account is not scheduled for deletion"
+// @Failure 401 {object} api.ErrorResponse "Unauthorized - invalid or missing session cookie"
+// @Failure 429 {object} api.ErrorResponse "Rate limit exceeded (10 requests per minute)"
+// @Failure 500 {object} api.ErrorResponse "Internal server error"
+// @Router /auth/account/restore [post]
+func (ar *AuthRouter) HandleRestoreAccount(w http.ResponseWriter, r *http.Request) {
+	applog.Info("HandleRestoreAccount called")
+	user, ok := utils.UserFromContext(r.Context())
+	if !ok {
+		applog.Error("Failed to get user from context")
+		api.WriteInternalError(w)
+		return
+	}
+
+	if user.DeletionScheduledAt == 0 {
+		api.WriteMessage(w, 400, "error", "account is not scheduled for deletion")
+		return
+	}
+
+	if err := ar.UserRepo.CancelDeletion(r.Context(), user.ID); err != nil {
+		applog.Error("Failed to cancel account deletion:", err)
+		api.WriteInternalError(w)
+		return
+	}
+
+	applog.Info("Account deletion cancelled", "userID:", user.ID)
+	api.WriteMessage(w, 200, "message", "account deletion cancelled")
+}
@@ -0,0 +1,177 @@
+package auth
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/akramboussanni/treenode/internal/api"
+	"github.com/akramboussanni/treenode/internal/applog"
+	"github.com/akramboussanni/treenode/internal/model"
+	"github.com/akramboussanni/treenode/internal/utils"
+	"github.com/go-chi/chi/v5"
+)
+
+const apiTokenNameMaxLength = 100
+
+// @Summary List personal access tokens
+// @Description List the personal access tokens of the current user. Raw token values are never returned after creation.
+// @Tags Account
+// @Accept json
+// @Produce json
+// @Security CookieAuth
+// @Success 200 {array} model.ApiToken "Personal access tokens, newest first"
+// @Failure 401 {object} api.ErrorResponse "Unauthorized - invalid or missing session cookie"
+// @Failure 429 {object} api.ErrorResponse "Rate limit exceeded (10 requests per minute)"
+// @Failure 500 {object} api.ErrorResponse "Internal server error"
+// @Router /auth/tokens [get]
+func (ar *AuthRouter) HandleListApiTokens(w http.ResponseWriter, r *http.Request) {
+	applog.Info("HandleListApiTokens called")
+	user, ok := utils.UserFromContext(r.Context())
+	if !ok {
+		applog.Error("Failed to get user from context")
+		api.WriteInternalError(w)
+		return
+	}
+
+	tokens, err := ar.ApiTokenRepo.GetApiTokensByUserID(r.Context(), user.ID)
+	if err != nil {
+		applog.Error("Failed to list api tokens:", err)
+		api.WriteInternalError(w)
+		return
+	}
+
+	if tokens == nil {
+		tokens = []model.ApiToken{}
+	}
+
+	api.WriteJSON(w, 200, tokens)
+}
+
+// @Summary Create a personal access token
+// @Description Create a named, scoped token for the nodes API, sent as an Authorization Bearer header. The raw token is only shown in this response.
+// @Tags Account
+// @Accept json
+// @Produce json
+// @Security CookieAuth
+// @Param request body CreateApiTokenRequest true "Token name, scopes and optional lifetime"
+// @Success 201 {object} CreateApiTokenResponse "Token created"
+// @Failure 400 {object} api.ErrorResponse "Invalid name, scope or lifetime"
+// @Failure 401 {object} api.ErrorResponse "Unauthorized - invalid or missing session cookie"
+// @Failure 429 {object} api.ErrorResponse "Rate limit exceeded (10 requests per minute)"
+// @Failure 500 {object} api.ErrorResponse "Internal server error"
+// @Router /auth/tokens [post]
+func (ar *AuthRouter) HandleCreateApiToken(w http.ResponseWriter, r *http.Request) {
+	applog.Info("HandleCreateApiToken called")
+	req, err := api.DecodeJSON[CreateApiTokenRequest](w, r)
+	if err != nil {
+		applog.Error("Failed to decode create api token request:", err)
+		return
+	}
+
+	user, ok := utils.UserFromContext(r.Context())
+	if !ok {
+		applog.Error("Failed to get user from context")
+		api.WriteInternalError(w)
+		return
+	}
+
+	name, ok := utils.ValidateAndSanitizeString(req.Name, apiTokenNameMaxLength)
+	if !ok {
+		api.WriteMessage(w, 400, "error", "invalid token name")
+		return
+	}
+
+	if len(req.Scopes) == 0 {
+		api.WriteMessage(w, 400, "error", "at least one scope is required")
+		return
+	}
+
+	scopes := make([]string, 0, len(req.Scopes))
+	for _, scope := range req.Scopes {
+		if !model.IsValidApiScope(model.ApiScope(scope)) {
+			api.WriteMessage(w, 400, "error", "invalid scope: "+scope)
+			return
+		}
+		scopes = append(scopes, scope)
+	}
+
+	if req.ExpiresIn < 0 {
+		api.WriteMessage(w, 400, "error", "invalid expiry")
+		return
+	}
+
+	token, err := utils.GetRandomToken(32)
+	if err != nil {
+		applog.Error("Failed to generate api token:", err)
+		api.WriteInternalError(w)
+		return
+	}
+
+	now := time.Now().UTC().Unix()
+	apiToken := model.ApiToken{
+		ID:        utils.GenerateSnowflakeID(),
+		UserID:    user.ID,
+		Name:      name,
+		TokenHash: token.Hash,
+		Scopes:    strings.Join(scopes, ","),
+		CreatedAt: now,
+	}
+	if req.ExpiresIn > 0 {
+		apiToken.ExpiresAt = now + req.ExpiresIn
+	}
+
+	if err := ar.ApiTokenRepo.CreateApiToken(r.Context(), &apiToken); err != nil {
+		applog.Error("Failed to create api token:", err)
+		api.WriteInternalError(w)
+		return
+	}
+
+	applog.Info("Api token created", "userID:", user.ID, "tokenID:", apiToken.ID)
+	api.WriteJSON(w, 201, CreateApiTokenResponse{ApiToken: apiToken, Token: utils.ApiTokenPrefix + token.Raw})
+}
+
+// @Summary Revoke a personal access token
+// @Description Delete a personal access token. Requests using it are rejected immediately.
+// @Tags Account
+// @Accept json
+// @Produce json
+// @Security CookieAuth
+// @Param tokenID path string true "Token ID"
+// @Success 200 {object} api.SuccessResponse "Token revoked"
+// @Failure 400 {object} api.ErrorResponse "Invalid token ID"
+// @Failure 401 {object} api.ErrorResponse "Unauthorized - invalid or missing session cookie"
+// @Failure 404 {object} api.ErrorResponse "Token not found"
+// @Failure 429 {object} api.ErrorResponse "Rate limit exceeded (10 requests per minute)"
+// @Failure 500 {object} api.ErrorResponse "Internal server error"
+// @Router /auth/tokens/{tokenID} [delete]
+func (ar *AuthRouter) HandleRevokeApiToken(w http.ResponseWriter, r *http.Request) {
+	applog.Info("HandleRevokeApiToken called")
+	tokenID, err := utils.ParseID(chi.URLParam(r, "tokenID"))
+	if err != nil {
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return
+	}
+
+	user, ok := utils.UserFromContext(r.Context())
+	if !ok {
+		applog.Error("Failed to get user from context")
+		api.WriteInternalError(w)
+		return
+	}
+
+	deleted, err := ar.ApiTokenRepo.DeleteApiToken(r.Context(), tokenID, user.ID)
+	if err != nil {
+		applog.Error("Failed to revoke api token:", err)
+		api.WriteInternalError(w)
+		return
+	}
+
+	if !deleted {
+		api.WriteMessage(w, 404, "error", "token not found")
+		return
+	}
+
+	applog.Info("Api token revoked", "userID:", user.ID, "tokenID:", tokenID)
+	api.WriteMessage(w, 200, "message", "token revoked")
+}
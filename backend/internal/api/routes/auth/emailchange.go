@@ -0,0 +1,223 @@
+package auth
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/akramboussanni/treenode/config"
+	"github.com/akramboussanni/treenode/internal/api"
+	"github.com/akramboussanni/treenode/internal/applog"
+	"github.com/akramboussanni/treenode/internal/utils"
+)
+
+// @Summary Request email address change
+// @Description Start changing the account's email address. A confirmation link is sent to the new address and a notice with a cancel link to the current one. The address only changes once the new address confirms, after which every session is logged out.
+// @Tags Account
+// @Accept json
+// @Produce json
+// @Security CookieAuth
+// @Param X-Recaptcha-Token header string false "reCAPTCHA verification token (optional if reCAPTCHA is not configured)"
+// @Param request body EmailChangeRequest true "New address, password confirmation and frontend URLs"
+// @Success 200 {object} api.SuccessResponse "Confirmation email sent to the new address"
+// @Failure 400 {object} api.ErrorResponse "Invalid or unavailable email address"
+// @Failure 401 {object} api.ErrorResponse "Unauthorized - invalid password or missing session cookie"
+// @Failure 403 {object} api.ErrorResponse "Recent login required"
+// @Failure 429 {object} api.ErrorResponse "Rate limit exceeded (8 requests per hour)"
+// @Failure 500 {object} api.ErrorResponse "Internal server error or email sending failure"
+// @Router /auth/change-email [post]
+func (ar *AuthRouter) HandleRequestEmailChange(w http.ResponseWriter, r *http.Request) {
+	applog.Info("HandleRequestEmailChange called")
+	req, err := api.DecodeJSON[EmailChangeRequest](w, r)
+	if err != nil {
+		applog.Error("Failed to decode email change request:", err)
+		return
+	}
+
+	user, ok := utils.UserFromContext(r.Context())
+	if !ok {
+		applog.Error("Failed to get user from context")
+		api.WriteInternalError(w)
+		return
+	}
+
+	if !ar.reconfirmIdentity(w, r, user, req.Password) {
+		return
+	}
+
+	if !utils.IsValidEmail(req.NewEmail) || strings.EqualFold(req.NewEmail, user.Email) {
+		api.WriteMessage(w, 400, "error", "invalid email address")
+		return
+	}
+
+	duplicate, err := ar.UserRepo.DuplicateEmail(r.Context(), req.NewEmail)
+	if err != nil {
+		applog.Error("Failed to check duplicate email:", err)
+		api.WriteInternalError(w)
+		return
+	}
+
+	if duplicate {
+		applog.Warn("Email change to an address in use", "userID:", user.ID)
+		api.WriteMessage(w, 400, "error", "email address already in use")
+		return
+	}
+
+	expiryStr := utils.ExpiryToString(int(config.App.EmailConfirmExpiry))
+	token, err := GenerateTokenAndSendEmail(req.NewEmail, "confirmemailchange", "Confirm your new email address", req.Url, map[string]any{"Expiry": expiryStr, "Url": req.Url})
+	if err != nil {
+		applog.Error("Failed to send email change confirmation:", err)
+		api.WriteInternalError(w)
+		return
+	}
+
+	cancelToken, err := GenerateTokenAndSendEmail(user.Email, "emailchangenotice", "Your email address is being changed", req.CancelUrl, map[string]any{"Expiry": expiryStr, "Url": req.CancelUrl, "NewEmail": req.NewEmail})
+	if err != nil {
+		applog.Error("Failed to send email change notice:", err)
+		api.WriteInternalError(w)
+		return
+	}
+
+	if err := ar.UserRepo.AssignEmailChange(r.Context(), user.ID, req.NewEmail, token.Hash, cancelToken.Hash, time.Now().UTC().Unix()); err != nil {
+		applog.Error("Failed to assign email change:", err)
+		api.WriteInternalError(w)
+		return
+	}
+
+	applog.Info("Email change requested", "userID:", user.ID)
+	api.WriteMessage(w, 200, "message", "confirmation email sent to the new address")
+}
+
+// @Summary Confirm email address change
+// @Description Confirm a pending email address change with the token sent to the new address. Every session is logged out, so the user has to sign in again with the new address. Reset and sign-in links sent to the old address stop working.
+// @Tags Email Verification
+// @Accept json
+// @Produce json
+// @Param X-Recaptcha-Token header string false "reCAPTCHA verification token (optional if reCAPTCHA is not configured)"
+// @Param request body TokenRequest true "Email change token"
+// @Success 200 {object} api.SuccessResponse "Email address changed"
+// @Failure 400 {object} api.ErrorResponse "Invalid request format or missing token"
+// @Failure 401 {object} api.ErrorResponse "Invalid or expired token"
+// @Failure 409 {object} api.ErrorResponse "The new address was taken in the meantime"
+// @Failure 429 {object} api.ErrorResponse "Rate limit exceeded (15 requests per hour)"
+// @Failure 500 {object} api.ErrorResponse "Internal server error"
+// @Router /auth/change-email/confirm [post]
+func (ar *AuthRouter) HandleConfirmEmailChange(w http.ResponseWriter, r *http.Request) {
+	applog.Info("HandleConfirmEmailChange called")
+	req, err := api.DecodeJSON[TokenRequest](w, r)
+	if err != nil {
+		applog.Error("Failed to decode email change confirmation:", err)
+		return
+	}
+
+	b, err := base64.URLEncoding.DecodeString(req.Token)
+	if err != nil {
+		applog.Warn("Failed to decode email change token:", err)
+		api.WriteInvalidCredentials(w)
+		return
+	}
+
+	sha := sha256.Sum256(b)
+	tokenHash := base64.URLEncoding.EncodeToString(sha[:])
+	user, err := ar.UserRepo.GetUserByEmailChangeToken(r.Context(), tokenHash)
+	if err != nil {
+		api.WriteInvalidCredentials(w)
+		return
+	}
+
+	expiry := user.EmailChangeIssuedAt + config.App.EmailConfirmExpiry
+	if expiry < time.Now().UTC().Unix() {
+		applog.Warn("Expired email change token", "userID:", user.ID)
+		api.WriteMessage(w, 401, "error", "expired token, please request a new one")
+		return
+	}
+
+	duplicate, err := ar.UserRepo.DuplicateEmail(r.Context(), user.PendingEmail)
+	if err != nil {
+		applog.Error("Failed to check duplicate email:", err)
+		api.WriteInternalError(w)
+		return
+	}
+
+	if duplicate {
+		applog.Warn("Pending email taken before confirmation", "userID:", user.ID)
+		api.WriteMessage(w, 409, "error", "email address already in use")
+		return
+	}
+
+	changed, err := ar.UserRepo.ConfirmEmailChange(r.Context(), user.ID, tokenHash)
+	if err != nil {
+		applog.Error("Failed to confirm email change:", err)
+		api.WriteInternalError(w)
+		return
+	}
+
+	if !changed {
+		api.WriteInvalidCredentials(w)
+		return
+	}
+
+	if err := ar.UserRepo.ChangeJwtSessionID(r.Context(), user.ID, utils.GenerateSnowflakeID()); err != nil {
+		applog.Error("Failed to rotate sessions after email change:", err)
+		api.WriteInternalError(w)
+		return
+	}
+
+	utils.ClearAllCookies(w)
+	applog.Info("Email address changed", "userID:", user.ID)
+	api.WriteMessage(w, 200, "message", "email address changed, please sign in again")
+}
+
+// @Summary Cancel email address change
+// @Description Cancel a pending email address change with the token sent to the current address. Since the change may not have been made by the account owner, every session is logged out as well.
+// @Tags Email Verification
+// @Accept json
+// @Produce json
+// @Param X-Recaptcha-Token header string false "reCAPTCHA verification token (optional if reCAPTCHA is not configured)"
+// @Param request body TokenRequest true "Email change cancel token"
+// @Success 200 {object} api.SuccessResponse "Email change cancelled"
+// @Failure 400 {object} api.ErrorResponse "Invalid request format or missing token"
+// @Failure 401 {object} api.ErrorResponse "Invalid or expired token"
+// @Failure 429 {object} api.ErrorResponse "Rate limit exceeded (15 requests per hour)"
+// @Failure 500 {object} api.ErrorResponse "Internal server error"
+// @Router /auth/change-email/cancel [post]
+func (ar *AuthRouter) HandleCancelEmailChange(w http.ResponseWriter, r *http.Request) {
+	applog.Info("HandleCancelEmailChange called")
+	req, err := api.DecodeJSON[TokenRequest](w, r)
+	if err != nil {
+		applog.Error("Failed to decode email change cancellation:", err)
+		return
+	}
+
+	b, err := base64.URLEncoding.DecodeString(req.Token)
+	if err != nil {
+		applog.Warn("Failed to decode email change cancel token:", err)
+		api.WriteInvalidCredentials(w)
+		return
+	}
+
+	sha := sha256.Sum256(b)
+	tokenHash := base64.URLEncoding.EncodeToString(sha[:])
+	user, err := ar.UserRepo.GetUserByEmailChangeCancelToken(r.Context(), tokenHash)
+	if err != nil {
+		api.WriteInvalidCredentials(w)
+		return
+	}
+
+	if err := ar.UserRepo.ClearEmailChange(r.Context(), user.ID); err != nil {
+		applog.Error("Failed to cancel email change:", err)
+		api.WriteInternalError(w)
+		return
+	}
+
+	if err := ar.UserRepo.ChangeJwtSessionID(r.Context(), user.ID, utils.GenerateSnowflakeID()); err != nil {
+		applog.Error("Failed to rotate sessions after email change cancellation:", err)
+		api.WriteInternalError(w)
+		return
+	}
+
+	applog.Warn("Email change cancelled from the old address", "userID:", user.ID)
+	api.WriteMessage(w, 200, "message", "email change cancelled and all sessions logged out, consider resetting your password")
+}
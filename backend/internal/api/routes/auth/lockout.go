@@ -0,0 +1,256 @@
+package auth
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"net/http"
+	"time"
+
+	"github.com/akramboussanni/treenode/config"
+	"github.com/akramboussanni/treenode/internal/api"
+	"github.com/akramboussanni/treenode/internal/applog"
+	"github.com/akramboussanni/treenode/internal/mailer"
+	"github.com/akramboussanni/treenode/internal/model"
+	"github.com/akramboussanni/treenode/internal/utils"
+)
+
+// registerFailedLogin records a failed attempt and applies every lockout it pushes over
+// its threshold. user is nil for unknown emails, which still count towards the address.
+// it always writes the error response.
+func (ar *AuthRouter) registerFailedLogin(ctx context.Context, w http.ResponseWriter, user *model.User, ip string) {
+	now := time.Now().UTC().Unix()
+	prefix := utils.IPPrefix(ip)
+
+	var userID int64
+	if user != nil {
+		userID = user.ID
+	}
+
+	err := ar.LockoutRepo.AddFailedLogin(ctx, model.FailedLogin{ID: utils.GenerateSnowflakeID(), UserID: userID, IPAddress: ip, IPPrefix: prefix, AttemptedAt: now, Active: true})
+	if err != nil {
+		applog.Error("Failed to add failed login:", err)
+		api.WriteInternalError(w)
+		return
+	}
+
+	locked := false
+	if user != nil {
+		accountCount, err := ar.LockoutRepo.CountRecentFailuresByUser(ctx, user.ID)
+		if err != nil {
+			applog.Error("Failed to count recent failures:", err)
+			api.WriteInternalError(w)
+			return
+		}
+
+		pairCount, err := ar.LockoutRepo.CountRecentFailures(ctx, user.ID, prefix)
+		if err != nil {
+			applog.Error("Failed to count recent failures:", err)
+			api.WriteInternalError(w)
+			return
+		}
+
+		switch {
+		case accountCount > config.App.AccountLockoutCount:
+			err = ar.lockAccount(ctx, user, ip, now)
+			locked = true
+		case pairCount > config.App.LockoutCount:
+			err = ar.lockPair(ctx, user, ip, now)
+			locked = true
+		}
+
+		if err != nil {
+			applog.Error("Failed to add lockout:", err)
+			api.WriteInternalError(w)
+			return
+		}
+	}
+
+	prefixCount, err := ar.LockoutRepo.CountRecentFailuresByPrefix(ctx, prefix)
+	if err != nil {
+		applog.Error("Failed to count recent failures:", err)
+		api.WriteInternalError(w)
+		return
+	}
+
+	if prefixCount > config.App.IpLockoutCount {
+		if err := ar.lockPrefix(ctx, ip, now); err != nil {
+			applog.Error("Failed to add lockout:", err)
+			api.WriteInternalError(w)
+			return
+		}
+		locked = true
+	}
+
+	if locked {
+		api.WriteMessage(w, 423, "error", "account locked")
+		return
+	}
+
+	api.WriteInvalidCredentials(w)
+}
+
+// backoffDuration doubles the lockout duration for every earlier lockout in the backoff window
+func (ar *AuthRouter) backoffDuration(ctx context.Context, scope string, userID int64, prefix string, now int64) (int64, error) {
+	previous, err := ar.LockoutRepo.CountRecentLockouts(ctx, scope, userID, prefix, now-config.App.LockoutBackoffWindow)
+	if err != nil {
+		return 0, err
+	}
+
+	duration := config.App.LockoutDuration
+	for i := 0; i < previous && duration < config.App.LockoutMaxDuration; i++ {
+		duration *= 2
+	}
+	return min(duration, config.App.LockoutMaxDuration), nil
+}
+
+// lockPair locks the user out from the address prefix and lets the owner know
+func (ar *AuthRouter) lockPair(ctx context.Context, user *model.User, ip string, now int64) error {
+	prefix := utils.IPPrefix(ip)
+	duration, err := ar.backoffDuration(ctx, model.LockoutPair, user.ID, prefix, now)
+	if err != nil {
+		return err
+	}
+
+	err = ar.LockoutRepo.AddLockout(ctx, model.Lockout{
+		ID:          utils.GenerateSnowflakeID(),
+		UserID:      user.ID,
+		IPAddress:   ip,
+		IPPrefix:    prefix,
+		Scope:       model.LockoutPair,
+		LockedUntil: now + duration,
+		Reason:      "failed logins",
+		CreatedAt:   now,
+		Active:      true,
+	})
+	if err != nil {
+		return err
+	}
+
+	applog.Warn("User locked out due to failed logins", "userID:", user.ID, "ip:", ip, "duration:", duration)
+
+	data := map[string]any{"IPAddress": ip, "Duration": utils.ExpiryToString(int(duration))}
+	if err := mailer.Send("accountlocked", []string{user.Email}, "Sign-in attempts on your account were blocked", data); err != nil {
+		applog.Error("Failed to send lockout notice:", err)
+	}
+	return nil
+}
+
+// lockAccount locks the user out from every address after failures from too many of them.
+// the owner gets a link to lift it early when ACCOUNT_UNLOCK_URL is set.
+func (ar *AuthRouter) lockAccount(ctx context.Context, user *model.User, ip string, now int64) error {
+	duration := config.App.AccountLockoutDuration
+	expiryStr := utils.ExpiryToString(int(duration))
+
+	var tokenHash string
+	if url := config.App.AccountUnlockUrl; url != "" {
+		token, err := GenerateTokenAndSendEmail(user.Email, "accountunlock", "Your account has been locked", url, map[string]any{"Expiry": expiryStr, "Url": url})
+		if err != nil {
+			applog.Error("Failed to send unlock email:", err)
+		} else {
+			tokenHash = token.Hash
+		}
+	} else {
+		data := map[string]any{"IPAddress": ip, "Duration": expiryStr}
+		if err := mailer.Send("accountlocked", []string{user.Email}, "Your account has been locked", data); err != nil {
+			applog.Error("Failed to send lockout notice:", err)
+		}
+	}
+
+	err := ar.LockoutRepo.AddLockout(ctx, model.Lockout{
+		ID:          utils.GenerateSnowflakeID(),
+		UserID:      user.ID,
+		IPAddress:   ip,
+		Scope:       model.LockoutAccount,
+		LockedUntil: now + duration,
+		Reason:      "failed logins from many addresses",
+		UnlockToken: tokenHash,
+		CreatedAt:   now,
+		Active:      true,
+	})
+	if err != nil {
+		return err
+	}
+
+	applog.Warn("Security event: account locked after failed logins from many addresses", "userID:", user.ID, "ip:", ip)
+	return nil
+}
+
+// lockPrefix locks the address prefix out of every account
+func (ar *AuthRouter) lockPrefix(ctx context.Context, ip string, now int64) error {
+	prefix := utils.IPPrefix(ip)
+	duration, err := ar.backoffDuration(ctx, model.LockoutIP, 0, prefix, now)
+	if err != nil {
+		return err
+	}
+
+	err = ar.LockoutRepo.AddLockout(ctx, model.Lockout{
+		ID:          utils.GenerateSnowflakeID(),
+		IPAddress:   ip,
+		IPPrefix:    prefix,
+		Scope:       model.LockoutIP,
+		LockedUntil: now + duration,
+		Reason:      "failed logins across accounts",
+		CreatedAt:   now,
+		Active:      true,
+	})
+	if err != nil {
+		return err
+	}
+
+	applog.Warn("Security event: address locked out after failed logins across accounts", "prefix:", prefix, "duration:", duration)
+	return nil
+}
+
+// @Summary Unlock account
+// @Description Lift an account-wide lockout with the token from the lockout email. Failed sign-in attempts are forgiven and the address the request comes from is unlocked as well, lockouts on other addresses stay in place.
+// @Tags Authentication
+// @Accept json
+// @Produce json
+// @Param X-Recaptcha-Token header string false "reCAPTCHA verification token (optional if reCAPTCHA is not configured)"
+// @Param request body TokenRequest true "Unlock token from the email"
+// @Success 200 {object} api.SuccessResponse "Account unlocked"
+// @Failure 400 {object} api.ErrorResponse "Invalid request format"
+// @Failure 401 {object} api.ErrorResponse "Invalid, used or expired token"
+// @Failure 429 {object} api.ErrorResponse "Rate limit exceeded (15 requests per hour)"
+// @Failure 500 {object} api.ErrorResponse "Internal server error"
+// @Router /auth/unlock-account [post]
+func (ar *AuthRouter) HandleUnlockAccount(w http.ResponseWriter, r *http.Request) {
+	ip := utils.GetClientIP(r)
+	applog.Info("HandleUnlockAccount called", "remoteAddr:", ip)
+	req, err := api.DecodeJSON[TokenRequest](w, r)
+	if err != nil {
+		applog.Error("Failed to decode unlock request:", err)
+		return
+	}
+
+	b, err := base64.URLEncoding.DecodeString(req.Token)
+	if err != nil {
+		applog.Warn("Failed to decode unlock token:", err)
+		api.WriteInvalidCredentials(w)
+		return
+	}
+
+	sha := sha256.Sum256(b)
+	tokenHash := base64.URLEncoding.EncodeToString(sha[:])
+	lockout, err := ar.LockoutRepo.GetLockoutByUnlockToken(r.Context(), tokenHash)
+	if err != nil {
+		api.WriteInvalidCredentials(w)
+		return
+	}
+
+	if lockout.LockedUntil <= time.Now().UTC().Unix() {
+		applog.Warn("Expired unlock token", "userID:", lockout.UserID)
+		api.WriteMessage(w, 401, "error", "expired token, the lockout has already ended")
+		return
+	}
+
+	if err := ar.LockoutRepo.UnlockAccount(r.Context(), lockout.UserID, utils.IPPrefix(ip)); err != nil {
+		applog.Error("Failed to unlock account:", err)
+		api.WriteInternalError(w)
+		return
+	}
+
+	applog.Info("Account unlocked by email", "userID:", lockout.UserID, "ip:", ip)
+	api.WriteMessage(w, 200, "message", "account unlocked")
+}
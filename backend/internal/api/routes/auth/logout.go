@@ -1,7 +1,6 @@
 package auth
 
 import (
-	"math"
 	"net/http"
 
 	"github.com/akramboussanni/treenode/config"
@@ -31,7 +30,7 @@ func (ar *AuthRouter) HandleLogout(w http.ResponseWriter, r *http.Request) {
 	err := ar.TokenRepo.RevokeToken(r.Context(), model.JwtBlacklist{
 		TokenID:   claims.TokenID,
 		UserID:    claims.UserID,
-		ExpiresAt: math.MaxInt64,
+		ExpiresAt: claims.PairExpiry(),
 	})
 
 	if err != nil {
@@ -40,6 +39,12 @@ func (ar *AuthRouter) HandleLogout(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if _, err := ar.SessionRepo.DeleteSession(r.Context(), claims.SessionID, claims.UserID); err != nil {
+		applog.Error("Failed to delete session during logout:", err)
+		api.WriteInternalError(w)
+		return
+	}
+
 	utils.ClearAllCookies(w)
 
 	applog.Info("User logged out successfully", "userID:", claims.UserID, "tokenID:", claims.TokenID)
@@ -0,0 +1,175 @@
+package auth
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"net/http"
+	"time"
+
+	"github.com/akramboussanni/treenode/config"
+	"github.com/akramboussanni/treenode/internal/api"
+	"github.com/akramboussanni/treenode/internal/applog"
+	"github.com/akramboussanni/treenode/internal/jwt"
+	"github.com/akramboussanni/treenode/internal/mailer"
+	"github.com/akramboussanni/treenode/internal/model"
+	"github.com/akramboussanni/treenode/internal/utils"
+)
+
+// @Summary Request a magic sign-in link
+// @Description Email a single-use sign-in link to the address. The response is the same whether or not an account exists, so it can't be used to discover accounts. Only available when MAGIC_LINK_ENABLED is set.
+// @Tags Authentication
+// @Accept json
+// @Produce json
+// @Param X-Recaptcha-Token header string false "reCAPTCHA verification token (optional if reCAPTCHA is not configured)"
+// @Param request body EmailRequest true "User email and sign-in page URL"
+// @Success 200 {object} api.SuccessResponse "Sign-in link sent if the account exists"
+// @Failure 400 {object} api.ErrorResponse "Invalid request format"
+// @Failure 429 {object} api.ErrorResponse "Rate limit exceeded (15 requests per hour)"
+// @Router /auth/magic-link [post]
+func (ar *AuthRouter) HandleSendMagicLink(w http.ResponseWriter, r *http.Request) {
+	ip := utils.GetClientIP(r)
+	applog.Info("HandleSendMagicLink called", "remoteAddr:", ip)
+	req, err := api.DecodeJSON[EmailRequest](w, r)
+	if err != nil {
+		applog.Error("Failed to decode magic link request:", err)
+		return
+	}
+
+	user, err := ar.UserRepo.GetUserByEmail(r.Context(), req.Email)
+	if err != nil || user == nil {
+		applog.Warn("Magic link: user not found", "email:", req.Email)
+		api.WriteMessage(w, 200, "message", "magic link sent")
+		return
+	}
+
+	// every outcome past this point answers the same way so the endpoint can't be used
+	// to tell which addresses have accounts
+	lockedOut, err := ar.LockoutRepo.IsLockedOut(r.Context(), user.ID, utils.IPPrefix(ip))
+	if err != nil {
+		applog.Error("Error checking lockout:", err)
+		api.WriteMessage(w, 200, "message", "magic link sent")
+		return
+	}
+
+	if lockedOut {
+		applog.Warn("Magic link: account locked out", "userID:", user.ID, "ip:", ip)
+		api.WriteMessage(w, 200, "message", "magic link sent")
+		return
+	}
+
+	token, err := utils.GetRandomToken(16)
+	if err != nil {
+		applog.Error("Failed to generate magic link token:", err)
+		api.WriteMessage(w, 200, "message", "magic link sent")
+		return
+	}
+
+	// store the hash before mailing so a link never goes out that can't be redeemed
+	if err := ar.UserRepo.AssignUserMagicLinkToken(r.Context(), token.Hash, time.Now().UTC().Unix(), user.ID); err != nil {
+		applog.Error("Failed to assign magic link token:", err)
+		api.WriteMessage(w, 200, "message", "magic link sent")
+		return
+	}
+
+	expiryStr := utils.ExpiryToString(int(config.App.MagicLinkExpiry))
+	data := map[string]any{"Expiry": expiryStr, "Url": req.Url, "Token": token.Raw}
+	if err := mailer.Send("magiclink", []string{user.Email}, "Your sign-in link", data); err != nil {
+		applog.Error("Failed to send magic link:", err)
+		api.WriteMessage(w, 200, "message", "magic link sent")
+		return
+	}
+
+	applog.Info("Magic link sent", "userID:", user.ID)
+	api.WriteMessage(w, 200, "message", "magic link sent")
+}
+
+// @Summary Sign in with a magic link
+// @Description Exchange the token from a magic link email for session and refresh cookies. The token works once. Accounts with two-factor authentication get an mfa token to finish at /auth/login/mfa.
+// @Tags Authentication
+// @Accept json
+// @Produce json
+// @Param X-Recaptcha-Token header string false "reCAPTCHA verification token (optional if reCAPTCHA is not configured)"
+// @Param request body TokenRequest true "Token from the email"
+// @Success 200 {object} api.SuccessResponse "Authentication successful - session and refresh cookies set"
+// @Success 200 {object} MfaChallengeResponse "Link accepted - a second factor is required via /auth/login/mfa"
+// @Failure 400 {object} api.ErrorResponse "Invalid request format"
+// @Failure 401 {object} api.ErrorResponse "Invalid, used or expired token"
+// @Failure 423 {object} api.ErrorResponse "Account locked"
+// @Failure 429 {object} api.ErrorResponse "Rate limit exceeded (8 requests per minute)"
+// @Failure 500 {object} api.ErrorResponse "Internal server error"
+// @Router /auth/magic-link/verify [post]
+func (ar *AuthRouter) HandleVerifyMagicLink(w http.ResponseWriter, r *http.Request) {
+	ip := utils.GetClientIP(r)
+	applog.Info("HandleVerifyMagicLink called", "remoteAddr:", ip)
+	req, err := api.DecodeJSON[TokenRequest](w, r)
+	if err != nil {
+		applog.Error("Failed to decode magic link verify request:", err)
+		return
+	}
+
+	b, err := base64.URLEncoding.DecodeString(req.Token)
+	if err != nil {
+		applog.Warn("Failed to decode magic link token:", err)
+		api.WriteInvalidCredentials(w)
+		return
+	}
+
+	sha := sha256.Sum256(b)
+	tokenHash := base64.URLEncoding.EncodeToString(sha[:])
+	user, err := ar.UserRepo.GetUserByMagicLinkToken(r.Context(), tokenHash)
+	if err != nil {
+		api.WriteInvalidCredentials(w)
+		return
+	}
+
+	lockedOut, err := ar.LockoutRepo.IsLockedOut(r.Context(), user.ID, utils.IPPrefix(ip))
+	if err != nil {
+		applog.Error("Error checking lockout:", err)
+		api.WriteInternalError(w)
+		return
+	}
+
+	if lockedOut {
+		applog.Warn("Account locked out", "userID:", user.ID, "ip:", ip)
+		api.WriteMessage(w, 423, "error", "account locked")
+		return
+	}
+
+	consumed, err := ar.UserRepo.ConsumeMagicLinkToken(r.Context(), user.ID, tokenHash)
+	if err != nil {
+		applog.Error("Failed to consume magic link token:", err)
+		api.WriteInternalError(w)
+		return
+	}
+
+	if !consumed {
+		api.WriteInvalidCredentials(w)
+		return
+	}
+
+	expiry := user.MagicLinkIssuedAt + config.App.MagicLinkExpiry
+	if expiry < time.Now().UTC().Unix() {
+		applog.Warn("Expired magic link token", "userID:", user.ID)
+		http.Error(w, "expired token, please request a new one", http.StatusUnauthorized)
+		return
+	}
+
+	// following the link proves the user controls the address, anything set up on the
+	// unconfirmed account came from whoever registered it
+	if !user.EmailConfirmed {
+		if err := ar.claimUnconfirmedUser(r, user); err != nil {
+			applog.Error("Failed to confirm email:", err)
+			api.WriteInternalError(w)
+			return
+		}
+	}
+
+	if user.TotpEnabled {
+		mfaToken := jwt.CreateJwtFromUser(user, user.JwtSessionID).WithType(model.MfaPendingJwt).GenerateToken()
+		applog.Info("Magic link accepted, awaiting second factor", "userID:", user.ID)
+		api.WriteJSON(w, 200, MfaChallengeResponse{MfaRequired: true, MfaToken: mfaToken})
+		return
+	}
+
+	ar.completeLogin(w, r, user)
+}
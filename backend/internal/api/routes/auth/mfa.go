@@ -0,0 +1,404 @@
+package auth
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/akramboussanni/treenode/config"
+	"github.com/akramboussanni/treenode/internal/api"
+	"github.com/akramboussanni/treenode/internal/applog"
+	"github.com/akramboussanni/treenode/internal/middleware"
+	"github.com/akramboussanni/treenode/internal/model"
+	"github.com/akramboussanni/treenode/internal/utils"
+)
+
+const totpIssuer = "Treenode"
+const recoveryCodeCount = 10
+
+// checks a TOTP code, falling back to a recovery code. accepted TOTP steps are
+// persisted so the same code can't be replayed within its validity window.
+func (ar *AuthRouter) verifySecondFactor(ctx context.Context, user *model.User, code, recoveryCode string) (bool, error) {
+	if code != "" {
+		step, ok := utils.ValidateTOTP(user.TotpSecret, code, time.Now())
+		if !ok || step <= user.TotpLastStep {
+			return false, nil
+		}
+		return ar.UserRepo.UpdateTotpLastStep(ctx, user.ID, step)
+	}
+
+	if recoveryCode != "" {
+		return ar.MfaRepo.UseRecoveryCode(ctx, user.ID, utils.HashRecoveryCode(recoveryCode))
+	}
+
+	return false, nil
+}
+
+func (ar *AuthRouter) issueRecoveryCodes(ctx context.Context, userID int64) ([]string, error) {
+	codes, err := utils.GenerateRecoveryCodes(recoveryCodeCount)
+	if err != nil {
+		return nil, err
+	}
+
+	hashes := make([]string, len(codes))
+	for i, code := range codes {
+		hashes[i] = utils.HashRecoveryCode(code)
+	}
+
+	if err := ar.MfaRepo.ReplaceRecoveryCodes(ctx, userID, hashes); err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// @Summary Complete login with a second factor
+// @Description Exchange the short-lived mfa token returned by /auth/login and a TOTP or recovery code for session and refresh cookies. Failed codes count towards the account lockout.
+// @Tags Authentication
+// @Accept json
+// @Produce json
+// @Param X-Recaptcha-Token header string false "reCAPTCHA verification token (optional if reCAPTCHA is not configured)"
+// @Param request body MfaLoginRequest true "Pending mfa token and code"
+// @Success 200 {object} api.SuccessResponse "Authentication successful - session and refresh cookies set"
+// @Failure 400 {object} api.ErrorResponse "Invalid request format"
+// @Failure 401 {object} api.ErrorResponse "Invalid or expired mfa token, or invalid code"
+// @Failure 423 {object} api.ErrorResponse "Account locked"
+// @Failure 429 {object} api.ErrorResponse "Rate limit exceeded (8 requests per minute)"
+// @Failure 500 {object} api.ErrorResponse "Internal server error"
+// @Router /auth/login/mfa [post]
+func (ar *AuthRouter) HandleLoginMfa(w http.ResponseWriter, r *http.Request) {
+	ip := utils.GetClientIP(r)
+	applog.Info("HandleLoginMfa called", "remoteAddr:", ip)
+	req, err := api.DecodeJSON[MfaLoginRequest](w, r)
+	if err != nil {
+		applog.Error("Failed to decode mfa login request:", err)
+		return
+	}
+
+	claims := middleware.GetClaims(w, r, req.MfaToken, config.JwtSecretBytes, ar.TokenRepo)
+	if claims == nil {
+		return
+	}
+
+	if claims.Type != model.MfaPendingJwt {
+		applog.Warn("Wrong token type for mfa login", "userID:", claims.UserID)
+		api.WriteInvalidCredentials(w)
+		return
+	}
+
+	user, err := ar.UserRepo.GetUserByID(r.Context(), claims.UserID)
+	if err != nil || user == nil {
+		applog.Warn("Mfa login failed: user not found or db error", "userID:", claims.UserID, "err:", err)
+		api.WriteInvalidCredentials(w)
+		return
+	}
+
+	// jwt_session_id rotates on password reset, logout everywhere and account disable,
+	// which takes any pending mfa token with it
+	if claims.SessionID != user.JwtSessionID || !user.TotpEnabled {
+		api.WriteInvalidCredentials(w)
+		return
+	}
+
+	lockedOut, err := ar.LockoutRepo.IsLockedOut(r.Context(), user.ID, utils.IPPrefix(ip))
+	if err != nil {
+		applog.Error("Error checking lockout:", err)
+		api.WriteInternalError(w)
+		return
+	}
+
+	if lockedOut {
+		applog.Warn("Account locked out", "userID:", user.ID, "ip:", ip)
+		api.WriteMessage(w, 423, "error", "account locked")
+		return
+	}
+
+	ok, err := ar.verifySecondFactor(r.Context(), user, req.Code, req.RecoveryCode)
+	if err != nil {
+		applog.Error("Failed to verify second factor:", err)
+		api.WriteInternalError(w)
+		return
+	}
+
+	if !ok {
+		applog.Warn("Invalid second factor for user", "userID:", user.ID)
+		ar.registerFailedLogin(r.Context(), w, user, ip)
+		return
+	}
+
+	// the pending token is single use
+	err = ar.TokenRepo.RevokeToken(r.Context(), model.JwtBlacklist{
+		TokenID:   claims.TokenID,
+		UserID:    claims.UserID,
+		ExpiresAt: claims.Expiration,
+	})
+	if err != nil {
+		applog.Error("Failed to revoke mfa token:", err)
+		api.WriteInternalError(w)
+		return
+	}
+
+	ar.completeLogin(w, r, user)
+}
+
+// @Summary Start TOTP enrollment
+// @Description Generate a new TOTP secret for the current user. Two-factor authentication is not active until the secret is confirmed with a valid code.
+// @Tags Account
+// @Accept json
+// @Produce json
+// @Security CookieAuth
+// @Success 200 {object} TotpSetupResponse "Secret and otpauth URI for the authenticator app"
+// @Failure 401 {object} api.ErrorResponse "Unauthorized - invalid or missing session cookie"
+// @Failure 409 {object} api.ErrorResponse "Two-factor authentication is already enabled"
+// @Failure 429 {object} api.ErrorResponse "Rate limit exceeded (10 requests per minute)"
+// @Failure 500 {object} api.ErrorResponse "Internal server error"
+// @Router /auth/mfa/totp/setup [post]
+func (ar *AuthRouter) HandleTotpSetup(w http.ResponseWriter, r *http.Request) {
+	applog.Info("HandleTotpSetup called")
+	user, ok := utils.UserFromContext(r.Context())
+	if !ok {
+		applog.Error("Failed to get user from context")
+		api.WriteInternalError(w)
+		return
+	}
+
+	if user.TotpEnabled {
+		api.WriteMessage(w, 409, "error", "two-factor authentication already enabled")
+		return
+	}
+
+	secret, err := utils.GenerateTOTPSecret()
+	if err != nil {
+		applog.Error("Failed to generate totp secret:", err)
+		api.WriteInternalError(w)
+		return
+	}
+
+	if err := ar.UserRepo.SetTotpSecret(r.Context(), user.ID, secret); err != nil {
+		applog.Error("Failed to store totp secret:", err)
+		api.WriteInternalError(w)
+		return
+	}
+
+	applog.Info("TOTP enrollment started", "userID:", user.ID)
+	api.WriteJSON(w, 200, TotpSetupResponse{
+		Secret:     secret,
+		OtpauthURI: utils.TOTPURI(totpIssuer, user.Email, secret),
+	})
+}
+
+// @Summary Confirm TOTP enrollment
+// @Description Confirm the pending TOTP secret with a code from the authenticator app. Enables two-factor authentication and returns one-time recovery codes, which are only shown once.
+// @Tags Account
+// @Accept json
+// @Produce json
+// @Security CookieAuth
+// @Param request body TotpCodeRequest true "Current TOTP code"
+// @Success 200 {object} RecoveryCodesResponse "Two-factor authentication enabled"
+// @Failure 400 {object} api.ErrorResponse "No pending enrollment or already enabled"
+// @Failure 401 {object} api.ErrorResponse "Unauthorized or invalid code"
+// @Failure 429 {object} api.ErrorResponse "Rate limit exceeded (10 requests per minute)"
+// @Failure 500 {object} api.ErrorResponse "Internal server error"
+// @Router /auth/mfa/totp/confirm [post]
+func (ar *AuthRouter) HandleTotpConfirm(w http.ResponseWriter, r *http.Request) {
+	applog.Info("HandleTotpConfirm called")
+	req, err := api.DecodeJSON[TotpCodeRequest](w, r)
+	if err != nil {
+		applog.Error("Failed to decode totp confirm request:", err)
+		return
+	}
+
+	user, ok := utils.UserFromContext(r.Context())
+	if !ok {
+		applog.Error("Failed to get user from context")
+		api.WriteInternalError(w)
+		return
+	}
+
+	if user.TotpEnabled {
+		api.WriteMessage(w, 400, "error", "two-factor authentication already enabled")
+		return
+	}
+
+	if user.TotpSecret == "" {
+		api.WriteMessage(w, 400, "error", "two-factor setup not started")
+		return
+	}
+
+	step, valid := utils.ValidateTOTP(user.TotpSecret, req.Code, time.Now())
+	if !valid {
+		applog.Warn("Invalid totp code during enrollment", "userID:", user.ID)
+		api.WriteInvalidCredentials(w)
+		return
+	}
+
+	if err := ar.UserRepo.EnableTotp(r.Context(), user.ID, step); err != nil {
+		applog.Error("Failed to enable totp:", err)
+		api.WriteInternalError(w)
+		return
+	}
+
+	codes, err := ar.issueRecoveryCodes(r.Context(), user.ID)
+	if err != nil {
+		applog.Error("Failed to issue recovery codes:", err)
+		api.WriteInternalError(w)
+		return
+	}
+
+	applog.Info("TOTP enabled", "userID:", user.ID)
+	api.WriteJSON(w, 200, RecoveryCodesResponse{RecoveryCodes: codes})
+}
+
+// @Summary Disable two-factor authentication
+// @Description Disable TOTP for the current user. Requires the account password and either a current TOTP code or a recovery code. All recovery codes are deleted.
+// @Tags Account
+// @Accept json
+// @Produce json
+// @Security CookieAuth
+// @Param request body TotpDisableRequest true "Password and second factor"
+// @Success 200 {object} api.SuccessResponse "Two-factor authentication disabled"
+// @Failure 400 {object} api.ErrorResponse "Two-factor authentication is not enabled"
+// @Failure 401 {object} api.ErrorResponse "Unauthorized, wrong password or invalid code"
+// @Failure 423 {object} api.ErrorResponse "Account locked"
+// @Failure 429 {object} api.ErrorResponse "Rate limit exceeded (10 requests per minute)"
+// @Failure 500 {object} api.ErrorResponse "Internal server error"
+// @Router /auth/mfa/totp/disable [post]
+func (ar *AuthRouter) HandleTotpDisable(w http.ResponseWriter, r *http.Request) {
+	ip := utils.GetClientIP(r)
+	applog.Info("HandleTotpDisable called", "remoteAddr:", ip)
+	req, err := api.DecodeJSON[TotpDisableRequest](w, r)
+	if err != nil {
+		applog.Error("Failed to decode totp disable request:", err)
+		return
+	}
+
+	user, ok := utils.UserFromContext(r.Context())
+	if !ok {
+		applog.Error("Failed to get user from context")
+		api.WriteInternalError(w)
+		return
+	}
+
+	if !user.TotpEnabled {
+		api.WriteMessage(w, 400, "error", "two-factor authentication not enabled")
+		return
+	}
+
+	lockedOut, err := ar.LockoutRepo.IsLockedOut(r.Context(), user.ID, utils.IPPrefix(ip))
+	if err != nil {
+		applog.Error("Error checking lockout:", err)
+		api.WriteInternalError(w)
+		return
+	}
+
+	if lockedOut {
+		applog.Warn("Account locked out", "userID:", user.ID, "ip:", ip)
+		api.WriteMessage(w, 423, "error", "account locked")
+		return
+	}
+
+	if !utils.ComparePassword(user.PasswordHash, req.Password) {
+		applog.Warn("Incorrect password when disabling totp", "userID:", user.ID)
+		ar.registerFailedLogin(r.Context(), w, user, ip)
+		return
+	}
+
+	valid, err := ar.verifySecondFactor(r.Context(), user, req.Code, req.RecoveryCode)
+	if err != nil {
+		applog.Error("Failed to verify second factor:", err)
+		api.WriteInternalError(w)
+		return
+	}
+
+	if !valid {
+		applog.Warn("Invalid second factor when disabling totp", "userID:", user.ID)
+		ar.registerFailedLogin(r.Context(), w, user, ip)
+		return
+	}
+
+	if err := ar.UserRepo.DisableTotp(r.Context(), user.ID); err != nil {
+		applog.Error("Failed to disable totp:", err)
+		api.WriteInternalError(w)
+		return
+	}
+
+	if err := ar.MfaRepo.DeleteRecoveryCodes(r.Context(), user.ID); err != nil {
+		applog.Error("Failed to delete recovery codes:", err)
+		api.WriteInternalError(w)
+		return
+	}
+
+	applog.Info("TOTP disabled", "userID:", user.ID)
+	api.WriteMessage(w, 200, "message", "two-factor authentication disabled")
+}
+
+// @Summary Regenerate recovery codes
+// @Description Replace all recovery codes with a new set. Requires a current TOTP code. Previous codes stop working immediately.
+// @Tags Account
+// @Accept json
+// @Produce json
+// @Security CookieAuth
+// @Param request body TotpCodeRequest true "Current TOTP code"
+// @Success 200 {object} RecoveryCodesResponse "New recovery codes"
+// @Failure 400 {object} api.ErrorResponse "Two-factor authentication is not enabled"
+// @Failure 401 {object} api.ErrorResponse "Unauthorized or invalid code"
+// @Failure 423 {object} api.ErrorResponse "Account locked"
+// @Failure 429 {object} api.ErrorResponse "Rate limit exceeded (10 requests per minute)"
+// @Failure 500 {object} api.ErrorResponse "Internal server error"
+// @Router /auth/mfa/recovery-codes [post]
+func (ar *AuthRouter) HandleRegenerateRecoveryCodes(w http.ResponseWriter, r *http.Request) {
+	ip := utils.GetClientIP(r)
+	applog.Info("HandleRegenerateRecoveryCodes called", "remoteAddr:", ip)
+	req, err := api.DecodeJSON[TotpCodeRequest](w, r)
+	if err != nil {
+		applog.Error("Failed to decode recovery codes request:", err)
+		return
+	}
+
+	user, ok := utils.UserFromContext(r.Context())
+	if !ok {
+		applog.Error("Failed to get user from context")
+		api.WriteInternalError(w)
+		return
+	}
+
+	if !user.TotpEnabled {
+		api.WriteMessage(w, 400, "error", "two-factor authentication not enabled")
+		return
+	}
+
+	lockedOut, err := ar.LockoutRepo.IsLockedOut(r.Context(), user.ID, utils.IPPrefix(ip))
+	if err != nil {
+		applog.Error("Error checking lockout:", err)
+		api.WriteInternalError(w)
+		return
+	}
+
+	if lockedOut {
+		applog.Warn("Account locked out", "userID:", user.ID, "ip:", ip)
+		api.WriteMessage(w, 423, "error", "account locked")
+		return
+	}
+
+	valid, err := ar.verifySecondFactor(r.Context(), user, req.Code, "")
+	if err != nil {
+		applog.Error("Failed to verify totp code:", err)
+		api.WriteInternalError(w)
+		return
+	}
+
+	if !valid {
+		applog.Warn("Invalid totp code for recovery code regeneration", "userID:", user.ID)
+		ar.registerFailedLogin(r.Context(), w, user, ip)
+		return
+	}
+
+	codes, err := ar.issueRecoveryCodes(r.Context(), user.ID)
+	if err != nil {
+		applog.Error("Failed to issue recovery codes:", err)
+		api.WriteInternalError(w)
+		return
+	}
+
+	applog.Info("Recovery codes regenerated", "userID:", user.ID)
+	api.WriteJSON(w, 200, RecoveryCodesResponse{RecoveryCodes: codes})
+}
@@ -1,5 +1,7 @@
 package auth
 
+import "github.com/akramboussanni/treenode/internal/model"
+
 // @Description User registration request with email confirmation
 type RegisterRequest struct {
 	Username string `json:"username" example:"johndoe" binding:"required" minLength:"3" maxLength:"30" pattern:"^[a-zA-Z0-9_-]+$"`
@@ -37,3 +39,109 @@ type PasswordChangeRequest struct {
 	OldPassword string `json:"old_password" example:"SecurePass123!" binding:"required" description:"Current password for verification"`
 	NewPassword string `json:"new_password" example:"NewSecurePass123!" binding:"required" minLength:"8" description:"New password that meets security requirements"`
 }
+
+// @Description Request to move the account to a new email address
+type EmailChangeRequest struct {
+	NewEmail  string `json:"new_email" example:"new@example.com" binding:"required" format:"email"`
+	Password  string `json:"password" example:"SecurePass123!" description:"Current password, not needed for accounts that only sign in with OpenID Connect"`
+	Url       string `json:"url" example:"https://example.com/confirm-email-change" binding:"required" format:"uri" description:"Page the new address confirms from"`
+	CancelUrl string `json:"cancel_url" example:"https://example.com/cancel-email-change" binding:"required" format:"uri" description:"Page the current address can cancel from"`
+}
+
+// @Description Returned by login when the account has two-factor authentication enabled
+type MfaChallengeResponse struct {
+	MfaRequired bool   `json:"mfa_required" example:"true"`
+	MfaToken    string `json:"mfa_token" example:"eyJhbGciOiJIUzI1NiIsInR5cCI6IkpXVCJ9..." description:"Short-lived token to exchange along with a TOTP or recovery code"`
+}
+
+// @Description Second login step for accounts with two-factor authentication
+type MfaLoginRequest struct {
+	MfaToken     string `json:"mfa_token" binding:"required" description:"Token returned by /auth/login"`
+	Code         string `json:"code" example:"123456" description:"6-digit code from the authenticator app"`
+	RecoveryCode string `json:"recovery_code" example:"a1b2c-3d4e5" description:"One-time recovery code, used instead of code"`
+}
+
+// @Description TOTP enrollment details to load into an authenticator app
+type TotpSetupResponse struct {
+	Secret     string `json:"secret" example:"JBSWY3DPEHPK3PXP" description:"Base32 encoded shared secret"`
+	OtpauthURI string `json:"otpauth_uri" example:"otpauth://totp/Treenode:john%40example.com?secret=JBSWY3DPEHPK3PXP&issuer=Treenode"`
+}
+
+// @Description TOTP code confirmation
+type TotpCodeRequest struct {
+	Code string `json:"code" example:"123456" binding:"required"`
+}
+
+// @Description Disabling two-factor authentication requires the password and a current code
+type TotpDisableRequest struct {
+	Password     string `json:"password" example:"SecurePass123!" binding:"required"`
+	Code         string `json:"code" example:"123456"`
+	RecoveryCode string `json:"recovery_code" example:"a1b2c-3d4e5"`
+}
+
+// @Description Freshly generated recovery codes, shown only once
+type RecoveryCodesResponse struct {
+	RecoveryCodes []string `json:"recovery_codes" example:"a1b2c-3d4e5,f6a7b-8c9d0"`
+}
+
+// @Description A device session of the current user
+type SessionResponse struct {
+	model.Session
+	Current bool `json:"current" example:"true" description:"Whether this is the session making the request"`
+}
+
+// @Description Request to create a personal access token for scripted API access
+type CreateApiTokenRequest struct {
+	Name      string   `json:"name" example:"deploy script" binding:"required" maxLength:"100"`
+	Scopes    []string `json:"scopes" example:"nodes:read,links:write" binding:"required" description:"Any of nodes:read, links:write, collaborators:manage"`
+	ExpiresIn int64    `json:"expires_in" example:"2592000" description:"Lifetime in seconds, 0 for a token that never expires"`
+}
+
+// @Description Newly created personal access token. The raw token is only ever returned here.
+type CreateApiTokenResponse struct {
+	model.ApiToken
+	Token string `json:"token" example:"tn_q2Xw9mVb0c1JrY8cT1jzQmRkR0nE5pYxkS7yVb3nLfQ=" description:"Send as Authorization: Bearer <token>"`
+}
+
+// @Description OpenID Connect provider available for login
+type OidcProviderResponse struct {
+	Name        string `json:"name" example:"google" description:"Identifier used in /auth/oidc/{provider} routes"`
+	DisplayName string `json:"display_name" example:"Google"`
+}
+
+// @Description Provider authorization URL to send the browser to
+type OidcAuthorizationResponse struct {
+	AuthorizationURL string `json:"authorization_url" example:"https://accounts.example.com/authorize?response_type=code&client_id=treenode"`
+}
+
+// @Description Request to delete the current account once the grace period ends
+type DeleteAccountRequest struct {
+	Password  string               `json:"password" example:"SecurePass123!" description:"Current password, not needed for accounts that only sign in with OpenID Connect"`
+	Transfers []model.NodeTransfer `json:"transfers" description:"Owned nodes to hand to one of their collaborators, every other owned node is deleted"`
+}
+
+// @Description Scheduled account deletion
+type DeleteAccountResponse struct {
+	DeletionScheduledAt int64                `json:"deletion_scheduled_at,string" example:"1641600000" description:"When the account and its data are purged, restore before then to cancel"`
+	Transfers           []model.NodeTransfer `json:"transfers"`
+}
+
+// @Description A node in a personal data export, with its links and color stops
+type ExportedNode struct {
+	Node        model.Node          `json:"node"`
+	Links       []model.Link        `json:"links"`
+	Invitations []*model.Invitation `json:"invitations,omitempty" description:"Pending and accepted invitations, only for owned nodes"`
+}
+
+// @Description Everything treenode stores about the current user
+type AccountExport struct {
+	ExportedAt   int64               `json:"exported_at,string" example:"1640995200"`
+	Profile      *model.User         `json:"profile"`
+	Identities   []model.Identity    `json:"identities"`
+	ApiTokens    []model.ApiToken    `json:"api_tokens"`
+	Sessions     []model.Session     `json:"sessions" description:"Login history, one entry per device session"`
+	FailedLogins []model.FailedLogin `json:"failed_logins"`
+	OwnedNodes   []ExportedNode      `json:"owned_nodes"`
+	SharedNodes  []ExportedNode      `json:"shared_nodes"`
+	Invitations  []*model.Invitation `json:"invitations" description:"Invitations addressed to the user"`
+}
@@ -0,0 +1,540 @@
+package auth
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/akramboussanni/treenode/config"
+	"github.com/akramboussanni/treenode/internal/api"
+	"github.com/akramboussanni/treenode/internal/applog"
+	"github.com/akramboussanni/treenode/internal/jwt"
+	"github.com/akramboussanni/treenode/internal/model"
+	"github.com/akramboussanni/treenode/internal/oidc"
+	"github.com/akramboussanni/treenode/internal/utils"
+	"github.com/go-chi/chi/v5"
+)
+
+var usernameInvalidChars = regexp.MustCompile(`[^a-zA-Z0-9_-]`)
+
+func hashOidcState(state string) string {
+	sum := sha256.Sum256([]byte(state))
+	return base64.URLEncoding.EncodeToString(sum[:])
+}
+
+// beginOidc stores a fresh state, nonce and PKCE verifier and returns the provider's
+// authorization URL. on failure it writes the error response.
+func (ar *AuthRouter) beginOidc(w http.ResponseWriter, r *http.Request, provider *oidc.Provider, linkUserID int64) (string, bool) {
+	state, err := oidc.RandomString(32)
+	if err != nil {
+		applog.Error("Failed to generate oidc state:", err)
+		api.WriteInternalError(w)
+		return "", false
+	}
+
+	nonce, err := oidc.RandomString(32)
+	if err != nil {
+		applog.Error("Failed to generate oidc nonce:", err)
+		api.WriteInternalError(w)
+		return "", false
+	}
+
+	verifier, err := oidc.RandomString(48)
+	if err != nil {
+		applog.Error("Failed to generate pkce verifier:", err)
+		api.WriteInternalError(w)
+		return "", false
+	}
+
+	authURL, err := provider.AuthCodeURL(r.Context(), state, nonce, verifier)
+	if err != nil {
+		applog.Error("Failed to build authorization url:", err, "provider:", provider.Name)
+		api.WriteMessage(w, 502, "error", "identity provider unavailable")
+		return "", false
+	}
+
+	now := time.Now().UTC().Unix()
+	err = ar.IdentityRepo.CreateState(r.Context(), &model.OidcState{
+		StateHash:    hashOidcState(state),
+		Provider:     provider.Name,
+		CodeVerifier: verifier,
+		Nonce:        nonce,
+		LinkUserID:   linkUserID,
+		ExpiresAt:    now + config.App.OidcStateDuration,
+	}, now)
+	if err != nil {
+		applog.Error("Failed to store oidc state:", err)
+		api.WriteInternalError(w)
+		return "", false
+	}
+
+	// ties the callback to the browser that started the flow
+	utils.SetOidcStateCookie(w, state)
+	return authURL, true
+}
+
+// finishOidc sends the browser back to the frontend, or answers with JSON when no
+// frontend url is configured
+func finishOidc(w http.ResponseWriter, r *http.Request, errCode string) {
+	target := config.App.OidcFrontendURL
+	if target == "" {
+		if errCode != "" {
+			api.WriteMessage(w, 400, "error", errCode)
+			return
+		}
+		api.WriteMessage(w, 200, "message", "login successful")
+		return
+	}
+
+	if errCode != "" {
+		u, err := url.Parse(target)
+		if err == nil {
+			q := u.Query()
+			q.Set("oidc_error", errCode)
+			u.RawQuery = q.Encode()
+			target = u.String()
+		}
+	}
+
+	http.Redirect(w, r, target, http.StatusFound)
+}
+
+// usernameFromClaims derives an available username from the provider's claims
+func (ar *AuthRouter) usernameFromClaims(r *http.Request, claims *oidc.Claims) (string, error) {
+	base := claims.PreferredUsername
+	if base == "" {
+		base = strings.SplitN(claims.Email, "@", 2)[0]
+	}
+
+	base = usernameInvalidChars.ReplaceAllString(base, "")
+	if len(base) > 24 {
+		base = base[:24]
+	}
+	if len(base) < 3 {
+		base = "user" + base
+	}
+
+	candidate := base
+	for i := 0; i < 5; i++ {
+		duplicate, err := ar.UserRepo.DuplicateName(r.Context(), candidate)
+		if err != nil {
+			return "", err
+		}
+		if !duplicate {
+			return candidate, nil
+		}
+
+		suffix, err := utils.GenerateRandomBytes(3)
+		if err != nil {
+			return "", err
+		}
+		candidate = base + "-" + hex.EncodeToString(suffix)
+	}
+
+	return "", errors.New("no available username")
+}
+
+// @Summary List login providers
+// @Description List the OpenID Connect providers that can be used to log in or be linked to an account.
+// @Tags Authentication
+// @Accept json
+// @Produce json
+// @Success 200 {array} OidcProviderResponse "Configured providers"
+// @Failure 429 {object} api.ErrorResponse "Rate limit exceeded (30 requests per minute)"
+// @Router /auth/oidc/providers [get]
+func (ar *AuthRouter) HandleListOidcProviders(w http.ResponseWriter, r *http.Request) {
+	providers := oidc.Providers()
+	result := make([]OidcProviderResponse, 0, len(providers))
+	for _, p := range providers {
+		result = append(result, OidcProviderResponse{Name: p.Name, DisplayName: p.Config.DisplayName})
+	}
+
+	api.WriteJSON(w, 200, result)
+}
+
+// @Summary Start an OpenID Connect login
+// @Description Redirect the browser to the provider using the authorization code flow with PKCE. The provider sends it back to /auth/oidc/{provider}/callback.
+// @Tags Authentication
+// @Param provider path string true "Provider name"
+// @Success 302 "Redirect to the identity provider"
+// @Failure 404 {object} api.ErrorResponse "Unknown provider"
+// @Failure 429 {object} api.ErrorResponse "Rate limit exceeded (30 requests per minute)"
+// @Failure 500 {object} api.ErrorResponse "Internal server error"
+// @Failure 502 {object} api.ErrorResponse "Identity provider unavailable"
+// @Router /auth/oidc/{provider}/login [get]
+func (ar *AuthRouter) HandleOidcLogin(w http.ResponseWriter, r *http.Request) {
+	applog.Info("HandleOidcLogin called", "remoteAddr:", utils.GetClientIP(r))
+	provider, err := oidc.GetProvider(chi.URLParam(r, "provider"))
+	if err != nil {
+		api.WriteMessage(w, 404, "error", "unknown provider")
+		return
+	}
+
+	authURL, ok := ar.beginOidc(w, r, provider, 0)
+	if !ok {
+		return
+	}
+
+	http.Redirect(w, r, authURL, http.StatusFound)
+}
+
+// @Summary Link an OpenID Connect identity
+// @Description Start the authorization flow to attach a provider identity to the current account. Send the browser to the returned URL.
+// @Tags Account
+// @Accept json
+// @Produce json
+// @Security CookieAuth
+// @Param provider path string true "Provider name"
+// @Success 200 {object} OidcAuthorizationResponse "Authorization URL"
+// @Failure 401 {object} api.ErrorResponse "Unauthorized - invalid or missing session cookie"
+// @Failure 404 {object} api.ErrorResponse "Unknown provider"
+// @Failure 429 {object} api.ErrorResponse "Rate limit exceeded (10 requests per minute)"
+// @Failure 500 {object} api.ErrorResponse "Internal server error"
+// @Failure 502 {object} api.ErrorResponse "Identity provider unavailable"
+// @Router /auth/oidc/{provider}/link [post]
+func (ar *AuthRouter) HandleOidcLink(w http.ResponseWriter, r *http.Request) {
+	applog.Info("HandleOidcLink called")
+	user, ok := utils.UserFromContext(r.Context())
+	if !ok {
+		applog.Error("Failed to get user from context")
+		api.WriteInternalError(w)
+		return
+	}
+
+	provider, err := oidc.GetProvider(chi.URLParam(r, "provider"))
+	if err != nil {
+		api.WriteMessage(w, 404, "error", "unknown provider")
+		return
+	}
+
+	authURL, ok := ar.beginOidc(w, r, provider, user.ID)
+	if !ok {
+		return
+	}
+
+	api.WriteJSON(w, 200, OidcAuthorizationResponse{AuthorizationURL: authURL})
+}
+
+// @Summary OpenID Connect callback
+// @Description Redirect target for the provider. Logs the user in, linking to an existing account by verified email or creating a new one, or finishes linking an identity. The browser is then sent to OIDC_FRONTEND_URL, with an oidc_error query parameter on failure or an mfa_token fragment when a second factor is required.
+// @Tags Authentication
+// @Param provider path string true "Provider name"
+// @Param code query string true "Authorization code"
+// @Param state query string true "State issued when the flow started"
+// @Success 302 "Redirect to the frontend"
+// @Failure 404 {object} api.ErrorResponse "Unknown provider"
+// @Failure 429 {object} api.ErrorResponse "Rate limit exceeded (30 requests per minute)"
+// @Router /auth/oidc/{provider}/callback [get]
+func (ar *AuthRouter) HandleOidcCallback(w http.ResponseWriter, r *http.Request) {
+	ip := utils.GetClientIP(r)
+	applog.Info("HandleOidcCallback called", "remoteAddr:", ip)
+	provider, err := oidc.GetProvider(chi.URLParam(r, "provider"))
+	if err != nil {
+		api.WriteMessage(w, 404, "error", "unknown provider")
+		return
+	}
+
+	query := r.URL.Query()
+	if e := query.Get("error"); e != "" {
+		applog.Warn("Identity provider returned an error", "provider:", provider.Name, "error:", e)
+		utils.ClearOidcStateCookie(w)
+		finishOidc(w, r, "access_denied")
+		return
+	}
+
+	stateCookie, err := r.Cookie("oidc_state")
+	state := query.Get("state")
+	if err != nil || state == "" || subtle.ConstantTimeCompare([]byte(stateCookie.Value), []byte(state)) != 1 {
+		applog.Warn("Oidc state mismatch", "provider:", provider.Name, "ip:", ip)
+		finishOidc(w, r, "invalid_state")
+		return
+	}
+	utils.ClearOidcStateCookie(w)
+
+	pending, err := ar.IdentityRepo.ConsumeState(r.Context(), hashOidcState(state))
+	if err != nil || pending.Provider != provider.Name || pending.ExpiresAt < time.Now().UTC().Unix() {
+		applog.Warn("Oidc state unknown or expired", "provider:", provider.Name, "ip:", ip)
+		finishOidc(w, r, "invalid_state")
+		return
+	}
+
+	claims, err := provider.Exchange(r.Context(), query.Get("code"), pending.CodeVerifier, pending.Nonce)
+	if err != nil {
+		applog.Warn("Oidc code exchange failed", "provider:", provider.Name, "err:", err)
+		finishOidc(w, r, "exchange_failed")
+		return
+	}
+
+	if pending.LinkUserID != 0 {
+		ar.linkOidcIdentity(w, r, provider, claims, pending.LinkUserID)
+		return
+	}
+
+	ar.loginOidcIdentity(w, r, provider, claims)
+}
+
+func (ar *AuthRouter) linkOidcIdentity(w http.ResponseWriter, r *http.Request, provider *oidc.Provider, claims *oidc.Claims, userID int64) {
+	existing, err := ar.IdentityRepo.GetIdentity(r.Context(), provider.Name, claims.Subject)
+	if err == nil {
+		if existing.UserID != userID {
+			applog.Warn("Identity already linked to another user", "provider:", provider.Name, "userID:", userID)
+			finishOidc(w, r, "identity_in_use")
+			return
+		}
+		finishOidc(w, r, "")
+		return
+	}
+
+	if !errors.Is(err, sql.ErrNoRows) {
+		applog.Error("Failed to look up identity:", err)
+		finishOidc(w, r, "server_error")
+		return
+	}
+
+	err = ar.IdentityRepo.CreateIdentity(r.Context(), &model.Identity{
+		ID:        utils.GenerateSnowflakeID(),
+		UserID:    userID,
+		Provider:  provider.Name,
+		Subject:   claims.Subject,
+		Email:     claims.Email,
+		CreatedAt: time.Now().UTC().Unix(),
+	})
+	if err != nil {
+		applog.Error("Failed to link identity:", err)
+		finishOidc(w, r, "server_error")
+		return
+	}
+
+	applog.Info("Identity linked", "userID:", userID, "provider:", provider.Name)
+	finishOidc(w, r, "")
+}
+
+func (ar *AuthRouter) loginOidcIdentity(w http.ResponseWriter, r *http.Request, provider *oidc.Provider, claims *oidc.Claims) {
+	var user *model.User
+	identity, err := ar.IdentityRepo.GetIdentity(r.Context(), provider.Name, claims.Subject)
+	switch {
+	case err == nil:
+		user, err = ar.UserRepo.GetUserByID(r.Context(), identity.UserID)
+		if err != nil {
+			applog.Error("Failed to load user for identity:", err)
+			finishOidc(w, r, "server_error")
+			return
+		}
+
+	case errors.Is(err, sql.ErrNoRows):
+		// unknown identity, match on a verified email or sign the user up
+		if claims.Email == "" || !bool(claims.EmailVerified) || !utils.IsValidEmail(claims.Email) {
+			applog.Warn("Oidc login without a verified email", "provider:", provider.Name)
+			finishOidc(w, r, "email_not_verified")
+			return
+		}
+
+		user, err = ar.userForOidcEmail(r, provider, claims)
+		if err != nil {
+			applog.Error("Failed to resolve oidc user:", err)
+			finishOidc(w, r, "server_error")
+			return
+		}
+
+	default:
+		applog.Error("Failed to look up identity:", err)
+		finishOidc(w, r, "server_error")
+		return
+	}
+
+	if !user.IsActive() {
+		applog.Warn("Oidc login on disabled account", "userID:", user.ID)
+		finishOidc(w, r, "account_disabled")
+		return
+	}
+
+	if user.TotpEnabled {
+		mfaToken := jwt.CreateJwtFromUser(user, user.JwtSessionID).WithType(model.MfaPendingJwt).GenerateToken()
+		applog.Info("Oidc login accepted, awaiting second factor", "userID:", user.ID)
+		if config.App.OidcFrontendURL == "" {
+			api.WriteJSON(w, 200, MfaChallengeResponse{MfaRequired: true, MfaToken: mfaToken})
+			return
+		}
+		http.Redirect(w, r, config.App.OidcFrontendURL+"#mfa_token="+url.QueryEscape(mfaToken), http.StatusFound)
+		return
+	}
+
+	if !ar.startSession(w, r, user) {
+		return
+	}
+
+	finishOidc(w, r, "")
+}
+
+// userForOidcEmail links the identity to the account owning the verified email, or
+// creates a confirmed account for it
+func (ar *AuthRouter) userForOidcEmail(r *http.Request, provider *oidc.Provider, claims *oidc.Claims) (*model.User, error) {
+	now := time.Now().UTC().Unix()
+	identity := &model.Identity{
+		ID:        utils.GenerateSnowflakeID(),
+		Provider:  provider.Name,
+		Subject:   claims.Subject,
+		Email:     claims.Email,
+		CreatedAt: now,
+	}
+
+	user, err := ar.UserRepo.GetUserByEmail(r.Context(), claims.Email)
+	if err == nil {
+		identity.UserID = user.ID
+		if err := ar.IdentityRepo.CreateIdentity(r.Context(), identity); err != nil {
+			return nil, err
+		}
+
+		// the provider just proved ownership of the address, anything set up on the
+		// unconfirmed account came from whoever registered it
+		if !user.EmailConfirmed {
+			if err := ar.claimUnconfirmedUser(r, user); err != nil {
+				return nil, err
+			}
+		}
+
+		applog.Info("Identity linked by verified email", "userID:", user.ID, "provider:", provider.Name)
+		return user, nil
+	}
+
+	if !errors.Is(err, sql.ErrNoRows) {
+		return nil, err
+	}
+
+	username, err := ar.usernameFromClaims(r, claims)
+	if err != nil {
+		return nil, err
+	}
+
+	// no password is set, the user can add one through the forgot password flow
+	user = &model.User{ID: utils.GenerateSnowflakeID(), Username: username, Email: claims.Email, CreatedAt: now, Role: model.RoleUser, Status: model.StatusActive, EmailConfirmed: true}
+	identity.UserID = user.ID
+	if err := ar.IdentityRepo.CreateUserWithIdentity(r.Context(), user, identity); err != nil {
+		return nil, err
+	}
+
+	applog.Info("User registered through oidc", "userID:", user.ID, "provider:", provider.Name)
+	return user, nil
+}
+
+// claimUnconfirmedUser confirms the account for the owner of its address, clearing the
+// password and anything else the registrant set up, and updates user to match
+func (ar *AuthRouter) claimUnconfirmedUser(r *http.Request, user *model.User) error {
+	sessionID := utils.GenerateSnowflakeID()
+	claimed, err := ar.UserRepo.ClaimUnconfirmedUser(r.Context(), user.ID, sessionID)
+	if err != nil {
+		return err
+	}
+
+	if claimed {
+		applog.Warn("Unconfirmed account claimed by the owner of its address, password and sessions cleared", "userID:", user.ID)
+		user.PasswordHash = ""
+		user.JwtSessionID = sessionID
+		user.TotpEnabled = false
+		user.TotpSecret = ""
+		user.PendingEmail = ""
+	}
+	user.EmailConfirmed = true
+	return nil
+}
+
+// @Summary List linked identities
+// @Description List the OpenID Connect identities linked to the current account.
+// @Tags Account
+// @Accept json
+// @Produce json
+// @Security CookieAuth
+// @Success 200 {array} model.Identity "Linked identities"
+// @Failure 401 {object} api.ErrorResponse "Unauthorized - invalid or missing session cookie"
+// @Failure 429 {object} api.ErrorResponse "Rate limit exceeded (10 requests per minute)"
+// @Failure 500 {object} api.ErrorResponse "Internal server error"
+// @Router /auth/identities [get]
+func (ar *AuthRouter) HandleListIdentities(w http.ResponseWriter, r *http.Request) {
+	applog.Info("HandleListIdentities called")
+	user, ok := utils.UserFromContext(r.Context())
+	if !ok {
+		applog.Error("Failed to get user from context")
+		api.WriteInternalError(w)
+		return
+	}
+
+	identities, err := ar.IdentityRepo.GetIdentitiesByUserID(r.Context(), user.ID)
+	if err != nil {
+		applog.Error("Failed to list identities:", err)
+		api.WriteInternalError(w)
+		return
+	}
+
+	if identities == nil {
+		identities = []model.Identity{}
+	}
+
+	api.WriteJSON(w, 200, identities)
+}
+
+// @Summary Unlink an identity
+// @Description Remove a linked OpenID Connect identity. The last identity of an account without a password can't be removed.
+// @Tags Account
+// @Accept json
+// @Produce json
+// @Security CookieAuth
+// @Param identityID path string true "Identity ID"
+// @Success 200 {object} api.SuccessResponse "Identity unlinked"
+// @Failure 400 {object} api.ErrorResponse "Invalid identity ID or last sign-in method"
+// @Failure 401 {object} api.ErrorResponse "Unauthorized - invalid or missing session cookie"
+// @Failure 404 {object} api.ErrorResponse "Identity not found"
+// @Failure 429 {object} api.ErrorResponse "Rate limit exceeded (10 requests per minute)"
+// @Failure 500 {object} api.ErrorResponse "Internal server error"
+// @Router /auth/identities/{identityID} [delete]
+func (ar *AuthRouter) HandleUnlinkIdentity(w http.ResponseWriter, r *http.Request) {
+	applog.Info("HandleUnlinkIdentity called")
+	identityID, err := utils.ParseID(chi.URLParam(r, "identityID"))
+	if err != nil {
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return
+	}
+
+	user, ok := utils.UserFromContext(r.Context())
+	if !ok {
+		applog.Error("Failed to get user from context")
+		api.WriteInternalError(w)
+		return
+	}
+
+	if user.PasswordHash == "" {
+		count, err := ar.IdentityRepo.CountIdentities(r.Context(), user.ID)
+		if err != nil {
+			applog.Error("Failed to count identities:", err)
+			api.WriteInternalError(w)
+			return
+		}
+
+		if count <= 1 {
+			api.WriteMessage(w, 400, "error", "set a password before removing your last sign-in method")
+			return
+		}
+	}
+
+	deleted, err := ar.IdentityRepo.DeleteIdentity(r.Context(), identityID, user.ID)
+	if err != nil {
+		applog.Error("Failed to unlink identity:", err)
+		api.WriteInternalError(w)
+		return
+	}
+
+	if !deleted {
+		api.WriteMessage(w, 404, "error", "identity not found")
+		return
+	}
+
+	applog.Info("Identity unlinked", "userID:", user.ID, "identityID:", identityID)
+	api.WriteMessage(w, 200, "message", "identity unlinked")
+}
@@ -11,13 +11,33 @@ import (
 	"github.com/akramboussanni/treenode/config"
 	"github.com/akramboussanni/treenode/internal/api"
 	"github.com/akramboussanni/treenode/internal/applog"
+	"github.com/akramboussanni/treenode/internal/breach"
 	"github.com/akramboussanni/treenode/internal/model"
 	"github.com/akramboussanni/treenode/internal/utils"
 )
 
+// checkBreachedPassword rejects passwords found in the breach corpus. on failure it writes
+// the error response and returns false.
+func checkBreachedPassword(w http.ResponseWriter, password string) bool {
+	breached, err := breach.IsBreached(password)
+	if err != nil {
+		applog.Error("Failed to check breached passwords:", err)
+		api.WriteInternalError(w)
+		return false
+	}
+
+	if breached {
+		applog.Warn("Rejected a password found in a data breach")
+		api.WriteMessage(w, 400, "error", "password found in a data breach, please choose another")
+		return false
+	}
+
+	return true
+}
+
 // shared helper for password change logic
 func (ar *AuthRouter) changeUserPassword(ctx context.Context, w http.ResponseWriter, user *model.User, newPassword, ip string) bool {
-	if !utils.IsValidPassword(newPassword) {
+	if !utils.MeetsPasswordPolicy(newPassword) {
 		applog.Warn("Invalid new password format", "userID:", user.ID)
 		api.WriteMessage(w, 400, "error", "invalid password")
 		return false
@@ -27,6 +47,9 @@ func (ar *AuthRouter) changeUserPassword(ctx context.Context, w http.ResponseWri
 		api.WriteMessage(w, 400, "error", "same password")
 		return false
 	}
+	if !checkBreachedPassword(w, newPassword) {
+		return false
+	}
 	hash, err := utils.HashPassword(newPassword)
 	if err != nil {
 		applog.Error("Failed to hash new password:", err)
@@ -43,7 +66,7 @@ func (ar *AuthRouter) changeUserPassword(ctx context.Context, w http.ResponseWri
 		api.WriteInternalError(w)
 		return false
 	}
-	if err := ar.LockoutRepo.UnlockAccount(ctx, user.ID, ip); err != nil {
+	if err := ar.LockoutRepo.UnlockAccount(ctx, user.ID, utils.IPPrefix(ip)); err != nil {
 		applog.Error("Failed to revoke all sessions:", err)
 		api.WriteInternalError(w)
 		return false
@@ -60,7 +83,7 @@ func (ar *AuthRouter) changeUserPassword(ctx context.Context, w http.ResponseWri
 // @Param X-Recaptcha-Token header string false "reCAPTCHA verification token (optional if reCAPTCHA is not configured)"
 // @Param request body PasswordResetRequest true "Reset token and new password"
 // @Success 200 {string} string "Password reset successful"
-// @Failure 400 {object} api.ErrorResponse "Invalid password format or requirements not met"
+// @Failure 400 {object} api.ErrorResponse "Invalid password format, requirements not met, or password found in a data breach"
 // @Failure 401 {object} api.ErrorResponse "Invalid or expired reset token"
 // @Failure 429 {object} api.ErrorResponse "Rate limit exceeded (5 requests per minute)"
 // @Failure 500 {object} api.ErrorResponse "Internal server error"
@@ -156,7 +179,7 @@ func (ar *AuthRouter) HandleSendForgotPassword(w http.ResponseWriter, r *http.Re
 // @Security CookieAuth
 // @Param request body PasswordChangeRequest true "Current password and new password"
 // @Success 200 {string} string "Password changed successfully"
-// @Failure 400 {object} api.ErrorResponse "Invalid password format or requirements not met"
+// @Failure 400 {object} api.ErrorResponse "Invalid password format, requirements not met, or password found in a data breach"
 // @Failure 401 {object} api.ErrorResponse "Unauthorized or incorrect current password"
 // @Failure 429 {object} api.ErrorResponse "Rate limit exceeded (5 requests per minute)"
 // @Failure 500 {object} api.ErrorResponse "Internal server error"
@@ -19,7 +19,7 @@ import (
 // @Param X-Recaptcha-Token header string false "reCAPTCHA verification token (optional if reCAPTCHA is not configured)"
 // @Param request body RegisterRequest true "User registration credentials including confirmation URL"
 // @Success 200 {object} api.SuccessResponse "User account created successfully - confirmation email sent"
-// @Failure 400 {object} api.ErrorResponse "Invalid credentials, duplicate username, or validation errors"
+// @Failure 400 {object} api.ErrorResponse "Invalid credentials, duplicate username, validation errors, or a password found in a data breach"
 // @Failure 429 {object} api.ErrorResponse "Rate limit exceeded (2 requests per minute)"
 // @Failure 500 {object} api.ErrorResponse "Internal server error or email sending failure"
 // @Router /auth/register [post]
@@ -37,12 +37,16 @@ func (ar *AuthRouter) HandleRegister(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if strings.Contains(req.Username, "@") || !utils.IsValidEmail(req.Email) || !utils.IsValidPassword(req.Password) {
+	if strings.Contains(req.Username, "@") || !utils.IsValidEmail(req.Email) || !utils.MeetsPasswordPolicy(req.Password) {
 		applog.Warn("Invalid registration credentials", "username:", req.Username, "email:", req.Email)
 		http.Error(w, "invalid credentials", http.StatusBadRequest)
 		return
 	}
 
+	if !checkBreachedPassword(w, req.Password) {
+		return
+	}
+
 	duplicate, err := ar.UserRepo.DuplicateName(r.Context(), req.Username)
 	if err != nil {
 		applog.Error("Failed to check duplicate username:", err)
@@ -63,7 +67,7 @@ func (ar *AuthRouter) HandleRegister(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	user := &model.User{ID: utils.GenerateSnowflakeID(), Username: req.Username, PasswordHash: hash, Email: req.Email, CreatedAt: time.Now().UTC().Unix(), Role: "user", EmailConfirmed: false}
+	user := &model.User{ID: utils.GenerateSnowflakeID(), Username: req.Username, PasswordHash: hash, Email: req.Email, CreatedAt: time.Now().UTC().Unix(), Role: model.RoleUser, Status: model.StatusActive, EmailConfirmed: false}
 
 	if err := ar.UserRepo.CreateUser(r.Context(), user); err != nil {
 		applog.Error("Failed to create user:", err)
@@ -4,19 +4,27 @@ import (
 	"net/http"
 	"time"
 
+	"github.com/akramboussanni/treenode/config"
 	"github.com/akramboussanni/treenode/internal/middleware"
 	"github.com/akramboussanni/treenode/internal/repo"
 	"github.com/go-chi/chi/v5"
 )
 
 type AuthRouter struct {
-	UserRepo    *repo.UserRepo
-	TokenRepo   *repo.TokenRepo
-	LockoutRepo *repo.LockoutRepo
+	UserRepo       *repo.UserRepo
+	TokenRepo      *repo.TokenRepo
+	LockoutRepo    *repo.LockoutRepo
+	MfaRepo        *repo.MfaRepo
+	SessionRepo    *repo.SessionRepo
+	ApiTokenRepo   *repo.ApiTokenRepo
+	IdentityRepo   *repo.IdentityRepo
+	NodeRepo       *repo.NodeRepo
+	LinkRepo       *repo.LinkRepo
+	InvitationRepo *repo.InvitationRepo
 }
 
-func NewAuthRouter(userRepo *repo.UserRepo, tokenRepo *repo.TokenRepo, lockoutRepo *repo.LockoutRepo) http.Handler {
-	ar := &AuthRouter{UserRepo: userRepo, TokenRepo: tokenRepo, LockoutRepo: lockoutRepo}
+func NewAuthRouter(userRepo *repo.UserRepo, tokenRepo *repo.TokenRepo, lockoutRepo *repo.LockoutRepo, mfaRepo *repo.MfaRepo, sessionRepo *repo.SessionRepo, apiTokenRepo *repo.ApiTokenRepo, identityRepo *repo.IdentityRepo, nodeRepo *repo.NodeRepo, linkRepo *repo.LinkRepo, invitationRepo *repo.InvitationRepo) http.Handler {
+	ar := &AuthRouter{UserRepo: userRepo, TokenRepo: tokenRepo, LockoutRepo: lockoutRepo, MfaRepo: mfaRepo, SessionRepo: sessionRepo, ApiTokenRepo: apiTokenRepo, IdentityRepo: identityRepo, NodeRepo: nodeRepo, LinkRepo: linkRepo, InvitationRepo: invitationRepo}
 	r := chi.NewRouter()
 
 	r.Use(middleware.MaxBytesMiddleware(1 << 20))
@@ -26,8 +34,12 @@ func NewAuthRouter(userRepo *repo.UserRepo, tokenRepo *repo.TokenRepo, lockoutRe
 		middleware.AddRatelimit(r, 7, 1*time.Minute)
 		middleware.AddRecaptcha(r)
 		r.Post("/login", ar.HandleLogin)
+		r.Post("/login/mfa", ar.HandleLoginMfa)
 		r.Post("/logout", ar.HandleLogout)
 		r.Post("/logout-all", ar.HandleLogoutEverywhere)
+		if config.App.MagicLinkEnabled {
+			r.Post("/magic-link/verify", ar.HandleVerifyMagicLink)
+		}
 	})
 
 	//15/hour+recaptcha
@@ -38,22 +50,58 @@ func NewAuthRouter(userRepo *repo.UserRepo, tokenRepo *repo.TokenRepo, lockoutRe
 		r.Post("/forgot-password", ar.HandleSendForgotPassword)
 		r.Post("/confirm-email", ar.HandleConfirmEmail)
 		r.Post("/resend-confirmation", ar.HandleResendConfirmation)
+		r.Post("/change-email/confirm", ar.HandleConfirmEmailChange)
+		r.Post("/change-email/cancel", ar.HandleCancelEmailChange)
+		r.Post("/unlock-account", ar.HandleUnlockAccount)
 		r.Post("/register", ar.HandleRegister)
+		if config.App.MagicLinkEnabled {
+			r.Post("/magic-link", ar.HandleSendMagicLink)
+		}
 	})
 
 	//8/hour+auth+recaptcha
 	r.Group(func(r chi.Router) {
 		middleware.AddRatelimit(r, 8, 1*time.Hour)
-		middleware.AddAuth(r, ar.UserRepo, ar.TokenRepo)
+		middleware.AddAuth(r, ar.UserRepo, ar.TokenRepo, ar.SessionRepo)
 		middleware.AddRecaptcha(r)
 		r.Post("/change-password", ar.HandleChangePassword)
+		r.Post("/change-email", ar.HandleRequestEmailChange)
+		r.Delete("/account", ar.HandleDeleteAccount)
 	})
 
 	//30/min+auth
 	r.Group(func(r chi.Router) {
 		middleware.AddRatelimit(r, 30, 1*time.Minute)
-		middleware.AddAuth(r, ar.UserRepo, ar.TokenRepo)
+		middleware.AddAuth(r, ar.UserRepo, ar.TokenRepo, ar.SessionRepo)
 		r.Get("/me", ar.HandleProfile)
+		r.Get("/sessions", ar.HandleListSessions)
+		r.Delete("/sessions/{sessionID}", ar.HandleRevokeSession)
+	})
+
+	//10/min+auth
+	r.Group(func(r chi.Router) {
+		middleware.AddRatelimit(r, 10, 1*time.Minute)
+		middleware.AddAuth(r, ar.UserRepo, ar.TokenRepo, ar.SessionRepo)
+		r.Post("/mfa/totp/setup", ar.HandleTotpSetup)
+		r.Post("/mfa/totp/confirm", ar.HandleTotpConfirm)
+		r.Post("/mfa/totp/disable", ar.HandleTotpDisable)
+		r.Post("/mfa/recovery-codes", ar.HandleRegenerateRecoveryCodes)
+		r.Get("/tokens", ar.HandleListApiTokens)
+		r.Post("/tokens", ar.HandleCreateApiToken)
+		r.Delete("/tokens/{tokenID}", ar.HandleRevokeApiToken)
+		r.Post("/oidc/{provider}/link", ar.HandleOidcLink)
+		r.Get("/identities", ar.HandleListIdentities)
+		r.Delete("/identities/{identityID}", ar.HandleUnlinkIdentity)
+		r.Get("/account/export", ar.HandleExportAccount)
+		r.Post("/account/restore", ar.HandleRestoreAccount)
+	})
+
+	//30/min, browser redirects can't carry a recaptcha token
+	r.Group(func(r chi.Router) {
+		middleware.AddRatelimit(r, 30, 1*time.Minute)
+		r.Get("/oidc/providers", ar.HandleListOidcProviders)
+		r.Get("/oidc/{provider}/login", ar.HandleOidcLogin)
+		r.Get("/oidc/{provider}/callback", ar.HandleOidcCallback)
 	})
 
 	//15/min
@@ -1,7 +1,9 @@
 package auth
 
 import (
-	"math"
+	"context"
+	"database/sql"
+	"errors"
 	"net/http"
 	"time"
 
@@ -9,7 +11,6 @@ import (
 	"github.com/akramboussanni/treenode/internal/api"
 	"github.com/akramboussanni/treenode/internal/applog"
 	"github.com/akramboussanni/treenode/internal/jwt"
-	"github.com/akramboussanni/treenode/internal/middleware"
 	"github.com/akramboussanni/treenode/internal/model"
 	"github.com/akramboussanni/treenode/internal/utils"
 )
@@ -22,8 +23,10 @@ import (
 // @Param X-Recaptcha-Token header string false "reCAPTCHA verification token (optional if reCAPTCHA is not configured)"
 // @Param request body LoginRequest true "User login credentials"
 // @Success 200 {object} api.SuccessResponse "Authentication successful - session and refresh cookies set"
+// @Success 200 {object} MfaChallengeResponse "Password accepted - a second factor is required via /auth/login/mfa"
 // @Failure 400 {object} api.ErrorResponse "Invalid request format or missing required fields"
 // @Failure 401 {object} api.ErrorResponse "Invalid credentials or email not confirmed"
+// @Failure 423 {object} api.ErrorResponse "Too many failed attempts for this account or from this address"
 // @Failure 429 {object} api.ErrorResponse "Rate limit exceeded (8 requests per minute)"
 // @Failure 500 {object} api.ErrorResponse "Internal server error"
 // @Router /auth/login [post]
@@ -37,13 +40,21 @@ func (ar *AuthRouter) HandleLogin(w http.ResponseWriter, r *http.Request) {
 	}
 
 	user, err := ar.UserRepo.GetUserByEmail(r.Context(), cred.Email)
-	if err != nil || user == nil {
-		applog.Warn("Login failed: user not found or db error", "email:", cred.Email, "err:", err)
-		api.WriteInvalidCredentials(w)
+	if errors.Is(err, sql.ErrNoRows) {
+		user = nil
+	} else if err != nil {
+		applog.Error("Failed to get user by email:", err)
+		api.WriteInternalError(w)
 		return
 	}
 
-	lockedOut, err := ar.LockoutRepo.IsLockedOut(r.Context(), user.ID, ip)
+	// unknown emails only run into lockouts of the whole address
+	var userID int64
+	if user != nil {
+		userID = user.ID
+	}
+
+	lockedOut, err := ar.LockoutRepo.IsLockedOut(r.Context(), userID, utils.IPPrefix(ip))
 	if err != nil {
 		applog.Error("Error checking lockout:", err)
 		api.WriteInternalError(w)
@@ -51,73 +62,115 @@ func (ar *AuthRouter) HandleLogin(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if lockedOut {
-		applog.Warn("Account locked out", "userID:", user.ID, "ip:", ip)
+		applog.Warn("Account locked out", "userID:", userID, "ip:", ip)
 		api.WriteMessage(w, 423, "error", "account locked")
 		return
 	}
 
-	if !utils.ComparePassword(user.PasswordHash, cred.Password) {
-		now := time.Now().UTC().Unix()
-		nowMicro := time.Now().UTC().UnixMicro()
-		err := ar.LockoutRepo.AddFailedLogin(r.Context(), model.FailedLogin{ID: nowMicro, UserID: user.ID, IPAddress: ip, AttemptedAt: now, Active: true})
-
-		if err != nil {
-			applog.Error("Failed to add failed login:", err)
-			api.WriteInternalError(w)
-			return
-		}
-
-		count, err := ar.LockoutRepo.CountRecentFailures(r.Context(), user.ID, ip)
-		if err != nil {
-			applog.Error("Failed to count recent failures:", err)
-			api.WriteInternalError(w)
-			return
-		}
-
-		if count > config.App.LockoutCount {
-			err := ar.LockoutRepo.AddLockout(r.Context(), model.Lockout{
-				ID:          nowMicro,
-				UserID:      user.ID,
-				IPAddress:   ip,
-				LockedUntil: now + config.App.LockoutDuration,
-				Reason:      "failed logins",
-				Active:      true,
-			})
-
-			if err != nil {
-				applog.Error("Failed to add lockout:", err)
-				api.WriteInternalError(w)
-				return
-			}
-
-			applog.Warn("User locked out due to failed logins", "userID:", user.ID, "ip:", ip)
-			api.WriteMessage(w, 423, "error", "account locked")
-			return
-		}
+	if user == nil {
+		applog.Warn("Login failed: user not found", "email:", cred.Email)
+		ar.registerFailedLogin(r.Context(), w, nil, ip)
+		return
+	}
 
+	if !utils.ComparePassword(user.PasswordHash, cred.Password) {
 		applog.Warn("Invalid password for user", "userID:", user.ID)
-		api.WriteInvalidCredentials(w)
+		ar.registerFailedLogin(r.Context(), w, user, ip)
 		return
 	}
 
+	ar.rehashPassword(r.Context(), user, cred.Password)
+
 	if !user.EmailConfirmed {
 		applog.Warn("Login attempt with unconfirmed email", "userID:", user.ID)
 		api.WriteInvalidCredentials(w)
 		return
 	}
 
-	loginTokens := GenerateLogin(jwt.CreateJwtFromUser(user))
+	if user.TotpEnabled {
+		mfaToken := jwt.CreateJwtFromUser(user, user.JwtSessionID).WithType(model.MfaPendingJwt).GenerateToken()
+		applog.Info("Password accepted, awaiting second factor", "userID:", user.ID)
+		api.WriteJSON(w, 200, MfaChallengeResponse{MfaRequired: true, MfaToken: mfaToken})
+		return
+	}
+
+	ar.completeLogin(w, r, user)
+}
+
+// rehashPassword upgrades a hash made with an older algorithm or parameters while the
+// plain password is at hand. failures are logged and the old hash keeps working.
+func (ar *AuthRouter) rehashPassword(ctx context.Context, user *model.User, password string) {
+	if !utils.PasswordNeedsRehash(user.PasswordHash) {
+		return
+	}
+
+	hash, err := utils.HashPassword(password)
+	if err != nil {
+		applog.Error("Failed to rehash password:", err)
+		return
+	}
+
+	if err := ar.UserRepo.RehashPassword(ctx, user.ID, user.PasswordHash, hash); err != nil {
+		applog.Error("Failed to store rehashed password:", err)
+		return
+	}
+
+	applog.Info("Password rehashed to current policy", "userID:", user.ID)
+	user.PasswordHash = hash
+}
+
+// completeLogin registers a device session and issues fresh session and refresh
+// cookies once every factor has been checked
+func (ar *AuthRouter) completeLogin(w http.ResponseWriter, r *http.Request, user *model.User) {
+	if !ar.startSession(w, r, user) {
+		return
+	}
+
+	api.WriteMessage(w, 200, "message", "login successful")
+}
+
+// startSession creates the device session and sets the cookies. on failure it writes
+// the error response and returns false.
+func (ar *AuthRouter) startSession(w http.ResponseWriter, r *http.Request, user *model.User) bool {
+	if !user.IsActive() {
+		applog.Warn("Login attempt on disabled account", "userID:", user.ID, "status:", user.Status)
+		api.WriteMessage(w, 403, "error", "account disabled")
+		return false
+	}
+
+	now := time.Now().UTC().Unix()
+	userAgent := r.UserAgent()
+	sessionID := utils.GenerateSnowflakeID()
+	jwtToken := jwt.CreateJwtFromUser(user, sessionID)
+	session := &model.Session{
+		ID:         sessionID,
+		UserID:     user.ID,
+		CreatedAt:  now,
+		LastSeenAt: now,
+		IPAddress:  utils.GetClientIP(r),
+		UserAgent:  userAgent,
+		Label:      utils.DescribeUserAgent(userAgent),
+		RefreshJti: jwtToken.Payload.TokenID,
+	}
+
+	if err := ar.SessionRepo.CreateSession(r.Context(), session); err != nil {
+		applog.Error("Failed to create session:", err)
+		api.WriteInternalError(w)
+		return false
+	}
+
+	loginTokens := GenerateLogin(jwtToken)
 
 	utils.ClearAllCookies(w)
 	utils.SetSessionCookie(w, loginTokens.Session)
 	utils.SetRefreshCookie(w, loginTokens.Refresh)
 
-	applog.Info("User login successful", "userID:", user.ID)
-	api.WriteMessage(w, 200, "message", "login successful")
+	applog.Info("User login successful", "userID:", user.ID, "sessionID:", session.ID)
+	return true
 }
 
 // @Summary Refresh session cookies
-// @Description Refresh user's session cookies using a valid refresh cookie. The old refresh token will be revoked and new session/refresh cookies will be set.
+// @Description Refresh user's session cookies using a valid refresh cookie. The old refresh token will be revoked and new session/refresh cookies will be set. Presenting a refresh token that was already rotated is treated as theft and logs the whole device session out.
 // @Tags Authentication
 // @Accept json
 // @Produce json
@@ -129,6 +182,7 @@ func (ar *AuthRouter) HandleLogin(w http.ResponseWriter, r *http.Request) {
 // @Router /auth/refresh [post]
 func (ar *AuthRouter) HandleRefresh(w http.ResponseWriter, r *http.Request) {
 	applog.Info("HandleRefresh called")
+	ip := utils.GetClientIP(r)
 
 	refreshCookie, err := r.Cookie("refresh")
 	if err != nil {
@@ -137,8 +191,9 @@ func (ar *AuthRouter) HandleRefresh(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	claims := middleware.GetClaims(w, r, refreshCookie.Value, config.JwtSecretBytes, ar.TokenRepo)
-	if claims == nil || claims.Type != model.RefreshJwt {
+	// rotated tokens are blacklisted, skip that check here so replays can be recognised
+	claims, err := jwt.ParseToken(refreshCookie.Value, config.JwtSecretBytes)
+	if err != nil || claims.Type != model.RefreshJwt {
 		applog.Warn("Invalid or missing refresh token")
 		api.WriteInvalidCredentials(w)
 		return
@@ -151,18 +206,55 @@ func (ar *AuthRouter) HandleRefresh(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	blacklist := model.JwtBlacklist{
-		TokenID:   claims.TokenID,
-		UserID:    claims.UserID,
-		ExpiresAt: math.MaxInt64,
+	if !user.IsActive() {
+		applog.Warn("Refresh failed: account disabled", "userID:", user.ID)
+		api.WriteMessage(w, 403, "error", "account disabled")
+		return
+	}
+
+	session, err := ar.SessionRepo.GetSessionByID(r.Context(), claims.SessionID)
+	if err != nil || session.UserID != user.ID {
+		applog.Warn("Refresh failed: session revoked or not found", "userID:", claims.UserID, "sessionID:", claims.SessionID)
+		api.WriteInvalidCredentials(w)
+		return
+	}
+
+	// sessions from before family tracking adopt the first refresh token they see
+	currentJti := session.RefreshJti
+	if currentJti == "" {
+		currentJti = claims.TokenID
+	}
+
+	if claims.TokenID != currentJti {
+		ar.revokeRefreshFamily(r.Context(), w, session, ip)
+		return
 	}
 
-	err = ar.TokenRepo.RevokeToken(r.Context(), blacklist)
+	jwtToken := jwt.CreateJwtFromUser(user, session.ID)
+	rotated, err := ar.SessionRepo.RotateRefreshToken(r.Context(), session.ID, session.RefreshJti, jwtToken.Payload.TokenID, time.Now().UTC().Unix(), ip, r.UserAgent())
+	if err != nil {
+		applog.Error("Failed to rotate refresh token:", err)
+		api.WriteInternalError(w)
+		return
+	}
+
+	if !rotated {
+		// another request redeemed this token first
+		ar.revokeRefreshFamily(r.Context(), w, session, ip)
+		return
+	}
+
+	err = ar.TokenRepo.RevokeToken(r.Context(), model.JwtBlacklist{
+		TokenID:   claims.TokenID,
+		UserID:    claims.UserID,
+		ExpiresAt: claims.PairExpiry(),
+	})
 	if err != nil {
 		applog.Error("Failed to revoke old refresh token:", err)
 	}
 
-	loginTokens := GenerateLogin(jwt.CreateJwtFromUser(user))
+	// the session id stays stable across refreshes
+	loginTokens := GenerateLogin(jwtToken)
 
 	utils.SetSessionCookie(w, loginTokens.Session)
 	utils.SetRefreshCookie(w, loginTokens.Refresh)
@@ -170,3 +262,18 @@ func (ar *AuthRouter) HandleRefresh(w http.ResponseWriter, r *http.Request) {
 	applog.Info("Refresh token successful", "userID:", user.ID)
 	api.WriteMessage(w, 200, "message", "tokens refreshed")
 }
+
+// revokeRefreshFamily handles a replayed refresh token. either the legitimate client or
+// an attacker holds a stale copy, and there is no telling which, so the session goes.
+func (ar *AuthRouter) revokeRefreshFamily(ctx context.Context, w http.ResponseWriter, session *model.Session, ip string) {
+	applog.Warn("Security event: refresh token reuse detected, revoking session", "userID:", session.UserID, "sessionID:", session.ID, "ip:", ip)
+
+	if _, err := ar.SessionRepo.DeleteSession(ctx, session.ID, session.UserID); err != nil {
+		applog.Error("Failed to revoke session after refresh token reuse:", err)
+		api.WriteInternalError(w)
+		return
+	}
+
+	utils.ClearAllCookies(w)
+	api.WriteInvalidCredentials(w)
+}
@@ -0,0 +1,100 @@
+package auth
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/akramboussanni/treenode/config"
+	"github.com/akramboussanni/treenode/internal/api"
+	"github.com/akramboussanni/treenode/internal/applog"
+	"github.com/akramboussanni/treenode/internal/model"
+	"github.com/akramboussanni/treenode/internal/utils"
+	"github.com/go-chi/chi/v5"
+)
+
+// @Summary List active sessions
+// @Description List the devices the current user is logged in from. Sessions whose refresh token can no longer be used are omitted.
+// @Tags Account
+// @Accept json
+// @Produce json
+// @Security CookieAuth
+// @Success 200 {array} SessionResponse "Active sessions, most recently seen first"
+// @Failure 401 {object} api.ErrorResponse "Unauthorized - invalid or missing session cookie"
+// @Failure 429 {object} api.ErrorResponse "Rate limit exceeded (30 requests per minute)"
+// @Failure 500 {object} api.ErrorResponse "Internal server error"
+// @Router /auth/sessions [get]
+func (ar *AuthRouter) HandleListSessions(w http.ResponseWriter, r *http.Request) {
+	applog.Info("HandleListSessions called")
+	user, ok := utils.UserFromContext(r.Context())
+	if !ok {
+		applog.Error("Failed to get user from context")
+		api.WriteInternalError(w)
+		return
+	}
+
+	currentID, _ := utils.SessionIDFromContext(r.Context())
+
+	seenAfter := time.Now().UTC().Unix() - config.App.JwtExpirations[string(model.RefreshJwt)]
+	sessions, err := ar.SessionRepo.GetSessionsByUserID(r.Context(), user.ID, seenAfter)
+	if err != nil {
+		applog.Error("Failed to list sessions:", err)
+		api.WriteInternalError(w)
+		return
+	}
+
+	result := make([]SessionResponse, 0, len(sessions))
+	for _, session := range sessions {
+		result = append(result, SessionResponse{Session: session, Current: session.ID == currentID})
+	}
+
+	api.WriteJSON(w, 200, result)
+}
+
+// @Summary Revoke a session
+// @Description Log out a single device. Its session and refresh tokens stop working immediately. Revoking the current session also clears the cookies.
+// @Tags Account
+// @Accept json
+// @Produce json
+// @Security CookieAuth
+// @Param sessionID path string true "Session ID"
+// @Success 200 {object} api.SuccessResponse "Session revoked"
+// @Failure 400 {object} api.ErrorResponse "Invalid session ID"
+// @Failure 401 {object} api.ErrorResponse "Unauthorized - invalid or missing session cookie"
+// @Failure 404 {object} api.ErrorResponse "Session not found"
+// @Failure 429 {object} api.ErrorResponse "Rate limit exceeded (30 requests per minute)"
+// @Failure 500 {object} api.ErrorResponse "Internal server error"
+// @Router /auth/sessions/{sessionID} [delete]
+func (ar *AuthRouter) HandleRevokeSession(w http.ResponseWriter, r *http.Request) {
+	applog.Info("HandleRevokeSession called")
+	sessionID, err := utils.ParseID(chi.URLParam(r, "sessionID"))
+	if err != nil {
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return
+	}
+
+	user, ok := utils.UserFromContext(r.Context())
+	if !ok {
+		applog.Error("Failed to get user from context")
+		api.WriteInternalError(w)
+		return
+	}
+
+	deleted, err := ar.SessionRepo.DeleteSession(r.Context(), sessionID, user.ID)
+	if err != nil {
+		applog.Error("Failed to revoke session:", err)
+		api.WriteInternalError(w)
+		return
+	}
+
+	if !deleted {
+		api.WriteMessage(w, 404, "error", "session not found")
+		return
+	}
+
+	if currentID, ok := utils.SessionIDFromContext(r.Context()); ok && currentID == sessionID {
+		utils.ClearAllCookies(w)
+	}
+
+	applog.Info("Session revoked", "userID:", user.ID, "sessionID:", sessionID)
+	api.WriteMessage(w, 200, "message", "session revoked")
+}
@@ -0,0 +1,33 @@
+package routes
+
+import (
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/akramboussanni/treenode/config"
+	"github.com/akramboussanni/treenode/internal/utils"
+)
+
+// routeByHost sends requests for node hosts to the host router. the platform host,
+// API_HOSTS, localhost and bare addresses keep serving the API.
+func routeByHost(hostRouter http.Handler) func(http.Handler) http.Handler {
+	apiHosts := map[string]bool{config.App.PlatformHost: true, "localhost": true}
+	for _, host := range strings.Split(config.App.ApiHosts, ",") {
+		if host = strings.ToLower(strings.TrimSpace(host)); host != "" {
+			apiHosts[host] = true
+		}
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			host := utils.RequestHost(r)
+			if apiHosts[host] || net.ParseIP(host) != nil {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			hostRouter.ServeHTTP(w, r)
+		})
+	}
+}
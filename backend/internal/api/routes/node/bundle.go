@@ -0,0 +1,409 @@
+package node
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/akramboussanni/treenode/internal/api"
+	"github.com/akramboussanni/treenode/internal/applog"
+	"github.com/akramboussanni/treenode/internal/model"
+	"github.com/akramboussanni/treenode/internal/utils"
+)
+
+const (
+	bundleMaxLinks      = 500
+	bundleMaxColorStops = 20
+	bundleMaxText       = 255
+	bundleMaxLongText   = 2000
+	bundleMaxColor      = 50
+	// attempts at a free subdomain or link name before giving up
+	bundleMaxSuffix = 100
+)
+
+var (
+	subdomainRegex     = regexp.MustCompile(`^[a-zA-Z0-9]([a-zA-Z0-9\-]{0,61}[a-zA-Z0-9])?$`)
+	bundleGradientType = []string{"", "solid", "linear", "radial"}
+)
+
+// @Summary Export a node
+// @Description Export a node's page settings, links and color stops as a versioned JSON bundle, in their current draft state. ids, the owner, collaborators and the custom domain aren't included. Owners and collaborators can export.
+// @Tags nodes
+// @Produce json
+// @Param nodeID path string true "Node ID"
+// @Success 200 {object} model.NodeBundle
+// @Failure 400 {string} string "Bad request"
+// @Failure 401 {string} string "Unauthorized"
+// @Failure 403 {string} string "Forbidden"
+// @Failure 500 {string} string "Internal server error"
+// @Router /nodes/{nodeID}/export [get]
+func (nr *NodeRouter) HandleExportNode(w http.ResponseWriter, r *http.Request) {
+	nodeID, ok := nr.accessibleNodeID(w, r)
+	if !ok {
+		return
+	}
+
+	node, err := nr.NodeRepo.GetNodeByID(r.Context(), nodeID)
+	if err != nil {
+		http.Error(w, "not found", http.StatusNotFound)
+		return
+	}
+
+	links, err := nr.LinkRepo.GetLinksByNodeID(r.Context(), nodeID)
+	if err != nil {
+		applog.Error("Failed to get links:", err)
+		api.WriteInternalError(w)
+		return
+	}
+
+	for i := range links {
+		if err := nr.LinkRepo.LoadColorStops(r.Context(), &links[i]); err != nil {
+			applog.Error("Failed to load color stops:", err)
+			api.WriteInternalError(w)
+			return
+		}
+	}
+
+	bundle := model.NewNodeBundle(node, links, time.Now().UTC().Unix())
+
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.treenode.json"`, node.SubdomainName))
+	api.WriteJSON(w, 200, bundle)
+}
+
+// @Summary Import a node
+// @Description Create a node owned by the authenticated user from an exported bundle, posted as is. Every field is validated: invalid node settings reject the import, while invalid links and color stops are skipped and listed in errors. A taken or reserved subdomain and duplicate link names get a numbered suffix, listed in renamed. subdomain_name overrides the bundle's subdomain.
+// @Tags nodes
+// @Accept json
+// @Produce json
+// @Param subdomain_name query string false "Subdomain for the new node, the bundle's by default"
+// @Param request body model.NodeBundle true "Exported bundle"
+// @Success 201 {object} ImportResponse
+// @Failure 400 {object} ImportResponse "Bad request, unsupported schema version or invalid node settings"
+// @Failure 401 {string} string "Unauthorized"
+// @Failure 409 {object} api.ErrorResponse "No free subdomain name"
+// @Failure 500 {string} string "Internal server error"
+// @Router /nodes/import [post]
+func (nr *NodeRouter) HandleImportNode(w http.ResponseWriter, r *http.Request) {
+	user, ok := utils.UserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var bundle model.NodeBundle
+	if err := json.NewDecoder(r.Body).Decode(&bundle); err != nil {
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return
+	}
+
+	if bundle.SchemaVersion < 1 || bundle.SchemaVersion > model.NodeBundleVersion {
+		api.WriteMessage(w, 400, "error", fmt.Sprintf("Unsupported schema version, expected 1 to %d", model.NodeBundleVersion))
+		return
+	}
+
+	subdomain := bundle.SubdomainName
+	if override := r.URL.Query().Get("subdomain_name"); override != "" {
+		subdomain = override
+	}
+
+	var res ImportResponse
+	if !subdomainRegex.MatchString(subdomain) {
+		res.addError("subdomain_name", "subdomain_name", "must be letters, digits and dashes, at most 63")
+	}
+	validateBundleNode(&bundle.Node, &res)
+	if len(bundle.Links) > bundleMaxLinks {
+		res.addError("links", "links", fmt.Sprintf("at most %d links", bundleMaxLinks))
+	}
+
+	if len(res.Errors) > 0 {
+		api.WriteJSON(w, 400, res)
+		return
+	}
+
+	free, err := nr.freeSubdomain(r, subdomain)
+	if err != nil {
+		applog.Error("Failed to check duplicate subdomain:", err)
+		api.WriteInternalError(w)
+		return
+	}
+	if free == "" {
+		api.WriteMessage(w, 409, "error", "Subdomain name already exists")
+		return
+	}
+	if free != subdomain {
+		res.Renamed = append(res.Renamed, ImportRename{Item: "subdomain_name", From: subdomain, To: free})
+	}
+
+	links := importLinks(bundle.Links, &res)
+
+	now := time.Now().UTC().Unix()
+	node := &model.Node{
+		ID:            utils.GenerateSnowflakeID(),
+		OwnerID:       user.ID,
+		SubdomainName: free,
+		CreatedAt:     now,
+		UpdatedAt:     now,
+	}
+	applyBundleNode(&bundle.Node, node)
+
+	err = nr.NodeRepo.CreateNodeWithLinks(r.Context(), node, freshLinks(links, node.ID, now), nil)
+	if err != nil {
+		applog.Error("Failed to import node:", err)
+		api.WriteInternalError(w)
+		return
+	}
+
+	nr.recordRevision(r.Context(), node.ID, user.ID, model.RevisionNodeCreate)
+
+	res.Node = node
+	res.LinksImported = len(links)
+
+	applog.Info("Node imported", "nodeID:", node.ID, "links:", len(links), "skipped:", len(bundle.Links)-len(links))
+	api.WriteJSON(w, 201, res)
+}
+
+func (res *ImportResponse) addError(item, field, message string) {
+	res.Errors = append(res.Errors, ImportError{Item: item, Field: field, Message: message})
+}
+
+// freeSubdomain returns the subdomain, or the first free one with a numbered suffix
+// when it's reserved, taken or held. empty when none is free.
+func (nr *NodeRouter) freeSubdomain(r *http.Request, subdomain string) (string, error) {
+	for i := 1; i <= bundleMaxSuffix; i++ {
+		candidate := subdomain
+		if i > 1 {
+			suffix := fmt.Sprintf("-%d", i)
+			candidate = strings.TrimSuffix(subdomain[:min(len(subdomain), 63-len(suffix))], "-") + suffix
+		}
+
+		if isReservedSubdomain(candidate) {
+			continue
+		}
+
+		duplicate, err := nr.NodeRepo.DuplicateSubdomain(r.Context(), candidate)
+		if err != nil {
+			return "", err
+		}
+		if duplicate {
+			continue
+		}
+
+		held, err := nr.SubdomainRepo.IsHeld(r.Context(), candidate, 0, time.Now().UTC().Unix())
+		if err != nil {
+			return "", err
+		}
+		if !held {
+			return candidate, nil
+		}
+	}
+	return "", nil
+}
+
+func isReservedSubdomain(subdomain string) bool {
+	for _, keyword := range reservedSubdomains {
+		if strings.EqualFold(subdomain, keyword) {
+			return true
+		}
+	}
+	return false
+}
+
+func validateBundleNode(n *model.BundleNode, res *ImportResponse) {
+	checkText := func(field, value string, max int) {
+		if len(value) > max {
+			res.addError("node", field, fmt.Sprintf("at most %d characters", max))
+		}
+	}
+	checkColor := func(field, value string) {
+		if value != "" && (len(value) > bundleMaxColor || !utils.IsValidColor(value)) {
+			res.addError("node", field, "not a valid color")
+		}
+	}
+
+	checkText("display_name", n.DisplayName, bundleMaxText)
+	checkText("description", n.Description, bundleMaxLongText)
+	checkText("page_title", n.PageTitle, bundleMaxText)
+	checkText("theme", n.Theme, bundleMaxColor)
+	checkColor("background_color", n.BackgroundColor)
+	checkColor("title_font_color", n.TitleFontColor)
+	checkColor("caption_font_color", n.CaptionFontColor)
+	checkColor("accent_color", n.AccentColor)
+	checkColor("theme_color", n.ThemeColor)
+}
+
+func applyBundleNode(n *model.BundleNode, node *model.Node) {
+	node.DisplayName = n.DisplayName
+	node.Description = n.Description
+	node.BackgroundColor = n.BackgroundColor
+	node.TitleFontColor = n.TitleFontColor
+	node.CaptionFontColor = n.CaptionFontColor
+	node.AccentColor = n.AccentColor
+	node.ThemeColor = n.ThemeColor
+	node.ShowShareButton = n.ShowShareButton
+	node.Theme = n.Theme
+	node.MouseEffectsEnabled = n.MouseEffectsEnabled
+	node.TextShadowsEnabled = n.TextShadowsEnabled
+	node.HidePoweredBy = n.HidePoweredBy
+	node.PageTitle = n.PageTitle
+}
+
+// importLinks validates the bundle's links, skipping the invalid ones and color stops and
+// renaming duplicate names. ids and positions are left for freshLinks.
+func importLinks(bundleLinks []model.BundleLink, res *ImportResponse) []model.Link {
+	links := make([]model.Link, 0, len(bundleLinks))
+	names := make(map[string]bool, len(bundleLinks))
+
+	for i, bl := range bundleLinks {
+		item := fmt.Sprintf("links[%d]", i)
+		if !validateBundleLink(item, &bl, res) {
+			continue
+		}
+
+		link := model.Link{
+			Name:                          bl.Name,
+			DisplayName:                   bl.DisplayName,
+			Link:                          bl.Link,
+			Description:                   bl.Description,
+			Icon:                          bl.Icon,
+			Visible:                       bl.Visible,
+			Enabled:                       bl.Enabled,
+			Mini:                          bl.Mini,
+			GradientType:                  bl.GradientType,
+			GradientAngle:                 bl.GradientAngle,
+			CustomAccentColorEnabled:      bl.CustomAccentColorEnabled,
+			CustomAccentColor:             bl.CustomAccentColor,
+			CustomTitleColorEnabled:       bl.CustomTitleColorEnabled,
+			CustomTitleColor:              bl.CustomTitleColor,
+			CustomDescriptionColorEnabled: bl.CustomDescriptionColorEnabled,
+			CustomDescriptionColor:        bl.CustomDescriptionColor,
+			MiniBackgroundEnabled:         bl.MiniBackgroundEnabled,
+		}
+
+		// names are unique per node, the empty one included
+		base := link.Name
+		if base == "" && names[""] {
+			base = "link"
+		}
+		name, ok := freeLinkName(base, names)
+		if !ok {
+			res.addError(item, "name", "no free name left")
+			continue
+		}
+		if name != link.Name {
+			res.Renamed = append(res.Renamed, ImportRename{Item: item, From: link.Name, To: name})
+			link.Name = name
+		}
+		names[strings.ToLower(name)] = true
+
+		if len(bl.ColorStops) > bundleMaxColorStops {
+			res.addError(item, "color_stops", fmt.Sprintf("at most %d color stops, the rest were skipped", bundleMaxColorStops))
+			bl.ColorStops = bl.ColorStops[:bundleMaxColorStops]
+		}
+
+		for j, stop := range bl.ColorStops {
+			stopItem := fmt.Sprintf("%s.color_stops[%d]", item, j)
+			if len(stop.Color) > bundleMaxColor || !utils.IsValidColor(stop.Color) {
+				res.addError(stopItem, "color", "not a valid color")
+				continue
+			}
+			if stop.Position < 0 || stop.Position > 100 {
+				res.addError(stopItem, "position", "must be between 0 and 100")
+				continue
+			}
+			link.ColorStops = append(link.ColorStops, model.ColorStop{Color: stop.Color, Position: stop.Position})
+		}
+
+		links = append(links, link)
+	}
+
+	return links
+}
+
+// validateBundleLink adds an error for each invalid field of the link, reporting whether
+// it can be imported
+func validateBundleLink(item string, bl *model.BundleLink, res *ImportResponse) bool {
+	before := len(res.Errors)
+
+	if bl.Name == "" && bl.Link == "" {
+		res.addError(item, "name", "either a name or a link is required")
+	}
+	if len(bl.Name) > bundleMaxText {
+		res.addError(item, "name", fmt.Sprintf("at most %d characters", bundleMaxText))
+	}
+	if len(bl.DisplayName) > bundleMaxText {
+		res.addError(item, "display_name", fmt.Sprintf("at most %d characters", bundleMaxText))
+	}
+	if len(bl.Description) > bundleMaxLongText {
+		res.addError(item, "description", fmt.Sprintf("at most %d characters", bundleMaxLongText))
+	}
+	if len(bl.Icon) > bundleMaxText {
+		res.addError(item, "icon", fmt.Sprintf("at most %d characters", bundleMaxText))
+	}
+
+	if bl.Link != "" {
+		if len(bl.Link) > bundleMaxLongText || !utils.IsValidLinkURL(bl.Link) {
+			res.addError(item, "link", "must be an http(s), mailto or tel url")
+		}
+	}
+	for _, f := range []fieldValue{
+		{"name", bl.Name},
+		{"display_name", bl.DisplayName},
+		{"description", bl.Description},
+	} {
+		if utils.ContainsXSS(f.value) {
+			res.addError(item, f.field, "contains a script or event handler")
+		}
+	}
+
+	if !contains(bundleGradientType, bl.GradientType) {
+		res.addError(item, "gradient_type", "must be solid, linear or radial")
+	}
+	if bl.GradientAngle < 0 || bl.GradientAngle > 360 {
+		res.addError(item, "gradient_angle", "must be between 0 and 360")
+	}
+
+	for _, f := range []fieldValue{
+		{"custom_accent_color", bl.CustomAccentColor},
+		{"custom_title_color", bl.CustomTitleColor},
+		{"custom_description_color", bl.CustomDescriptionColor},
+	} {
+		if f.value != "" && (len(f.value) > bundleMaxColor || !utils.IsValidColor(f.value)) {
+			res.addError(item, f.field, "not a valid color")
+		}
+	}
+
+	return len(res.Errors) == before
+}
+
+// freeLinkName returns the name, or the first one with a numbered suffix that isn't
+// taken. false when none is free.
+func freeLinkName(name string, taken map[string]bool) (string, bool) {
+	for i := 1; i <= bundleMaxSuffix; i++ {
+		candidate := name
+		if i > 1 {
+			candidate = fmt.Sprintf("%s-%d", name, i)
+		}
+		if !taken[strings.ToLower(candidate)] {
+			return candidate, true
+		}
+	}
+	return "", false
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// fieldValue pairs a field with its value so checks run, and report errors, in a fixed order
+type fieldValue struct {
+	field string
+	value string
+}
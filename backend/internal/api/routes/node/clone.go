@@ -0,0 +1,161 @@
+package node
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/akramboussanni/treenode/internal/api"
+	"github.com/akramboussanni/treenode/internal/applog"
+	"github.com/akramboussanni/treenode/internal/model"
+	"github.com/akramboussanni/treenode/internal/utils"
+	"github.com/go-chi/chi/v5"
+)
+
+// @Summary Clone a node
+// @Description Create a node owned by the authenticated user with the styling, links and color stops of a node they own or collaborate on, in one transaction. Hidden and disabled links and the collaborators are only copied when asked for, and only the owner can copy the collaborators. The custom domain isn't copied and the subdomain is checked like on creation.
+// @Tags nodes
+// @Accept json
+// @Produce json
+// @Param nodeID path string true "Node ID to clone"
+// @Param request body CloneNodeRequest true "Clone options"
+// @Success 201 {object} model.Node
+// @Failure 400 {object} api.ErrorResponse "Bad request or reserved subdomain"
+// @Failure 401 {string} string "Unauthorized"
+// @Failure 403 {string} string "Forbidden"
+// @Failure 404 {string} string "Not found"
+// @Failure 409 {object} api.ErrorResponse "Subdomain name already exists"
+// @Failure 500 {string} string "Internal server error"
+// @Router /nodes/{nodeID}/clone [post]
+func (nr *NodeRouter) HandleCloneNode(w http.ResponseWriter, r *http.Request) {
+	var req CloneNodeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return
+	}
+
+	nodeID, err := utils.ParseID(chi.URLParam(r, "nodeID"))
+	if err != nil {
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return
+	}
+
+	user, ok := utils.UserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	hasAccess, err := nr.NodeRepo.CheckNodeAccess(r.Context(), nodeID, user.ID)
+	if err != nil || !hasAccess {
+		http.Error(w, "forbidden", http.StatusForbidden)
+		return
+	}
+
+	source, err := nr.NodeRepo.GetNodeByID(r.Context(), nodeID)
+	if err != nil {
+		http.Error(w, "not found", http.StatusNotFound)
+		return
+	}
+
+	// a copy would bring the page back online
+	if source.TakenDownAt != 0 {
+		http.Error(w, "forbidden", http.StatusForbidden)
+		return
+	}
+
+	// only the owner decides who has access to the page
+	if req.IncludeCollaborators && source.OwnerID != user.ID {
+		http.Error(w, "forbidden", http.StatusForbidden)
+		return
+	}
+
+	if !nr.checkNewSubdomain(w, r, req.SubdomainName) {
+		return
+	}
+
+	links, err := nr.LinkRepo.GetLinksByNodeID(r.Context(), source.ID)
+	if err != nil {
+		applog.Error("Failed to get links:", err)
+		api.WriteInternalError(w)
+		return
+	}
+
+	var kept []model.Link
+	for i := range links {
+		if (!links[i].Visible && !req.IncludeHidden) || (!links[i].Enabled && !req.IncludeDisabled) {
+			continue
+		}
+
+		if err := nr.LinkRepo.LoadColorStops(r.Context(), &links[i]); err != nil {
+			applog.Error("Failed to load color stops:", err)
+			api.WriteInternalError(w)
+			return
+		}
+		kept = append(kept, links[i])
+	}
+
+	var collaborators []int64
+	if req.IncludeCollaborators {
+		if err := nr.NodeRepo.LoadCollaborators(r.Context(), source); err != nil {
+			applog.Error("Failed to load collaborators:", err)
+			api.WriteInternalError(w)
+			return
+		}
+
+		for _, id := range source.Collaborators {
+			if id != user.ID {
+				collaborators = append(collaborators, id)
+			}
+		}
+	}
+
+	now := time.Now().UTC().Unix()
+	node := &model.Node{
+		ID:            utils.GenerateSnowflakeID(),
+		OwnerID:       user.ID,
+		SubdomainName: req.SubdomainName,
+		CreatedAt:     now,
+		UpdatedAt:     now,
+	}
+	style := model.NewNodeSnapshot(source, nil)
+	style.Apply(node)
+
+	err = nr.NodeRepo.CreateNodeWithLinks(r.Context(), node, freshLinks(kept, node.ID, now), collaborators)
+	if err != nil {
+		applog.Error("Failed to clone node:", err)
+		api.WriteInternalError(w)
+		return
+	}
+
+	nr.recordRevision(r.Context(), node.ID, user.ID, model.RevisionNodeCreate)
+
+	applog.Info("Node cloned", "sourceID:", source.ID, "nodeID:", node.ID, "links:", len(kept))
+	api.WriteJSON(w, 201, node)
+}
+
+// freshLinks copies links and their color stops onto the node with new ids, numbering
+// their positions in order
+func freshLinks(links []model.Link, nodeID, now int64) []model.Link {
+	copies := make([]model.Link, 0, len(links))
+	for i, link := range links {
+		link.ID = utils.GenerateSnowflakeID()
+		link.NodeID = nodeID
+		link.Position = i
+		link.CreatedAt = now
+		link.UpdatedAt = now
+
+		stops := make([]model.ColorStop, 0, len(link.ColorStops))
+		for _, stop := range link.ColorStops {
+			stop.ID = utils.GenerateSnowflakeID()
+			stop.LinkID = link.ID
+			stop.CreatedAt = now
+			stop.UpdatedAt = now
+			stops = append(stops, stop)
+		}
+		link.ColorStops = stops
+
+		copies = append(copies, link)
+	}
+	return copies
+}
@@ -79,6 +79,12 @@ func (nr *NodeRouter) HandleCreateColorStop(w http.ResponseWriter, r *http.Reque
 		CreatedAt: time.Now().UTC().Unix(),
 	}
 
+	if err := nr.beforeChange(r.Context(), nodeID); err != nil {
+		applog.Error("Failed to prepare draft:", err)
+		api.WriteInternalError(w)
+		return
+	}
+
 	err = nr.LinkRepo.CreateColorStop(r.Context(), colorStop)
 	if err != nil {
 		applog.Error("Failed to create color stop:", err)
@@ -86,6 +92,8 @@ func (nr *NodeRouter) HandleCreateColorStop(w http.ResponseWriter, r *http.Reque
 		return
 	}
 
+	nr.recordRevision(r.Context(), nodeID, user.ID, model.RevisionColorStopCreate)
+
 	api.WriteJSON(w, 201, colorStop)
 }
 
@@ -169,6 +177,12 @@ func (nr *NodeRouter) HandleUpdateColorStop(w http.ResponseWriter, r *http.Reque
 	colorStop.Color = req.Color
 	colorStop.Position = req.Position
 
+	if err := nr.beforeChange(r.Context(), nodeID); err != nil {
+		applog.Error("Failed to prepare draft:", err)
+		api.WriteInternalError(w)
+		return
+	}
+
 	err = nr.LinkRepo.UpdateColorStop(r.Context(), colorStop)
 	if err != nil {
 		applog.Error("Failed to update color stop:", err)
@@ -176,6 +190,8 @@ func (nr *NodeRouter) HandleUpdateColorStop(w http.ResponseWriter, r *http.Reque
 		return
 	}
 
+	nr.recordRevision(r.Context(), nodeID, user.ID, model.RevisionColorStopUpdate)
+
 	api.WriteJSON(w, 200, colorStop)
 }
 
@@ -248,6 +264,12 @@ func (nr *NodeRouter) HandleDeleteColorStop(w http.ResponseWriter, r *http.Reque
 		return
 	}
 
+	if err := nr.beforeChange(r.Context(), nodeID); err != nil {
+		applog.Error("Failed to prepare draft:", err)
+		api.WriteInternalError(w)
+		return
+	}
+
 	err = nr.LinkRepo.DeleteColorStop(r.Context(), colorStopID)
 	if err != nil {
 		applog.Error("Failed to delete color stop:", err)
@@ -255,5 +277,7 @@ func (nr *NodeRouter) HandleDeleteColorStop(w http.ResponseWriter, r *http.Reque
 		return
 	}
 
+	nr.recordRevision(r.Context(), nodeID, user.ID, model.RevisionColorStopDelete)
+
 	api.WriteMessage(w, 200, "message", "Color stop deleted successfully")
 }
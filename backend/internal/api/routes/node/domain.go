@@ -0,0 +1,263 @@
+package node
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/akramboussanni/treenode/config"
+	"github.com/akramboussanni/treenode/internal/api"
+	"github.com/akramboussanni/treenode/internal/applog"
+	"github.com/akramboussanni/treenode/internal/dnsverify"
+	"github.com/akramboussanni/treenode/internal/model"
+	"github.com/akramboussanni/treenode/internal/utils"
+	"github.com/go-chi/chi/v5"
+)
+
+const domainTokenPrefix = "treenode-verification="
+
+// @Summary Get custom domain
+// @Description Get the node's custom domain and the DNS records that verify it (owner only)
+// @Tags nodes
+// @Produce json
+// @Param nodeID path string true "Node ID"
+// @Success 200 {object} DomainResponse
+// @Failure 400 {string} string "Bad request"
+// @Failure 401 {string} string "Unauthorized"
+// @Failure 403 {string} string "Forbidden"
+// @Failure 404 {string} string "Not found"
+// @Router /nodes/{nodeID}/domain [get]
+func (nr *NodeRouter) HandleGetDomain(w http.ResponseWriter, r *http.Request) {
+	node, ok := nr.ownedNode(w, r)
+	if !ok {
+		return
+	}
+
+	api.WriteJSON(w, 200, newDomainResponse(node))
+}
+
+// @Summary Set custom domain
+// @Description Attach a custom domain to the node (owner only). The domain starts unverified with a new verification token, publish it in a TXT record or point a CNAME at the node's subdomain and call the verify endpoint.
+// @Tags nodes
+// @Accept json
+// @Produce json
+// @Param nodeID path string true "Node ID"
+// @Param request body SetDomainRequest true "Custom domain"
+// @Success 200 {object} DomainResponse
+// @Failure 400 {object} api.ErrorResponse "Invalid domain"
+// @Failure 401 {string} string "Unauthorized"
+// @Failure 403 {string} string "Forbidden"
+// @Failure 404 {string} string "Not found"
+// @Failure 409 {object} api.ErrorResponse "Domain verified for another node"
+// @Failure 500 {string} string "Internal server error"
+// @Router /nodes/{nodeID}/domain [put]
+func (nr *NodeRouter) HandleSetDomain(w http.ResponseWriter, r *http.Request) {
+	var req SetDomainRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return
+	}
+
+	node, ok := nr.ownedNode(w, r)
+	if !ok {
+		return
+	}
+
+	domain, ok := normalizeDomain(req.Domain)
+	if !ok {
+		api.WriteMessage(w, 400, "error", "invalid domain")
+		return
+	}
+
+	taken, err := nr.NodeRepo.DomainVerifiedElsewhere(r.Context(), domain, node.ID)
+	if err != nil {
+		applog.Error("Failed to check domain:", err)
+		api.WriteInternalError(w)
+		return
+	}
+
+	if taken {
+		api.WriteMessage(w, 409, "error", "domain is already verified for another node")
+		return
+	}
+
+	b, err := utils.GenerateRandomBytes(16)
+	if err != nil {
+		applog.Error("Failed to generate domain token:", err)
+		api.WriteInternalError(w)
+		return
+	}
+
+	now := time.Now().UTC().Unix()
+	token := domainTokenPrefix + hex.EncodeToString(b)
+	if err := nr.NodeRepo.SetNodeDomain(r.Context(), node.ID, domain, token, now); err != nil {
+		applog.Error("Failed to set node domain:", err)
+		api.WriteInternalError(w)
+		return
+	}
+
+	node.Domain, node.DomainToken, node.DomainVerified, node.DomainVerifiedAt = domain, token, false, 0
+	applog.Info("Custom domain attached", "nodeID:", node.ID, "domain:", domain)
+	api.WriteJSON(w, 200, newDomainResponse(node))
+}
+
+// @Summary Verify custom domain
+// @Description Look up the verification TXT record or the CNAME for the node's custom domain and mark it verified if either matches (owner only). DNS changes can take a while to propagate.
+// @Tags nodes
+// @Produce json
+// @Param nodeID path string true "Node ID"
+// @Success 200 {object} DomainResponse
+// @Failure 400 {object} api.ErrorResponse "No custom domain set or no matching record found"
+// @Failure 401 {string} string "Unauthorized"
+// @Failure 403 {string} string "Forbidden"
+// @Failure 404 {string} string "Not found"
+// @Failure 409 {object} api.ErrorResponse "Domain verified for another node"
+// @Failure 502 {object} api.ErrorResponse "DNS lookup failed"
+// @Failure 500 {string} string "Internal server error"
+// @Router /nodes/{nodeID}/domain/verify [post]
+func (nr *NodeRouter) HandleVerifyDomain(w http.ResponseWriter, r *http.Request) {
+	node, ok := nr.ownedNode(w, r)
+	if !ok {
+		return
+	}
+
+	if node.Domain == "" {
+		api.WriteMessage(w, 400, "error", "no custom domain set")
+		return
+	}
+
+	taken, err := nr.NodeRepo.DomainVerifiedElsewhere(r.Context(), node.Domain, node.ID)
+	if err != nil {
+		applog.Error("Failed to check domain:", err)
+		api.WriteInternalError(w)
+		return
+	}
+
+	if taken {
+		api.WriteMessage(w, 409, "error", "domain is already verified for another node")
+		return
+	}
+
+	method, err := dnsverify.Verify(r.Context(), node.Domain, node.DomainToken, cnameTarget(node))
+	if errors.Is(err, dnsverify.ErrNoRecord) {
+		api.WriteMessage(w, 400, "error", "no matching TXT or CNAME record found")
+		return
+	}
+	if err != nil {
+		applog.Warn("DNS lookup failed", "domain:", node.Domain, "err:", err)
+		api.WriteMessage(w, 502, "error", "DNS lookup failed, try again later")
+		return
+	}
+
+	now := time.Now().UTC().Unix()
+	verified, err := nr.NodeRepo.MarkDomainVerified(r.Context(), node.ID, node.Domain, now)
+	if err != nil {
+		applog.Error("Failed to mark domain verified:", err)
+		api.WriteInternalError(w)
+		return
+	}
+
+	if !verified {
+		// the domain changed while it was being looked up
+		api.WriteMessage(w, 409, "error", "domain changed, please try again")
+		return
+	}
+
+	node.DomainVerified, node.DomainVerifiedAt = true, now
+	applog.Info("Custom domain verified", "nodeID:", node.ID, "domain:", node.Domain, "method:", method)
+	api.WriteJSON(w, 200, newDomainResponse(node))
+}
+
+// @Summary Remove custom domain
+// @Description Detach the node's custom domain (owner only)
+// @Tags nodes
+// @Produce json
+// @Param nodeID path string true "Node ID"
+// @Success 200 {object} api.SuccessResponse
+// @Failure 400 {string} string "Bad request"
+// @Failure 401 {string} string "Unauthorized"
+// @Failure 403 {string} string "Forbidden"
+// @Failure 404 {string} string "Not found"
+// @Failure 500 {string} string "Internal server error"
+// @Router /nodes/{nodeID}/domain [delete]
+func (nr *NodeRouter) HandleDeleteDomain(w http.ResponseWriter, r *http.Request) {
+	node, ok := nr.ownedNode(w, r)
+	if !ok {
+		return
+	}
+
+	if err := nr.NodeRepo.SetNodeDomain(r.Context(), node.ID, "", "", time.Now().UTC().Unix()); err != nil {
+		applog.Error("Failed to remove node domain:", err)
+		api.WriteInternalError(w)
+		return
+	}
+
+	applog.Info("Custom domain removed", "nodeID:", node.ID, "domain:", node.Domain)
+	api.WriteMessage(w, 200, "message", "custom domain removed")
+}
+
+// ownedNode loads the node in the url for its owner, writing the error response otherwise
+func (nr *NodeRouter) ownedNode(w http.ResponseWriter, r *http.Request) (*model.Node, bool) {
+	nodeID, err := utils.ParseID(chi.URLParam(r, "nodeID"))
+	if err != nil {
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return nil, false
+	}
+
+	user, ok := utils.UserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return nil, false
+	}
+
+	node, err := nr.NodeRepo.GetNodeByID(r.Context(), nodeID)
+	if err != nil {
+		http.Error(w, "not found", http.StatusNotFound)
+		return nil, false
+	}
+
+	if user.ID != node.OwnerID {
+		http.Error(w, "forbidden", http.StatusForbidden)
+		return nil, false
+	}
+
+	return node, true
+}
+
+// normalizeDomain lowercases the domain and checks it is a full hostname outside the
+// platform host, whose subdomains belong to nodes already
+func normalizeDomain(raw string) (string, bool) {
+	domain := strings.ToLower(strings.TrimSuffix(strings.TrimSpace(raw), "."))
+	if len(domain) > 253 || !strings.Contains(domain, ".") || !utils.IsValidDomain(domain) {
+		return "", false
+	}
+
+	if host := config.App.PlatformHost; host != "" && (domain == host || strings.HasSuffix(domain, "."+host)) {
+		return "", false
+	}
+
+	return domain, true
+}
+
+func cnameTarget(node *model.Node) string {
+	return dnsverify.CnameTarget(node.SubdomainName, config.App.PlatformHost)
+}
+
+func newDomainResponse(node *model.Node) DomainResponse {
+	resp := DomainResponse{
+		Domain:     node.Domain,
+		Verified:   node.DomainVerified,
+		VerifiedAt: node.DomainVerifiedAt,
+	}
+
+	if node.Domain != "" {
+		resp.TxtName = dnsverify.TxtName(node.Domain)
+		resp.TxtValue = node.DomainToken
+		resp.CnameTarget = cnameTarget(node)
+	}
+
+	return resp
+}
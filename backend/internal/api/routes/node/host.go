@@ -0,0 +1,175 @@
+package node
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/akramboussanni/treenode/config"
+	"github.com/akramboussanni/treenode/internal/api"
+	"github.com/akramboussanni/treenode/internal/applog"
+	"github.com/akramboussanni/treenode/internal/middleware"
+	"github.com/akramboussanni/treenode/internal/model"
+	"github.com/akramboussanni/treenode/internal/pages"
+	"github.com/akramboussanni/treenode/internal/repo"
+	"github.com/akramboussanni/treenode/internal/utils"
+	"github.com/go-chi/chi/v5"
+)
+
+// NewHostRouter serves a node's public page at the root of its own host, either
+// <subdomain>.<PLATFORM_HOST> or a verified custom domain. unknown hosts get a 404.
+func NewHostRouter(nodeRepo *repo.NodeRepo, linkRepo *repo.LinkRepo, publicationRepo *repo.PublicationRepo, subdomainRepo *repo.SubdomainRepo) http.Handler {
+	nr := &NodeRouter{NodeRepo: nodeRepo, LinkRepo: linkRepo, PublicationRepo: publicationRepo, SubdomainRepo: subdomainRepo}
+	r := chi.NewRouter()
+
+	middleware.AddRatelimit(r, 60, 1*time.Minute) // 60/min
+	r.Use(nr.hostNode)
+
+	r.Get("/", nr.HandleGetHostNode)
+	r.Get("/_links", nr.HandleGetHostLinks)
+	r.Get("/_og.png", nr.HandleGetHostImage)
+	r.Get("/{linkName}", nr.HandleGetHostLink)
+
+	r.NotFound(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "not found", http.StatusNotFound)
+	})
+
+	return r
+}
+
+// hostNode resolves the node from the Host header and stores it in the context
+func (nr *NodeRouter) hostNode(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host := utils.RequestHost(r)
+
+		var node *model.Node
+		var err error
+		if subdomain, ok := strings.CutSuffix(host, "."+config.App.PlatformHost); ok {
+			if subdomain == "" || strings.Contains(subdomain, ".") {
+				http.Error(w, "not found", http.StatusNotFound)
+				return
+			}
+			node, err = nr.NodeRepo.GetNodeBySubdomainFold(r.Context(), subdomain)
+			if errors.Is(err, sql.ErrNoRows) {
+				current, redirectErr := nr.SubdomainRepo.GetRedirect(r.Context(), subdomain)
+				if redirectErr == nil {
+					target := "https://" + strings.ToLower(current) + "." + config.App.PlatformHost + r.URL.RequestURI()
+					redirectRetired(w, r, target)
+					return
+				}
+				if !errors.Is(redirectErr, sql.ErrNoRows) {
+					err = redirectErr
+				}
+			}
+		} else {
+			node, err = nr.NodeRepo.GetNodeByDomain(r.Context(), host)
+		}
+
+		if errors.Is(err, sql.ErrNoRows) || (err == nil && node.TakenDownAt != 0) {
+			http.Error(w, "not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			applog.Error("Failed to resolve node from host:", err)
+			api.WriteInternalError(w)
+			return
+		}
+
+		ctx := context.WithValue(r.Context(), utils.NodeKey, node)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// @Summary Get the node of this host
+// @Description Get the node served on the request's host, a subdomain of the platform host or a verified custom domain. Returns its published HTML page, or its public information when the client only accepts JSON. Only available with host routing enabled.
+// @Tags public
+// @Produce html,json
+// @Success 200 {object} object
+// @Failure 404 {string} string "No node on this host"
+// @Failure 500 {string} string "Internal server error"
+// @Router / [get]
+func (nr *NodeRouter) HandleGetHostNode(w http.ResponseWriter, r *http.Request) {
+	node, _ := utils.NodeFromContext(r.Context())
+
+	links, err := nr.publishedLinks(r.Context(), node)
+	if err != nil {
+		applog.Error("Failed to load published page:", node.ID, err)
+		api.WriteInternalError(w)
+		return
+	}
+
+	// the same url serves html or json, caches have to keep them apart
+	w.Header().Add("Vary", "Accept")
+	if !wantsJSON(r) {
+		nr.writeNodePage(w, node, links, pages.NodeUrl(node), pages.NodeUrl(node)+"_og.png")
+		return
+	}
+
+	utils.StripUnsafeFields(node)
+	api.WriteJSON(w, 200, node)
+}
+
+// @Summary Get public links of the node of this host
+// @Description Get all visible and enabled published links of the node served on the request's host. Only available with host routing enabled.
+// @Tags public
+// @Produce json
+// @Success 200 {array} model.Link
+// @Failure 404 {string} string "No node on this host"
+// @Failure 500 {string} string "Internal server error"
+// @Router /_links [get]
+func (nr *NodeRouter) HandleGetHostLinks(w http.ResponseWriter, r *http.Request) {
+	node, _ := utils.NodeFromContext(r.Context())
+
+	links, err := nr.publishedLinks(r.Context(), node)
+	if err != nil {
+		applog.Error("Failed to load published page:", node.ID, err)
+		api.WriteInternalError(w)
+		return
+	}
+
+	api.WriteJSON(w, 200, links)
+}
+
+// @Summary Get the preview image of the node of this host
+// @Description Render the 1200x630 PNG preview card of the node served on the request's host, used as the Open Graph image of its page. Only available with host routing enabled.
+// @Tags public
+// @Produce png
+// @Success 200 {file} file "PNG image"
+// @Success 304 "Not modified"
+// @Failure 404 {string} string "No node on this host"
+// @Failure 500 {string} string "Internal server error"
+// @Router /_og.png [get]
+func (nr *NodeRouter) HandleGetHostImage(w http.ResponseWriter, r *http.Request) {
+	node, _ := utils.NodeFromContext(r.Context())
+	nr.writeNodeImage(w, r, node)
+}
+
+// @Summary Follow a link of the node of this host
+// @Description Redirect to the target of a visible and enabled link of the node served on the request's host. Only available with host routing enabled.
+// @Tags public
+// @Param linkName path string true "Link name"
+// @Success 307 "Redirect to the link target"
+// @Failure 404 {string} string "Not found"
+// @Failure 500 {string} string "Internal server error"
+// @Router /{linkName} [get]
+func (nr *NodeRouter) HandleGetHostLink(w http.ResponseWriter, r *http.Request) {
+	node, _ := utils.NodeFromContext(r.Context())
+
+	links, err := nr.publishedLinks(r.Context(), node)
+	if err != nil {
+		applog.Error("Failed to load published page:", node.ID, err)
+		api.WriteInternalError(w)
+		return
+	}
+
+	link := findLink(links, chi.URLParam(r, "linkName"))
+	if link == nil {
+		http.Error(w, "not found", http.StatusNotFound)
+		return
+	}
+
+	http.Redirect(w, r, link.Link, http.StatusTemporaryRedirect)
+}
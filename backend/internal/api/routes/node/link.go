@@ -94,6 +94,12 @@ func (nr *NodeRouter) HandleCreateLink(w http.ResponseWriter, r *http.Request) {
 		MiniBackgroundEnabled:         req.MiniBackgroundEnabled != nil && *req.MiniBackgroundEnabled,
 	}
 
+	if err := nr.beforeChange(r.Context(), nodeID); err != nil {
+		applog.Error("Failed to prepare draft:", err)
+		api.WriteInternalError(w)
+		return
+	}
+
 	err = nr.LinkRepo.CreateLink(r.Context(), link)
 	if err != nil {
 		applog.Error("Failed to create link:", err)
@@ -129,6 +135,8 @@ func (nr *NodeRouter) HandleCreateLink(w http.ResponseWriter, r *http.Request) {
 		applog.Error("Failed to load color stops:", err)
 	}
 
+	nr.recordRevision(r.Context(), nodeID, user.ID, model.RevisionLinkCreate)
+
 	api.WriteJSON(w, 201, link)
 }
 
@@ -336,6 +344,12 @@ func (nr *NodeRouter) HandleUpdateLink(w http.ResponseWriter, r *http.Request) {
 		link.MiniBackgroundEnabled = *req.MiniBackgroundEnabled
 	}
 
+	if err := nr.beforeChange(r.Context(), nodeID); err != nil {
+		applog.Error("Failed to prepare draft:", err)
+		api.WriteInternalError(w)
+		return
+	}
+
 	if len(req.ColorStops) > 0 {
 		err = nr.LinkRepo.DeleteColorStopsByLinkID(r.Context(), linkID)
 		if err != nil {
@@ -376,6 +390,8 @@ func (nr *NodeRouter) HandleUpdateLink(w http.ResponseWriter, r *http.Request) {
 		applog.Error("Failed to load color stops:", err)
 	}
 
+	nr.recordRevision(r.Context(), nodeID, user.ID, model.RevisionLinkUpdate)
+
 	api.WriteJSON(w, 200, link)
 }
 
@@ -429,6 +445,12 @@ func (nr *NodeRouter) HandleDeleteLink(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if err := nr.beforeChange(r.Context(), nodeID); err != nil {
+		applog.Error("Failed to prepare draft:", err)
+		api.WriteInternalError(w)
+		return
+	}
+
 	err = nr.LinkRepo.DeleteColorStopsByLinkID(r.Context(), linkID)
 	if err != nil {
 		applog.Error("Failed to delete color stops:", err)
@@ -441,6 +463,8 @@ func (nr *NodeRouter) HandleDeleteLink(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	nr.recordRevision(r.Context(), nodeID, user.ID, model.RevisionLinkDelete)
+
 	api.WriteMessage(w, 200, "message", "Link deleted successfully")
 }
 
@@ -517,6 +541,12 @@ func (nr *NodeRouter) HandleUpdateLinkName(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
+	if err := nr.beforeChange(r.Context(), nodeID); err != nil {
+		applog.Error("Failed to prepare draft:", err)
+		api.WriteInternalError(w)
+		return
+	}
+
 	err = nr.LinkRepo.UpdateLinkName(r.Context(), linkID, req.Name)
 	if err != nil {
 		applog.Error("Failed to update link name:", err)
@@ -524,6 +554,8 @@ func (nr *NodeRouter) HandleUpdateLinkName(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
+	nr.recordRevision(r.Context(), nodeID, user.ID, model.RevisionLinkRename)
+
 	api.WriteMessage(w, 200, "message", "Link name updated")
 }
 
@@ -605,6 +637,12 @@ func (nr *NodeRouter) HandleReorderLink(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
+	if err := nr.beforeChange(r.Context(), nodeID); err != nil {
+		applog.Error("Failed to prepare draft:", err)
+		api.WriteInternalError(w)
+		return
+	}
+
 	err = nr.LinkRepo.UpdateLinkOrder(r.Context(), linkID, req.NewPosition)
 	if err != nil {
 		applog.Error("Failed to update link order:", err)
@@ -612,5 +650,7 @@ func (nr *NodeRouter) HandleReorderLink(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
+	nr.recordRevision(r.Context(), nodeID, user.ID, model.RevisionLinkReorder)
+
 	api.WriteMessage(w, 200, "message", "Link reordered successfully")
 }
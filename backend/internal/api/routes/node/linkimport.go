@@ -0,0 +1,326 @@
+package node
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"html"
+	"io"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/akramboussanni/treenode/internal/api"
+	"github.com/akramboussanni/treenode/internal/applog"
+	"github.com/akramboussanni/treenode/internal/model"
+	"github.com/akramboussanni/treenode/internal/utils"
+	"github.com/go-chi/chi/v5"
+)
+
+const linkNameMaxLength = 50
+
+var (
+	bookmarkRegex = regexp.MustCompile(`(?is)<a\s([^>]*)>(.*?)</a>(?:\s*<dd>([^<]*))?`)
+	hrefRegex     = regexp.MustCompile(`(?is)\bhref\s*=\s*(?:"([^"]*)"|'([^']*)')`)
+	tagRegex      = regexp.MustCompile(`(?s)<[^>]*>`)
+	slugRegex     = regexp.MustCompile(`[^a-z0-9]+`)
+)
+
+// importedLink is a link read from an imported file, before validation
+type importedLink struct {
+	Name        string `json:"name"`
+	Title       string `json:"title"`
+	URL         string `json:"url"`
+	Description string `json:"description"`
+}
+
+// @Summary Import links
+// @Description Read links from a Netscape bookmarks HTML file (format=bookmarks), a CSV file with title, url and description columns (format=csv) or a JSON list of {"title", "url", "description", "name"} objects (format=json), posted as the body. Links without a name get one from their title. Items with a url that isn't http(s), mailto or tel, a duplicate name, an XSS pattern or a field too long are reported and skipped. With dry_run=true nothing is created, otherwise the other links are appended to the node in order in one transaction, visible and enabled.
+// @Tags links
+// @Accept plain
+// @Produce json
+// @Param nodeID path string true "Node ID"
+// @Param format query string true "bookmarks, csv or json"
+// @Param dry_run query bool false "Only preview the links"
+// @Success 200 {object} LinkImportResponse "Dry run"
+// @Success 201 {object} LinkImportResponse
+// @Failure 400 {object} api.ErrorResponse "Bad request or unreadable file"
+// @Failure 401 {string} string "Unauthorized"
+// @Failure 403 {string} string "Forbidden"
+// @Failure 500 {string} string "Internal server error"
+// @Router /nodes/{nodeID}/links/import [post]
+func (nr *NodeRouter) HandleImportLinks(w http.ResponseWriter, r *http.Request) {
+	nodeID, err := utils.ParseID(chi.URLParam(r, "nodeID"))
+	if err != nil {
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return
+	}
+
+	user, ok := utils.UserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	hasAccess, err := nr.NodeRepo.CheckNodeAccess(r.Context(), nodeID, user.ID)
+	if err != nil || !hasAccess {
+		http.Error(w, "forbidden", http.StatusForbidden)
+		return
+	}
+
+	dryRun := false
+	if raw := r.URL.Query().Get("dry_run"); raw != "" {
+		dryRun, err = strconv.ParseBool(raw)
+		if err != nil {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+	}
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return
+	}
+
+	var imported []importedLink
+	switch r.URL.Query().Get("format") {
+	case "bookmarks":
+		imported = parseBookmarks(body)
+	case "csv":
+		imported, err = parseLinksCSV(body)
+	case "json":
+		err = json.Unmarshal(body, &imported)
+	default:
+		api.WriteMessage(w, 400, "error", "format must be bookmarks, csv or json")
+		return
+	}
+	if err != nil {
+		api.WriteMessage(w, 400, "error", "Could not read the file: "+err.Error())
+		return
+	}
+
+	if len(imported) == 0 {
+		api.WriteMessage(w, 400, "error", "No links found in the file")
+		return
+	}
+	if len(imported) > bundleMaxLinks {
+		api.WriteMessage(w, 400, "error", fmt.Sprintf("At most %d links can be imported at once", bundleMaxLinks))
+		return
+	}
+
+	existing, err := nr.LinkRepo.GetLinksByNodeID(r.Context(), nodeID)
+	if err != nil {
+		applog.Error("Failed to get links:", err)
+		api.WriteInternalError(w)
+		return
+	}
+
+	taken := make(map[string]bool, len(existing)+len(imported))
+	for _, link := range existing {
+		taken[strings.ToLower(link.Name)] = true
+	}
+
+	now := time.Now().UTC().Unix()
+	res := LinkImportResponse{DryRun: dryRun, Items: make([]LinkImportItem, 0, len(imported))}
+	var links []model.Link
+	var created []int // item of each link
+	for i, il := range imported {
+		item := checkImportedLink(i, il, taken)
+		if len(item.Problems) > 0 {
+			res.Skipped++
+			res.Items = append(res.Items, item)
+			continue
+		}
+
+		taken[strings.ToLower(item.Name)] = true
+		links = append(links, model.Link{
+			ID:          utils.GenerateSnowflakeID(),
+			Name:        item.Name,
+			DisplayName: item.DisplayName,
+			Link:        item.Link,
+			Description: item.Description,
+			Visible:     true,
+			Enabled:     true,
+			CreatedAt:   now,
+			UpdatedAt:   now,
+		})
+		created = append(created, len(res.Items))
+		res.Items = append(res.Items, item)
+	}
+
+	if dryRun || len(links) == 0 {
+		api.WriteJSON(w, 200, res)
+		return
+	}
+
+	if err := nr.beforeChange(r.Context(), nodeID); err != nil {
+		applog.Error("Failed to prepare draft:", err)
+		api.WriteInternalError(w)
+		return
+	}
+
+	if err := nr.LinkRepo.CreateLinks(r.Context(), nodeID, links); err != nil {
+		applog.Error("Failed to import links:", err)
+		api.WriteInternalError(w)
+		return
+	}
+
+	for i, link := range links {
+		res.Items[created[i]].ID = link.ID
+	}
+	res.Created = len(links)
+
+	nr.recordRevision(r.Context(), nodeID, user.ID, model.RevisionLinkImport)
+
+	applog.Info("Links imported", "nodeID:", nodeID, "created:", res.Created, "skipped:", res.Skipped)
+	api.WriteJSON(w, 201, res)
+}
+
+// checkImportedLink cleans up an imported link and lists what keeps it from being
+// created. taken holds the lowercased names already used in the node.
+func checkImportedLink(index int, il importedLink, taken map[string]bool) LinkImportItem {
+	item := LinkImportItem{
+		Index:       index,
+		Name:        strings.TrimSpace(il.Name),
+		DisplayName: utils.SanitizeString(il.Title),
+		Link:        strings.TrimSpace(il.URL),
+		Description: utils.SanitizeString(il.Description),
+	}
+	if item.DisplayName == "" {
+		item.DisplayName = item.Link
+	}
+
+	if !utils.IsValidLinkURL(item.Link) {
+		item.Problems = append(item.Problems, "must be an http(s), mailto or tel url")
+	}
+	if utils.ContainsXSS(item.DisplayName) || utils.ContainsXSS(item.Description) || utils.ContainsXSS(item.Name) {
+		item.Problems = append(item.Problems, "contains a script or event handler")
+	}
+	if len(item.Link) > bundleMaxLongText {
+		item.Problems = append(item.Problems, fmt.Sprintf("url longer than %d characters", bundleMaxLongText))
+	}
+	if len(item.DisplayName) > bundleMaxText {
+		item.Problems = append(item.Problems, fmt.Sprintf("title longer than %d characters", bundleMaxText))
+	}
+	if len(item.Description) > bundleMaxLongText {
+		item.Problems = append(item.Problems, fmt.Sprintf("description longer than %d characters", bundleMaxLongText))
+	}
+
+	if item.Name != "" {
+		if len(item.Name) > bundleMaxText {
+			item.Problems = append(item.Problems, fmt.Sprintf("name longer than %d characters", bundleMaxText))
+		}
+		if taken[strings.ToLower(item.Name)] {
+			item.Problems = append(item.Problems, "duplicate name")
+		}
+		return item
+	}
+
+	// names are unique per node, so generated ones get a suffix instead
+	name, ok := freeLinkName(linkSlug(item.DisplayName), taken)
+	if !ok {
+		item.Problems = append(item.Problems, "no free name left")
+	}
+	item.Name = name
+	return item
+}
+
+// linkSlug turns a title into a link name, "link" when nothing usable is left
+func linkSlug(title string) string {
+	slug := strings.Trim(slugRegex.ReplaceAllString(strings.ToLower(title), "-"), "-")
+	if len(slug) > linkNameMaxLength {
+		slug = strings.TrimRight(slug[:linkNameMaxLength], "-")
+	}
+	if slug == "" {
+		return "link"
+	}
+	return slug
+}
+
+// parseBookmarks reads the links of a Netscape bookmarks file, the format browsers and
+// most link-in-bio tools export. folders are flattened.
+func parseBookmarks(body []byte) []importedLink {
+	var links []importedLink
+	for _, m := range bookmarkRegex.FindAllSubmatch(body, -1) {
+		href := hrefRegex.FindSubmatch(m[1])
+		if href == nil {
+			continue
+		}
+
+		links = append(links, importedLink{
+			Title:       html.UnescapeString(string(tagRegex.ReplaceAll(m[2], nil))),
+			URL:         html.UnescapeString(string(append(href[1], href[2]...))),
+			Description: html.UnescapeString(string(m[3])),
+		})
+	}
+	return links
+}
+
+// parseLinksCSV reads title, url and description columns. a header row can name them in
+// any order, as title/display_name, url/link/href, description and name.
+func parseLinksCSV(body []byte) ([]importedLink, error) {
+	reader := csv.NewReader(bytes.NewReader(bytes.TrimPrefix(body, []byte("\xef\xbb\xbf"))))
+	reader.FieldsPerRecord = -1
+	reader.LazyQuotes = true
+
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(records) == 0 {
+		return nil, nil
+	}
+
+	columns := map[string]int{"title": 0, "url": 1, "description": 2, "name": -1}
+	if header, ok := csvHeader(records[0]); ok {
+		columns = header
+		records = records[1:]
+	}
+
+	field := func(record []string, column string) string {
+		i := columns[column]
+		if i < 0 || i >= len(record) {
+			return ""
+		}
+		return record[i]
+	}
+
+	links := make([]importedLink, 0, len(records))
+	for _, record := range records {
+		if len(record) == 1 && strings.TrimSpace(record[0]) == "" {
+			continue
+		}
+		links = append(links, importedLink{
+			Name:        field(record, "name"),
+			Title:       field(record, "title"),
+			URL:         field(record, "url"),
+			Description: field(record, "description"),
+		})
+	}
+	return links, nil
+}
+
+// csvHeader maps the columns of a header row, false when the row has no url column
+func csvHeader(row []string) (map[string]int, bool) {
+	columns := map[string]int{"title": -1, "url": -1, "description": -1, "name": -1}
+	for i, cell := range row {
+		switch strings.ToLower(strings.TrimSpace(cell)) {
+		case "title", "display_name":
+			columns["title"] = i
+		case "url", "link", "href":
+			columns["url"] = i
+		case "description":
+			columns["description"] = i
+		case "name":
+			columns["name"] = i
+		}
+	}
+	if columns["url"] < 0 {
+		return nil, false
+	}
+	return columns, true
+}
@@ -6,6 +6,13 @@ type CreateNodeRequest struct {
 	SubdomainName string `json:"subdomain_name" binding:"required"`
 }
 
+type CloneNodeRequest struct {
+	SubdomainName        string `json:"subdomain_name" binding:"required" example:"summit-2026"`
+	IncludeHidden        bool   `json:"include_hidden" example:"false"`        // copy links that aren't visible
+	IncludeDisabled      bool   `json:"include_disabled" example:"false"`      // copy links that aren't enabled
+	IncludeCollaborators bool   `json:"include_collaborators" example:"false"` // owner only
+}
+
 type UpdateNodeRequest struct {
 	DisplayName         string `json:"display_name"`
 	Description         string `json:"description"`
@@ -97,3 +104,107 @@ type SharedNodeGroup struct {
 	OwnerName string       `json:"owner_name"`
 	Nodes     []model.Node `json:"nodes"`
 }
+
+type SetDomainRequest struct {
+	Domain string `json:"domain" binding:"required" example:"links.example.com"`
+}
+
+// @Description Custom domain of a node. verify it with a TXT record at txt_name holding txt_value, or a CNAME to cname_target
+type DomainResponse struct {
+	Domain      string `json:"domain" example:"links.example.com"`
+	Verified    bool   `json:"verified" example:"false"`
+	VerifiedAt  int64  `json:"verified_at,string" example:"0"`
+	TxtName     string `json:"txt_name,omitempty" example:"_treenode.links.example.com"`
+	TxtValue    string `json:"txt_value,omitempty" example:"treenode-verification=3f2a..."`
+	CnameTarget string `json:"cname_target,omitempty" example:"johndoe.treenode.app"`
+}
+
+// @Description A revision with the snapshot of the node's page settings and links it recorded
+type RevisionResponse struct {
+	model.NodeRevision
+	Snapshot model.NodeSnapshot `json:"snapshot"`
+}
+
+type FieldChange struct {
+	Field string `json:"field" example:"display_name"`
+	From  any    `json:"from"`
+	To    any    `json:"to"`
+}
+
+// @Description A link in a diff, with the fields that changed when it's in links_changed
+type LinkChange struct {
+	ID          int64         `json:"id,string" example:"1234567890123456789"`
+	Name        string        `json:"name" example:"github"`
+	DisplayName string        `json:"display_name" example:"GitHub"`
+	Changes     []FieldChange `json:"changes,omitempty"`
+}
+
+// @Description Differences between two revisions, to is 0 when comparing with the current state
+type RevisionDiffResponse struct {
+	From         int64         `json:"from,string" example:"1234567890123456789"`
+	To           int64         `json:"to,string" example:"0"`
+	Node         []FieldChange `json:"node"`
+	LinksAdded   []LinkChange  `json:"links_added"`
+	LinksRemoved []LinkChange  `json:"links_removed"`
+	LinksChanged []LinkChange  `json:"links_changed"`
+}
+
+type PublishRequest struct {
+	PublishAt int64 `json:"publish_at,string,omitempty" example:"1767225600"` // unix time, now when omitted or in the past
+}
+
+// @Description Publication state of a node, scheduled_at is 0 when nothing is scheduled
+type PublicationResponse struct {
+	model.NodePublication
+	HasChanges bool `json:"has_changes" example:"true"` // the draft differs from the published page
+}
+
+type PreviewResponse struct {
+	Token     string `json:"token"`
+	Url       string `json:"url" example:"https://api.treenode.app/nodes/public/preview/eyJhbGciOi..."` // empty when PUBLIC_API_URL is unset
+	ExpiresAt int64  `json:"expires_at,string" example:"1767225600"`
+}
+
+// @Description A node's draft with its visible links
+type DraftPageResponse struct {
+	Node  *model.Node  `json:"node"`
+	Links []model.Link `json:"links"`
+}
+
+// @Description Result of an import. renamed lists the subdomain and link names changed to avoid conflicts, errors the skipped fields, links and color stops
+type ImportResponse struct {
+	Node          *model.Node    `json:"node,omitempty"`
+	LinksImported int            `json:"links_imported" example:"12"`
+	Renamed       []ImportRename `json:"renamed,omitempty"`
+	Errors        []ImportError  `json:"errors,omitempty"`
+}
+
+type ImportRename struct {
+	Item string `json:"item" example:"links[2]"`
+	From string `json:"from" example:"github"`
+	To   string `json:"to" example:"github-2"`
+}
+
+type ImportError struct {
+	Item    string `json:"item" example:"links[2].color_stops[0]"`
+	Field   string `json:"field" example:"color"`
+	Message string `json:"message" example:"not a valid color"`
+}
+
+// @Description Links read from an imported file. items with problems are skipped, the others are created unless dry_run is set
+type LinkImportResponse struct {
+	DryRun  bool             `json:"dry_run" example:"true"`
+	Created int              `json:"created" example:"12"`
+	Skipped int              `json:"skipped" example:"1"`
+	Items   []LinkImportItem `json:"items"`
+}
+
+type LinkImportItem struct {
+	Index       int      `json:"index" example:"0"` // position in the file
+	ID          int64    `json:"id,string,omitempty" example:"1234567890123456789"`
+	Name        string   `json:"name" example:"github"`
+	DisplayName string   `json:"display_name" example:"GitHub"`
+	Link        string   `json:"link" example:"https://github.com/johndoe"`
+	Description string   `json:"description"`
+	Problems    []string `json:"problems,omitempty" example:"duplicate name"`
+}
@@ -39,25 +39,7 @@ func (nr *NodeRouter) HandleCreateNode(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	reservedKeywords := []string{"dashboard", "login", "register", "confirm", "nodes", "api", "admin", "settings", "profile", "account"}
-	for _, keyword := range reservedKeywords {
-		if strings.EqualFold(req.SubdomainName, keyword) {
-			applog.Warn("Reserved keyword used for subdomain name", "subdomain:", req.SubdomainName)
-			api.WriteMessage(w, 400, "error", "This name is reserved and cannot be used")
-			return
-		}
-	}
-
-	duplicate, err := nr.NodeRepo.DuplicateSubdomain(r.Context(), req.SubdomainName)
-	if err != nil {
-		applog.Error("Failed to check duplicate subdomain:", err)
-		api.WriteInternalError(w)
-		return
-	}
-
-	if duplicate {
-		applog.Warn("Duplicate subdomain name creation attempt", "subdomain:", req.SubdomainName)
-		api.WriteMessage(w, 409, "error", "Subdomain name already exists")
+	if !nr.checkNewSubdomain(w, r, req.SubdomainName) {
 		return
 	}
 
@@ -90,13 +72,15 @@ func (nr *NodeRouter) HandleCreateNode(w http.ResponseWriter, r *http.Request) {
 		UpdatedAt:           time.Now().UTC().Unix(),
 	}
 
-	err = nr.NodeRepo.CreateNode(r.Context(), node)
+	err := nr.NodeRepo.CreateNode(r.Context(), node)
 	if err != nil {
 		applog.Error("Failed to create node:", err)
 		api.WriteInternalError(w)
 		return
 	}
 
+	nr.recordRevision(r.Context(), node.ID, user.ID, model.RevisionNodeCreate)
+
 	api.WriteJSON(w, 201, node)
 }
 
@@ -231,7 +215,7 @@ func (nr *NodeRouter) HandleGetNode(w http.ResponseWriter, r *http.Request) {
 }
 
 // @Summary Update a node
-// @Description Update a node (owner only)
+// @Description Update a node (owner only). Renaming the subdomain keeps the old one redirecting to the new one and held back from other nodes, see /nodes/{nodeID}/subdomains
 // @Tags nodes
 // @Accept json
 // @Produce json
@@ -242,7 +226,7 @@ func (nr *NodeRouter) HandleGetNode(w http.ResponseWriter, r *http.Request) {
 // @Failure 401 {string} string "Unauthorized"
 // @Failure 403 {string} string "Forbidden"
 // @Failure 404 {string} string "Not found"
-// @Failure 409 {object} map[string]string "Subdomain name already exists"
+// @Failure 409 {object} map[string]string "Subdomain name already exists or is held"
 // @Failure 500 {string} string "Internal server error"
 // @Router /nodes/{nodeID} [put]
 func (nr *NodeRouter) HandleUpdateNode(w http.ResponseWriter, r *http.Request) {
@@ -276,9 +260,9 @@ func (nr *NodeRouter) HandleUpdateNode(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	var retired *model.RetiredSubdomain
 	if req.SubdomainName != "" && req.SubdomainName != node.SubdomainName {
-		reservedKeywords := []string{"dashboard", "login", "register", "confirm", "nodes", "api", "admin", "settings", "profile", "account"}
-		for _, keyword := range reservedKeywords {
+		for _, keyword := range reservedSubdomains {
 			if strings.EqualFold(req.SubdomainName, keyword) {
 				applog.Warn("Reserved keyword used for subdomain name update", "subdomain:", req.SubdomainName)
 				api.WriteMessage(w, 400, "error", "This name is reserved and cannot be used")
@@ -298,6 +282,12 @@ func (nr *NodeRouter) HandleUpdateNode(w http.ResponseWriter, r *http.Request) {
 			api.WriteMessage(w, 409, "error", "Subdomain name already exists")
 			return
 		}
+
+		if !nr.checkSubdomainHeld(w, r, req.SubdomainName, nodeID) {
+			return
+		}
+
+		retired = retiredSubdomain(nodeID, node.SubdomainName)
 	}
 
 	// i will have to rework it someday
@@ -345,13 +335,25 @@ func (nr *NodeRouter) HandleUpdateNode(w http.ResponseWriter, r *http.Request) {
 	}
 	node.UpdatedAt = time.Now().UTC().Unix()
 
-	err = nr.NodeRepo.UpdateNode(r.Context(), node)
+	if err := nr.beforeChange(r.Context(), nodeID); err != nil {
+		applog.Error("Failed to prepare draft:", err)
+		api.WriteInternalError(w)
+		return
+	}
+
+	if retired != nil {
+		err = nr.NodeRepo.RenameNode(r.Context(), node, retired)
+	} else {
+		err = nr.NodeRepo.UpdateNode(r.Context(), node)
+	}
 	if err != nil {
 		applog.Error("Failed to update node:", err)
 		api.WriteInternalError(w)
 		return
 	}
 
+	nr.recordRevision(r.Context(), nodeID, user.ID, model.RevisionNodeUpdate)
+
 	api.WriteJSON(w, 200, node)
 }
 
@@ -646,3 +648,33 @@ func (nr *NodeRouter) HandleGetCollaborators(w http.ResponseWriter, r *http.Requ
 
 	api.WriteJSON(w, 200, collaboratorUsers)
 }
+
+// subdomain names taken by frontend routes
+var reservedSubdomains = []string{"dashboard", "login", "register", "confirm", "nodes", "api", "admin", "settings", "profile", "account"}
+
+// checkNewSubdomain checks a subdomain for a new node isn't reserved or taken, writing
+// the error response otherwise
+func (nr *NodeRouter) checkNewSubdomain(w http.ResponseWriter, r *http.Request, subdomain string) bool {
+	for _, keyword := range reservedSubdomains {
+		if strings.EqualFold(subdomain, keyword) {
+			applog.Warn("Reserved keyword used for subdomain name", "subdomain:", subdomain)
+			api.WriteMessage(w, 400, "error", "This name is reserved and cannot be used")
+			return false
+		}
+	}
+
+	duplicate, err := nr.NodeRepo.DuplicateSubdomain(r.Context(), subdomain)
+	if err != nil {
+		applog.Error("Failed to check duplicate subdomain:", err)
+		api.WriteInternalError(w)
+		return false
+	}
+
+	if duplicate {
+		applog.Warn("Duplicate subdomain name creation attempt", "subdomain:", subdomain)
+		api.WriteMessage(w, 409, "error", "Subdomain name already exists")
+		return false
+	}
+
+	return nr.checkSubdomainHeld(w, r, subdomain, 0)
+}
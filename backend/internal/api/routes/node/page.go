@@ -0,0 +1,154 @@
+package node
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/akramboussanni/treenode/config"
+	"github.com/akramboussanni/treenode/internal/api"
+	"github.com/akramboussanni/treenode/internal/applog"
+	"github.com/akramboussanni/treenode/internal/model"
+	"github.com/akramboussanni/treenode/internal/ogimage"
+	"github.com/akramboussanni/treenode/internal/pages"
+	"github.com/go-chi/chi/v5"
+)
+
+// @Summary Get the HTML page of a node by subdomain
+// @Description Render the node's public link page as a complete HTML document that works without javascript, with Open Graph and Twitter meta tags (no authentication required)
+// @Tags public
+// @Produce html
+// @Param subdomain path string true "Subdomain name"
+// @Success 200 {string} string "HTML page"
+// @Success 307 "Redirect from a previous subdomain of the node"
+// @Failure 404 {string} string "Not found"
+// @Failure 500 {string} string "Internal server error"
+// @Router /nodes/public/subdomain/{subdomain}/page [get]
+func (nr *NodeRouter) HandleGetNodePageBySubdomain(w http.ResponseWriter, r *http.Request) {
+	subdomain := chi.URLParam(r, "subdomain")
+	if subdomain == "" {
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return
+	}
+
+	node, ok := nr.publicNode(w, r, subdomain)
+	if !ok {
+		return
+	}
+
+	links, err := nr.publishedLinks(r.Context(), node)
+	if err != nil {
+		applog.Error("Failed to load published page:", node.ID, err)
+		api.WriteInternalError(w)
+		return
+	}
+
+	nr.writeNodePage(w, node, links, pages.NodeUrl(node), nodeImageUrl(node))
+}
+
+// @Summary Get the preview image of a node by subdomain
+// @Description Render a 1200x630 PNG card with the node's name, description and first links, used as the Open Graph image of its page (no authentication required)
+// @Tags public
+// @Produce png
+// @Param subdomain path string true "Subdomain name"
+// @Success 200 {file} file "PNG image"
+// @Success 304 "Not modified"
+// @Success 307 "Redirect from a previous subdomain of the node"
+// @Failure 404 {string} string "Not found"
+// @Failure 500 {string} string "Internal server error"
+// @Router /nodes/public/subdomain/{subdomain}/og.png [get]
+func (nr *NodeRouter) HandleGetNodeImageBySubdomain(w http.ResponseWriter, r *http.Request) {
+	subdomain := chi.URLParam(r, "subdomain")
+	if subdomain == "" {
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return
+	}
+
+	node, ok := nr.publicNode(w, r, subdomain)
+	if !ok {
+		return
+	}
+
+	nr.writeNodeImage(w, r, node)
+}
+
+// nodeImageUrl is the address of the node's preview image, from PUBLIC_API_URL or its
+// own host with host routing. empty when neither is known.
+func nodeImageUrl(node *model.Node) string {
+	if image := pages.ApiUrl("/nodes/public/subdomain/" + url.PathEscape(node.SubdomainName) + "/og.png"); image != "" {
+		return image
+	}
+	if config.App.HostRouting && pages.NodeUrl(node) != "" {
+		return pages.NodeUrl(node) + "_og.png"
+	}
+	return ""
+}
+
+// writeNodePage renders the node with its visible links as an HTML page
+func (nr *NodeRouter) writeNodePage(w http.ResponseWriter, node *model.Node, links []model.Link, pageUrl, imageUrl string) {
+	page, err := pages.RenderNode(node, links, pageUrl, imageUrl)
+	if err != nil {
+		applog.Error("Failed to render node page:", node.ID, err)
+		api.WriteInternalError(w)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(200)
+	w.Write(page)
+}
+
+// writeNodeImage serves the preview image of the node's published page, rendering it
+// when the cached one is missing or was drawn from different content
+func (nr *NodeRouter) writeNodeImage(w http.ResponseWriter, r *http.Request, node *model.Node) {
+	links, err := nr.publishedLinks(r.Context(), node)
+	if err != nil {
+		applog.Error("Failed to load published page:", node.ID, err)
+		api.WriteInternalError(w)
+		return
+	}
+
+	card := ogimage.Card{
+		DisplayName: node.DisplayName,
+		Description: node.Description,
+		Background:  node.BackgroundColor,
+		Accent:      node.AccentColor,
+		Footer:      strings.TrimSuffix(strings.TrimPrefix(pages.NodeUrl(node), "https://"), "/"),
+	}
+	for _, link := range links {
+		if !link.Mini {
+			card.Links = append(card.Links, link.DisplayName)
+		}
+	}
+
+	key := card.Key()
+	etag := `"` + key + `"`
+	w.Header().Set("Cache-Control", "public, max-age=600")
+	w.Header().Set("ETag", etag)
+	if r.Header.Get("If-None-Match") == etag {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	img, ok := ogimage.Cached(node.ID, key)
+	if !ok {
+		img, err = ogimage.Render(card)
+		if err != nil {
+			applog.Error("Failed to render preview image:", node.ID, err)
+			api.WriteInternalError(w)
+			return
+		}
+		ogimage.Store(node.ID, key, img)
+	}
+
+	w.Header().Set("Content-Type", "image/png")
+	w.WriteHeader(200)
+	w.Write(img)
+}
+
+// wantsJSON reports whether the client asked for json rather than a page, browsers and
+// crawlers accept html and get the page
+func wantsJSON(r *http.Request) bool {
+	accept := r.Header.Get("Accept")
+	return strings.Contains(accept, "application/json") && !strings.Contains(accept, "text/html")
+}
@@ -10,7 +10,7 @@ import (
 )
 
 // @Summary Get public node information
-// @Description Get public information about a node (no authentication required)
+// @Description Get the published information about a node (no authentication required)
 // @Tags public
 // @Produce json
 // @Param nodeID path string true "Node ID"
@@ -26,17 +26,23 @@ func (nr *NodeRouter) HandleGetPublicNode(w http.ResponseWriter, r *http.Request
 	}
 
 	node, err := nr.NodeRepo.GetNodeByID(r.Context(), nodeID)
-	if err != nil {
+	if err != nil || node.TakenDownAt != 0 {
 		http.Error(w, "not found", http.StatusNotFound)
 		return
 	}
 
+	if _, err := nr.publishedSnapshot(r.Context(), node); err != nil {
+		applog.Error("Failed to load published page:", node.ID, err)
+		api.WriteInternalError(w)
+		return
+	}
+
 	utils.StripUnsafeFields(node)
 	api.WriteJSON(w, 200, node)
 }
 
 // @Summary Get public links for a node
-// @Description Get all visible and enabled links for a node (no authentication required)
+// @Description Get all visible and enabled links for a node as last published (no authentication required)
 // @Tags public
 // @Produce json
 // @Param nodeID path string true "Node ID"
@@ -51,17 +57,17 @@ func (nr *NodeRouter) HandleGetPublicLinks(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
-	links, err := nr.LinkRepo.GetVisibleLinksByNodeID(r.Context(), nodeID)
-	if err != nil {
-		api.WriteInternalError(w)
+	node, err := nr.NodeRepo.GetNodeByID(r.Context(), nodeID)
+	if err != nil || node.TakenDownAt != 0 {
+		http.Error(w, "not found", http.StatusNotFound)
 		return
 	}
 
-	for i := range links {
-		err = nr.LinkRepo.LoadColorStops(r.Context(), &links[i])
-		if err != nil {
-			applog.Error("Failed to load color stops for link:", links[i].ID, err)
-		}
+	links, err := nr.publishedLinks(r.Context(), node)
+	if err != nil {
+		applog.Error("Failed to load published page:", node.ID, err)
+		api.WriteInternalError(w)
+		return
 	}
 
 	api.WriteJSON(w, 200, links)
@@ -85,21 +91,35 @@ func (nr *NodeRouter) HandleGetPublicLink(w http.ResponseWriter, r *http.Request
 
 	linkName := chi.URLParam(r, "linkName")
 
-	link, err := nr.LinkRepo.GetLinkRedirectByNameAndNodeID(r.Context(), linkName, nodeID)
+	node, err := nr.NodeRepo.GetNodeByID(r.Context(), nodeID)
+	if err != nil || node.TakenDownAt != 0 {
+		http.Error(w, "not found", http.StatusNotFound)
+		return
+	}
+
+	links, err := nr.publishedLinks(r.Context(), node)
 	if err != nil {
+		applog.Error("Failed to load published page:", node.ID, err)
 		api.WriteInternalError(w)
 		return
 	}
 
-	http.Redirect(w, r, link, http.StatusTemporaryRedirect)
+	link := findLink(links, linkName)
+	if link == nil {
+		http.Error(w, "not found", http.StatusNotFound)
+		return
+	}
+
+	http.Redirect(w, r, link.Link, http.StatusTemporaryRedirect)
 }
 
 // @Summary Get public node information by subdomain
-// @Description Get public information about a node by subdomain (no authentication required)
+// @Description Get the published information about a node by subdomain (no authentication required)
 // @Tags public
 // @Produce json
 // @Param subdomain path string true "Subdomain name"
 // @Success 200 {object} object
+// @Success 307 "Redirect from a previous subdomain of the node"
 // @Failure 404 {string} string "Not found"
 // @Router /nodes/public/subdomain/{subdomain} [get]
 func (nr *NodeRouter) HandleGetNodeBySubdomain(w http.ResponseWriter, r *http.Request) {
@@ -109,9 +129,14 @@ func (nr *NodeRouter) HandleGetNodeBySubdomain(w http.ResponseWriter, r *http.Re
 		return
 	}
 
-	node, err := nr.NodeRepo.GetNodeBySubdomain(r.Context(), subdomain)
-	if err != nil {
-		http.Error(w, "not found", http.StatusNotFound)
+	node, ok := nr.publicNode(w, r, subdomain)
+	if !ok {
+		return
+	}
+
+	if _, err := nr.publishedSnapshot(r.Context(), node); err != nil {
+		applog.Error("Failed to load published page:", node.ID, err)
+		api.WriteInternalError(w)
 		return
 	}
 
@@ -120,11 +145,12 @@ func (nr *NodeRouter) HandleGetNodeBySubdomain(w http.ResponseWriter, r *http.Re
 }
 
 // @Summary Get public links for a node by subdomain
-// @Description Get all visible and enabled links for a node by subdomain (no authentication required)
+// @Description Get all visible and enabled links for a node by subdomain as last published (no authentication required)
 // @Tags public
 // @Produce json
 // @Param subdomain path string true "Subdomain name"
 // @Success 200 {array} model.Link
+// @Success 307 "Redirect from a previous subdomain of the node"
 // @Failure 500 {string} string "Internal server error"
 // @Router /nodes/public/subdomain/{subdomain}/links [get]
 func (nr *NodeRouter) HandleGetPublicLinksBySubdomain(w http.ResponseWriter, r *http.Request) {
@@ -134,34 +160,28 @@ func (nr *NodeRouter) HandleGetPublicLinksBySubdomain(w http.ResponseWriter, r *
 		return
 	}
 
-	node, err := nr.NodeRepo.GetNodeBySubdomain(r.Context(), subdomain)
-	if err != nil {
-		http.Error(w, "not found", http.StatusNotFound)
+	node, ok := nr.publicNode(w, r, subdomain)
+	if !ok {
 		return
 	}
 
-	links, err := nr.LinkRepo.GetVisibleLinksByNodeID(r.Context(), node.ID)
+	links, err := nr.publishedLinks(r.Context(), node)
 	if err != nil {
+		applog.Error("Failed to load published page:", node.ID, err)
 		api.WriteInternalError(w)
 		return
 	}
 
-	for i := range links {
-		err = nr.LinkRepo.LoadColorStops(r.Context(), &links[i])
-		if err != nil {
-			applog.Error("Failed to load color stops for link:", links[i].ID, err)
-		}
-	}
-
 	api.WriteJSON(w, 200, links)
 }
 
 // @Summary Get public node information by name
-// @Description Get public information about a node by name (no authentication required)
+// @Description Get the published information about a node by name (no authentication required)
 // @Tags public
 // @Produce json
 // @Param name path string true "Node name"
 // @Success 200 {object} object
+// @Success 307 "Redirect from a previous subdomain of the node"
 // @Failure 404 {string} string "Not found"
 // @Router /nodes/public/name/{name} [get]
 func (nr *NodeRouter) HandleGetNodeByName(w http.ResponseWriter, r *http.Request) {
@@ -171,9 +191,14 @@ func (nr *NodeRouter) HandleGetNodeByName(w http.ResponseWriter, r *http.Request
 		return
 	}
 
-	node, err := nr.NodeRepo.GetNodeBySubdomain(r.Context(), name)
-	if err != nil {
-		http.Error(w, "not found", http.StatusNotFound)
+	node, ok := nr.publicNode(w, r, name)
+	if !ok {
+		return
+	}
+
+	if _, err := nr.publishedSnapshot(r.Context(), node); err != nil {
+		applog.Error("Failed to load published page:", node.ID, err)
+		api.WriteInternalError(w)
 		return
 	}
 
@@ -182,11 +207,12 @@ func (nr *NodeRouter) HandleGetNodeByName(w http.ResponseWriter, r *http.Request
 }
 
 // @Summary Get public links for a node by name
-// @Description Get all visible and enabled links for a node by name (no authentication required)
+// @Description Get all visible and enabled links for a node by name as last published (no authentication required)
 // @Tags public
 // @Produce json
 // @Param name path string true "Node name"
 // @Success 200 {array} model.Link
+// @Success 307 "Redirect from a previous subdomain of the node"
 // @Failure 500 {string} string "Internal server error"
 // @Router /nodes/public/name/{name}/links [get]
 func (nr *NodeRouter) HandleGetPublicLinksByName(w http.ResponseWriter, r *http.Request) {
@@ -196,35 +222,29 @@ func (nr *NodeRouter) HandleGetPublicLinksByName(w http.ResponseWriter, r *http.
 		return
 	}
 
-	node, err := nr.NodeRepo.GetNodeBySubdomain(r.Context(), name)
-	if err != nil {
-		http.Error(w, "not found", http.StatusNotFound)
+	node, ok := nr.publicNode(w, r, name)
+	if !ok {
 		return
 	}
 
-	links, err := nr.LinkRepo.GetVisibleLinksByNodeID(r.Context(), node.ID)
+	links, err := nr.publishedLinks(r.Context(), node)
 	if err != nil {
+		applog.Error("Failed to load published page:", node.ID, err)
 		api.WriteInternalError(w)
 		return
 	}
 
-	for i := range links {
-		err = nr.LinkRepo.LoadColorStops(r.Context(), &links[i])
-		if err != nil {
-			applog.Error("Failed to load color stops for link:", links[i].ID, err)
-		}
-	}
-
 	api.WriteJSON(w, 200, links)
 }
 
 // @Summary Get public link information by subdomain and link name
-// @Description Get public information about a specific link by subdomain and link name (no authentication required)
+// @Description Get the published information about a specific link by subdomain and link name (no authentication required)
 // @Tags public
 // @Produce json
 // @Param subdomain path string true "Subdomain name"
 // @Param linkName path string true "Link name"
 // @Success 200 {object} model.Link
+// @Success 307 "Redirect from a previous subdomain of the node"
 // @Failure 404 {string} string "Not found"
 // @Failure 500 {string} string "Internal server error"
 // @Router /nodes/public/subdomain/{subdomain}/links/{linkName} [get]
@@ -241,28 +261,24 @@ func (nr *NodeRouter) HandleGetPublicLinkBySubdomain(w http.ResponseWriter, r *h
 		return
 	}
 
-	node, err := nr.NodeRepo.GetNodeBySubdomain(r.Context(), subdomain)
-	if err != nil {
-		http.Error(w, "not found", http.StatusNotFound)
+	node, ok := nr.publicNode(w, r, subdomain)
+	if !ok {
 		return
 	}
 
-	link, err := nr.LinkRepo.GetLinkByNameAndNodeID(r.Context(), linkName, node.ID)
+	links, err := nr.publishedLinks(r.Context(), node)
 	if err != nil {
-		http.Error(w, "not found", http.StatusNotFound)
+		applog.Error("Failed to load published page:", node.ID, err)
+		api.WriteInternalError(w)
 		return
 	}
 
-	// Check if link is visible and enabled
-	if !link.Visible || !link.Enabled {
+	// only visible and enabled links are published
+	link := findLink(links, linkName)
+	if link == nil {
 		http.Error(w, "not found", http.StatusNotFound)
 		return
 	}
 
-	err = nr.LinkRepo.LoadColorStops(r.Context(), link)
-	if err != nil {
-		applog.Error("Failed to load color stops for link:", link.ID, err)
-	}
-
 	api.WriteJSON(w, 200, link)
 }
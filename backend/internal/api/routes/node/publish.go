@@ -0,0 +1,431 @@
+package node
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/akramboussanni/treenode/config"
+	"github.com/akramboussanni/treenode/internal/api"
+	"github.com/akramboussanni/treenode/internal/applog"
+	"github.com/akramboussanni/treenode/internal/jwt"
+	"github.com/akramboussanni/treenode/internal/model"
+	"github.com/akramboussanni/treenode/internal/pages"
+	"github.com/akramboussanni/treenode/internal/utils"
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+)
+
+// how far ahead a publication can be scheduled
+const maxPublishDelay = 365 * 24 * 60 * 60
+
+// @Summary Get publication status
+// @Description Get when the node was last published, the publication scheduled for it and whether the draft has unpublished changes (owner and collaborators). published_at is 0 for nodes that were never edited as a draft, they're public as they are.
+// @Tags publishing
+// @Produce json
+// @Param nodeID path string true "Node ID"
+// @Success 200 {object} PublicationResponse
+// @Failure 400 {string} string "Bad request"
+// @Failure 401 {string} string "Unauthorized"
+// @Failure 403 {string} string "Forbidden"
+// @Failure 500 {string} string "Internal server error"
+// @Router /nodes/{nodeID}/publication [get]
+func (nr *NodeRouter) HandleGetPublication(w http.ResponseWriter, r *http.Request) {
+	nodeID, ok := nr.accessibleNodeID(w, r)
+	if !ok {
+		return
+	}
+
+	resp, err := nr.publicationResponse(r.Context(), nodeID)
+	if err != nil {
+		applog.Error("Failed to get publication:", err)
+		api.WriteInternalError(w)
+		return
+	}
+
+	api.WriteJSON(w, 200, resp)
+}
+
+// @Summary Publish the draft
+// @Description Make the node's draft page settings, links and color stops public (owner only). With publish_at in the future the draft as it is now is published at that time instead, replacing any publication already scheduled; later edits stay in the draft.
+// @Tags publishing
+// @Accept json
+// @Produce json
+// @Param nodeID path string true "Node ID"
+// @Param request body PublishRequest false "When to publish, now by default"
+// @Success 200 {object} PublicationResponse
+// @Failure 400 {object} api.ErrorResponse "Invalid publish time"
+// @Failure 401 {string} string "Unauthorized"
+// @Failure 403 {string} string "Forbidden"
+// @Failure 404 {string} string "Not found"
+// @Failure 500 {string} string "Internal server error"
+// @Router /nodes/{nodeID}/publish [post]
+func (nr *NodeRouter) HandlePublish(w http.ResponseWriter, r *http.Request) {
+	node, ok := nr.ownedNode(w, r)
+	if !ok {
+		return
+	}
+
+	var req PublishRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return
+	}
+
+	now := time.Now().UTC().Unix()
+	if req.PublishAt > now+maxPublishDelay {
+		api.WriteMessage(w, 400, "error", "Publications can be scheduled at most a year ahead")
+		return
+	}
+
+	snap, err := nr.encodedSnapshot(r.Context(), node.ID)
+	if err != nil {
+		applog.Error("Failed to snapshot node for publication:", err)
+		api.WriteInternalError(w)
+		return
+	}
+
+	if req.PublishAt > now {
+		// the schedule lives on the publication, so the current state has to be published first
+		if err := nr.beforeChange(r.Context(), node.ID); err != nil {
+			applog.Error("Failed to prepare draft:", err)
+			api.WriteInternalError(w)
+			return
+		}
+		err = nr.PublicationRepo.Schedule(r.Context(), node.ID, snap, node.OwnerID, req.PublishAt)
+	} else {
+		err = nr.PublicationRepo.Publish(r.Context(), node.ID, snap, node.OwnerID, now)
+	}
+	if err != nil {
+		applog.Error("Failed to publish node:", err)
+		api.WriteInternalError(w)
+		return
+	}
+
+	applog.Info("Node published", "nodeID:", node.ID, "at:", max(req.PublishAt, now))
+
+	resp, err := nr.publicationResponse(r.Context(), node.ID)
+	if err != nil {
+		applog.Error("Failed to get publication:", err)
+		api.WriteInternalError(w)
+		return
+	}
+
+	api.WriteJSON(w, 200, resp)
+}
+
+// @Summary Cancel a scheduled publication
+// @Description Drop the publication scheduled for the node, the draft is kept (owner only)
+// @Tags publishing
+// @Produce json
+// @Param nodeID path string true "Node ID"
+// @Success 200 {object} api.SuccessResponse
+// @Failure 400 {string} string "Bad request"
+// @Failure 401 {string} string "Unauthorized"
+// @Failure 403 {string} string "Forbidden"
+// @Failure 404 {object} api.ErrorResponse "Nothing scheduled"
+// @Failure 500 {string} string "Internal server error"
+// @Router /nodes/{nodeID}/publish [delete]
+func (nr *NodeRouter) HandleCancelPublish(w http.ResponseWriter, r *http.Request) {
+	node, ok := nr.ownedNode(w, r)
+	if !ok {
+		return
+	}
+
+	cancelled, err := nr.PublicationRepo.CancelSchedule(r.Context(), node.ID)
+	if err != nil {
+		applog.Error("Failed to cancel scheduled publication:", err)
+		api.WriteInternalError(w)
+		return
+	}
+
+	if !cancelled {
+		api.WriteMessage(w, 404, "error", "No publication is scheduled")
+		return
+	}
+
+	api.WriteMessage(w, 200, "message", "Scheduled publication cancelled")
+}
+
+// @Summary Discard the draft
+// @Description Put the node's draft back to its published state in one transaction, recorded as a revision (owner only). A scheduled publication keeps the draft it was scheduled with.
+// @Tags publishing
+// @Produce json
+// @Param nodeID path string true "Node ID"
+// @Success 200 {object} api.SuccessResponse
+// @Failure 400 {string} string "Bad request"
+// @Failure 401 {string} string "Unauthorized"
+// @Failure 403 {string} string "Forbidden"
+// @Failure 404 {string} string "Not found"
+// @Failure 500 {string} string "Internal server error"
+// @Router /nodes/{nodeID}/discard [post]
+func (nr *NodeRouter) HandleDiscardDraft(w http.ResponseWriter, r *http.Request) {
+	node, ok := nr.ownedNode(w, r)
+	if !ok {
+		return
+	}
+
+	pub, err := nr.PublicationRepo.GetPublication(r.Context(), node.ID)
+	if errors.Is(err, sql.ErrNoRows) {
+		// never edited as a draft, what's public is the current state
+		api.WriteMessage(w, 200, "message", "Draft discarded")
+		return
+	}
+	if err != nil {
+		applog.Error("Failed to get publication:", err)
+		api.WriteInternalError(w)
+		return
+	}
+
+	var snap model.NodeSnapshot
+	if err := json.Unmarshal([]byte(pub.Snapshot), &snap); err != nil {
+		applog.Error("Failed to decode published snapshot:", node.ID, err)
+		api.WriteInternalError(w)
+		return
+	}
+
+	rev := &model.NodeRevision{
+		ID:        utils.GenerateSnowflakeID(),
+		NodeID:    node.ID,
+		UserID:    node.OwnerID,
+		Action:    model.RevisionDraftDiscard,
+		Snapshot:  pub.Snapshot,
+		CreatedAt: time.Now().UTC().Unix(),
+	}
+
+	if err := nr.RevisionRepo.RestoreSnapshot(r.Context(), node.ID, &snap, rev); err != nil {
+		applog.Error("Failed to discard draft:", err)
+		api.WriteInternalError(w)
+		return
+	}
+
+	api.WriteMessage(w, 200, "message", "Draft discarded")
+}
+
+// @Summary Create a preview link
+// @Description Create a signed link to the node's draft page that works without an account until it expires, to share unpublished changes for review (owner and collaborators). url is empty when PUBLIC_API_URL is unset, build it from the token then. It stops working if its creator loses access to the node.
+// @Tags publishing
+// @Produce json
+// @Param nodeID path string true "Node ID"
+// @Success 201 {object} PreviewResponse
+// @Failure 400 {string} string "Bad request"
+// @Failure 401 {string} string "Unauthorized"
+// @Failure 403 {string} string "Forbidden"
+// @Router /nodes/{nodeID}/preview [post]
+func (nr *NodeRouter) HandleCreatePreview(w http.ResponseWriter, r *http.Request) {
+	nodeID, ok := nr.accessibleNodeID(w, r)
+	if !ok {
+		return
+	}
+
+	user, _ := utils.UserFromContext(r.Context())
+	now := time.Now().UTC().Unix()
+	token := jwt.CreateJwt(jwt.Claims{
+		Issuer:    config.App.JwtIssuer,
+		UserID:    user.ID,
+		Audience:  []string{config.App.JwtAudience},
+		TokenID:   uuid.New().String(),
+		IssuedAt:  now,
+		NotBefore: now,
+		NodeID:    nodeID,
+	}).WithType(model.PreviewJwt)
+
+	raw := token.GenerateToken()
+	api.WriteJSON(w, 201, PreviewResponse{
+		Token:     raw,
+		Url:       pages.ApiUrl("/nodes/public/preview/" + raw),
+		ExpiresAt: token.Payload.Expiration,
+	})
+}
+
+// @Summary Preview a draft
+// @Description Render the draft of the node a preview token was created for as an HTML page, or as JSON when the client only accepts JSON (no authentication required)
+// @Tags public
+// @Produce html,json
+// @Param token path string true "Preview token"
+// @Success 200 {object} DraftPageResponse
+// @Failure 404 {string} string "Not found"
+// @Failure 500 {string} string "Internal server error"
+// @Router /nodes/public/preview/{token} [get]
+func (nr *NodeRouter) HandleGetPreview(w http.ResponseWriter, r *http.Request) {
+	claims, err := jwt.ValidateToken(chi.URLParam(r, "token"), config.JwtSecretBytes, nr.TokenRepo)
+	if err != nil || claims.Type != model.PreviewJwt || claims.NodeID == 0 {
+		http.Error(w, "not found", http.StatusNotFound)
+		return
+	}
+
+	node, err := nr.NodeRepo.GetNodeByID(r.Context(), claims.NodeID)
+	if err != nil || node.TakenDownAt != 0 {
+		http.Error(w, "not found", http.StatusNotFound)
+		return
+	}
+
+	hasAccess, err := nr.NodeRepo.CheckNodeAccess(r.Context(), node.ID, claims.UserID)
+	if err != nil || !hasAccess {
+		http.Error(w, "not found", http.StatusNotFound)
+		return
+	}
+
+	links, err := nr.LinkRepo.GetVisibleLinksByNodeID(r.Context(), node.ID)
+	if err != nil {
+		api.WriteInternalError(w)
+		return
+	}
+
+	for i := range links {
+		err = nr.LinkRepo.LoadColorStops(r.Context(), &links[i])
+		if err != nil {
+			applog.Error("Failed to load color stops for link:", links[i].ID, err)
+		}
+	}
+
+	w.Header().Set("Cache-Control", "private, no-store")
+	w.Header().Set("X-Robots-Tag", "noindex")
+	w.Header().Add("Vary", "Accept")
+
+	if wantsJSON(r) {
+		utils.StripUnsafeFields(node)
+		api.WriteJSON(w, 200, DraftPageResponse{Node: node, Links: links})
+		return
+	}
+
+	nr.writeNodePage(w, node, links, pages.NodeUrl(node), "")
+}
+
+// beforeChange runs before an edit of the node's draft. nodes without history get a
+// baseline revision, and nodes that were never published have their current state
+// published so the edit stays a draft.
+func (nr *NodeRouter) beforeChange(ctx context.Context, nodeID int64) error {
+	hasRevisions, err := nr.RevisionRepo.HasRevisions(ctx, nodeID)
+	if err != nil {
+		return err
+	}
+
+	published, err := nr.PublicationRepo.HasPublication(ctx, nodeID)
+	if err != nil {
+		return err
+	}
+
+	if hasRevisions && published {
+		return nil
+	}
+
+	snap, err := nr.encodedSnapshot(ctx, nodeID)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now().UTC().Unix()
+	if !hasRevisions {
+		err := nr.RevisionRepo.CreateRevision(ctx, &model.NodeRevision{
+			ID:        utils.GenerateSnowflakeID(),
+			NodeID:    nodeID,
+			Action:    model.RevisionBaseline,
+			Snapshot:  snap,
+			CreatedAt: now,
+		})
+		if err != nil {
+			applog.Error("Failed to record baseline revision:", nodeID, err)
+		}
+	}
+
+	if published {
+		return nil
+	}
+
+	return nr.PublicationRepo.CreatePublication(ctx, &model.NodePublication{
+		NodeID:      nodeID,
+		Snapshot:    snap,
+		PublishedAt: now,
+	})
+}
+
+// publishedSnapshot puts the node's published page settings on it and returns what was
+// published, nil for nodes that were never edited as a draft and are served as they are
+func (nr *NodeRouter) publishedSnapshot(ctx context.Context, node *model.Node) (*model.NodeSnapshot, error) {
+	pub, err := nr.PublicationRepo.GetPublication(ctx, node.ID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var snap model.NodeSnapshot
+	if err := json.Unmarshal([]byte(pub.Snapshot), &snap); err != nil {
+		return nil, err
+	}
+
+	snap.Apply(node)
+	return &snap, nil
+}
+
+// publishedLinks puts the node's published page settings on it and returns the visible
+// links it was published with, with their color stops
+func (nr *NodeRouter) publishedLinks(ctx context.Context, node *model.Node) ([]model.Link, error) {
+	snap, err := nr.publishedSnapshot(ctx, node)
+	if err != nil {
+		return nil, err
+	}
+
+	if snap == nil {
+		links, err := nr.LinkRepo.GetVisibleLinksByNodeID(ctx, node.ID)
+		if err != nil {
+			return nil, err
+		}
+
+		for i := range links {
+			if err := nr.LinkRepo.LoadColorStops(ctx, &links[i]); err != nil {
+				applog.Error("Failed to load color stops for link:", links[i].ID, err)
+			}
+		}
+		return links, nil
+	}
+
+	links := []model.Link{}
+	for _, link := range snap.Links {
+		if link.Visible && link.Enabled {
+			links = append(links, link)
+		}
+	}
+	return links, nil
+}
+
+// findLink returns the link with the given name, or nil
+func findLink(links []model.Link, name string) *model.Link {
+	for i := range links {
+		if links[i].Name == name {
+			return &links[i]
+		}
+	}
+	return nil
+}
+
+func (nr *NodeRouter) publicationResponse(ctx context.Context, nodeID int64) (*PublicationResponse, error) {
+	pub, err := nr.PublicationRepo.GetPublication(ctx, nodeID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return &PublicationResponse{NodePublication: model.NodePublication{NodeID: nodeID}}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var published model.NodeSnapshot
+	if err := json.Unmarshal([]byte(pub.Snapshot), &published); err != nil {
+		return nil, err
+	}
+
+	draft, err := nr.snapshot(ctx, nodeID)
+	if err != nil {
+		return nil, err
+	}
+
+	diff := diffSnapshots(&published, draft)
+	changed := len(diff.Node)+len(diff.LinksAdded)+len(diff.LinksRemoved)+len(diff.LinksChanged) > 0
+
+	return &PublicationResponse{NodePublication: *pub, HasChanges: changed}, nil
+}
@@ -0,0 +1,405 @@
+package node
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/akramboussanni/treenode/internal/api"
+	"github.com/akramboussanni/treenode/internal/applog"
+	"github.com/akramboussanni/treenode/internal/model"
+	"github.com/akramboussanni/treenode/internal/utils"
+	"github.com/go-chi/chi/v5"
+)
+
+const (
+	defaultRevisionPageSize = 50
+	maxRevisionPageSize     = 200
+)
+
+// @Summary List revisions
+// @Description List the node's revisions newest first, one per change to the node, its links or their color stops (owner and collaborators)
+// @Tags revisions
+// @Produce json
+// @Param nodeID path string true "Node ID"
+// @Param limit query int false "Page size, 50 by default and at most 200"
+// @Param offset query int false "Number of revisions to skip"
+// @Success 200 {array} model.NodeRevision
+// @Failure 400 {string} string "Bad request"
+// @Failure 401 {string} string "Unauthorized"
+// @Failure 403 {string} string "Forbidden"
+// @Failure 500 {string} string "Internal server error"
+// @Router /nodes/{nodeID}/revisions [get]
+func (nr *NodeRouter) HandleGetRevisions(w http.ResponseWriter, r *http.Request) {
+	nodeID, ok := nr.accessibleNodeID(w, r)
+	if !ok {
+		return
+	}
+
+	limit, offset := revisionPage(r)
+	revs, err := nr.RevisionRepo.GetRevisions(r.Context(), nodeID, limit, offset)
+	if err != nil {
+		applog.Error("Failed to get revisions:", err)
+		api.WriteInternalError(w)
+		return
+	}
+
+	if revs == nil {
+		revs = []model.NodeRevision{}
+	}
+
+	api.WriteJSON(w, 200, revs)
+}
+
+// @Summary Get a revision
+// @Description Get a revision with the snapshot of the node's page and links it recorded (owner and collaborators)
+// @Tags revisions
+// @Produce json
+// @Param nodeID path string true "Node ID"
+// @Param revisionID path string true "Revision ID"
+// @Success 200 {object} RevisionResponse
+// @Failure 400 {string} string "Bad request"
+// @Failure 401 {string} string "Unauthorized"
+// @Failure 403 {string} string "Forbidden"
+// @Failure 404 {string} string "Not found"
+// @Failure 500 {string} string "Internal server error"
+// @Router /nodes/{nodeID}/revisions/{revisionID} [get]
+func (nr *NodeRouter) HandleGetRevision(w http.ResponseWriter, r *http.Request) {
+	nodeID, ok := nr.accessibleNodeID(w, r)
+	if !ok {
+		return
+	}
+
+	rev, snap, ok := nr.loadRevision(w, r, nodeID, chi.URLParam(r, "revisionID"))
+	if !ok {
+		return
+	}
+
+	api.WriteJSON(w, 200, RevisionResponse{NodeRevision: *rev, Snapshot: *snap})
+}
+
+// @Summary Diff two revisions
+// @Description Compare the page settings and links of two revisions of the node. Without to, the revision is compared with the node's current state (owner and collaborators)
+// @Tags revisions
+// @Produce json
+// @Param nodeID path string true "Node ID"
+// @Param from query string true "Revision ID to compare from"
+// @Param to query string false "Revision ID to compare to, the current state when omitted"
+// @Success 200 {object} RevisionDiffResponse
+// @Failure 400 {string} string "Bad request"
+// @Failure 401 {string} string "Unauthorized"
+// @Failure 403 {string} string "Forbidden"
+// @Failure 404 {string} string "Not found"
+// @Failure 500 {string} string "Internal server error"
+// @Router /nodes/{nodeID}/revisions/diff [get]
+func (nr *NodeRouter) HandleDiffRevisions(w http.ResponseWriter, r *http.Request) {
+	nodeID, ok := nr.accessibleNodeID(w, r)
+	if !ok {
+		return
+	}
+
+	fromRev, from, ok := nr.loadRevision(w, r, nodeID, r.URL.Query().Get("from"))
+	if !ok {
+		return
+	}
+
+	var toID int64
+	var to *model.NodeSnapshot
+	if raw := r.URL.Query().Get("to"); raw != "" {
+		var toRev *model.NodeRevision
+		if toRev, to, ok = nr.loadRevision(w, r, nodeID, raw); !ok {
+			return
+		}
+		toID = toRev.ID
+	} else {
+		snap, err := nr.snapshot(r.Context(), nodeID)
+		if err != nil {
+			applog.Error("Failed to snapshot node:", err)
+			api.WriteInternalError(w)
+			return
+		}
+		to = snap
+	}
+
+	resp := diffSnapshots(from, to)
+	resp.From, resp.To = fromRev.ID, toID
+	api.WriteJSON(w, 200, resp)
+}
+
+// @Summary Restore a revision
+// @Description Put the node's draft page settings, links and color stops back to a revision in one transaction, recorded as a new revision (owner only). The subdomain and custom domain are left as they are, publish the draft to make the restore public.
+// @Tags revisions
+// @Produce json
+// @Param nodeID path string true "Node ID"
+// @Param revisionID path string true "Revision ID"
+// @Success 200 {object} model.NodeRevision
+// @Failure 400 {string} string "Bad request"
+// @Failure 401 {string} string "Unauthorized"
+// @Failure 403 {string} string "Forbidden"
+// @Failure 404 {string} string "Not found"
+// @Failure 500 {string} string "Internal server error"
+// @Router /nodes/{nodeID}/revisions/{revisionID}/restore [post]
+func (nr *NodeRouter) HandleRestoreRevision(w http.ResponseWriter, r *http.Request) {
+	node, ok := nr.ownedNode(w, r)
+	if !ok {
+		return
+	}
+
+	target, snap, ok := nr.loadRevision(w, r, node.ID, chi.URLParam(r, "revisionID"))
+	if !ok {
+		return
+	}
+
+	if err := nr.beforeChange(r.Context(), node.ID); err != nil {
+		applog.Error("Failed to prepare draft:", err)
+		api.WriteInternalError(w)
+		return
+	}
+
+	rev := &model.NodeRevision{
+		ID:        utils.GenerateSnowflakeID(),
+		NodeID:    node.ID,
+		UserID:    node.OwnerID,
+		Action:    model.RevisionNodeRestore,
+		Snapshot:  target.Snapshot,
+		CreatedAt: time.Now().UTC().Unix(),
+	}
+
+	if err := nr.RevisionRepo.RestoreSnapshot(r.Context(), node.ID, snap, rev); err != nil {
+		applog.Error("Failed to restore revision:", err)
+		api.WriteInternalError(w)
+		return
+	}
+
+	applog.Info("Node restored to revision", "nodeID:", node.ID, "revisionID:", target.ID)
+	api.WriteJSON(w, 200, rev)
+}
+
+// accessibleNodeID parses the node in the url and checks the user owns or collaborates
+// on it, writing the error response otherwise
+func (nr *NodeRouter) accessibleNodeID(w http.ResponseWriter, r *http.Request) (int64, bool) {
+	nodeID, err := utils.ParseID(chi.URLParam(r, "nodeID"))
+	if err != nil {
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return 0, false
+	}
+
+	user, ok := utils.UserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return 0, false
+	}
+
+	hasAccess, err := nr.NodeRepo.CheckNodeAccess(r.Context(), nodeID, user.ID)
+	if err != nil || !hasAccess {
+		http.Error(w, "forbidden", http.StatusForbidden)
+		return 0, false
+	}
+
+	return nodeID, true
+}
+
+// loadRevision loads a revision of the node and decodes its snapshot, writing the error
+// response otherwise
+func (nr *NodeRouter) loadRevision(w http.ResponseWriter, r *http.Request, nodeID int64, rawID string) (*model.NodeRevision, *model.NodeSnapshot, bool) {
+	revID, err := utils.ParseID(rawID)
+	if err != nil {
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return nil, nil, false
+	}
+
+	rev, err := nr.RevisionRepo.GetRevision(r.Context(), nodeID, revID)
+	if errors.Is(err, sql.ErrNoRows) {
+		http.Error(w, "not found", http.StatusNotFound)
+		return nil, nil, false
+	}
+	if err != nil {
+		applog.Error("Failed to get revision:", err)
+		api.WriteInternalError(w)
+		return nil, nil, false
+	}
+
+	var snap model.NodeSnapshot
+	if err := json.Unmarshal([]byte(rev.Snapshot), &snap); err != nil {
+		applog.Error("Failed to decode revision snapshot:", rev.ID, err)
+		api.WriteInternalError(w)
+		return nil, nil, false
+	}
+
+	return rev, &snap, true
+}
+
+// snapshot reads the node's current page settings, links and color stops
+func (nr *NodeRouter) snapshot(ctx context.Context, nodeID int64) (*model.NodeSnapshot, error) {
+	node, err := nr.NodeRepo.GetNodeByID(ctx, nodeID)
+	if err != nil {
+		return nil, err
+	}
+
+	links, err := nr.LinkRepo.GetLinksByNodeID(ctx, nodeID)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range links {
+		if err := nr.LinkRepo.LoadColorStops(ctx, &links[i]); err != nil {
+			return nil, err
+		}
+	}
+
+	snap := model.NewNodeSnapshot(node, links)
+	return &snap, nil
+}
+
+// encodedSnapshot is the node's current state as stored in revisions and publications
+func (nr *NodeRouter) encodedSnapshot(ctx context.Context, nodeID int64) (string, error) {
+	snap, err := nr.snapshot(ctx, nodeID)
+	if err != nil {
+		return "", err
+	}
+
+	raw, err := json.Marshal(snap)
+	return string(raw), err
+}
+
+// recordRevision snapshots the node after a change. the change already went through,
+// so failures are only logged.
+func (nr *NodeRouter) recordRevision(ctx context.Context, nodeID, userID int64, action string) {
+	snap, err := nr.encodedSnapshot(ctx, nodeID)
+	if err != nil {
+		applog.Error("Failed to snapshot node for revision:", nodeID, err)
+		return
+	}
+
+	rev := &model.NodeRevision{
+		ID:        utils.GenerateSnowflakeID(),
+		NodeID:    nodeID,
+		UserID:    userID,
+		Action:    action,
+		Snapshot:  snap,
+		CreatedAt: time.Now().UTC().Unix(),
+	}
+
+	if err := nr.RevisionRepo.CreateRevision(ctx, rev); err != nil {
+		applog.Error("Failed to record revision:", nodeID, err)
+	}
+}
+
+// revisionPage reads ?limit= and ?offset=, falling back to the defaults on bad input
+func revisionPage(r *http.Request) (int, int) {
+	limit, err := strconv.Atoi(r.URL.Query().Get("limit"))
+	if err != nil || limit <= 0 {
+		limit = defaultRevisionPageSize
+	}
+	if limit > maxRevisionPageSize {
+		limit = maxRevisionPageSize
+	}
+
+	offset, err := strconv.Atoi(r.URL.Query().Get("offset"))
+	if err != nil || offset < 0 {
+		offset = 0
+	}
+
+	return limit, offset
+}
+
+// diffSnapshots lists the page settings that changed, then the links added, removed
+// and changed, matched by id
+func diffSnapshots(from, to *model.NodeSnapshot) RevisionDiffResponse {
+	fromPage, toPage := *from, *to
+	fromPage.Links, toPage.Links = nil, nil
+
+	resp := RevisionDiffResponse{
+		Node:         diffFields(jsonFields(fromPage, "links"), jsonFields(toPage, "links")),
+		LinksAdded:   []LinkChange{},
+		LinksRemoved: []LinkChange{},
+		LinksChanged: []LinkChange{},
+	}
+
+	fromLinks := map[int64]*model.Link{}
+	for i := range from.Links {
+		fromLinks[from.Links[i].ID] = &from.Links[i]
+	}
+
+	seen := map[int64]bool{}
+	for i := range to.Links {
+		link := &to.Links[i]
+		seen[link.ID] = true
+
+		old, ok := fromLinks[link.ID]
+		if !ok {
+			resp.LinksAdded = append(resp.LinksAdded, newLinkChange(link, nil))
+			continue
+		}
+
+		if changes := diffFields(linkFields(old), linkFields(link)); len(changes) > 0 {
+			resp.LinksChanged = append(resp.LinksChanged, newLinkChange(link, changes))
+		}
+	}
+
+	for i := range from.Links {
+		if !seen[from.Links[i].ID] {
+			resp.LinksRemoved = append(resp.LinksRemoved, newLinkChange(&from.Links[i], nil))
+		}
+	}
+
+	return resp
+}
+
+func newLinkChange(link *model.Link, changes []FieldChange) LinkChange {
+	return LinkChange{ID: link.ID, Name: link.Name, DisplayName: link.DisplayName, Changes: changes}
+}
+
+// linkFields is the link as json without the fields every save touches. color stops
+// are compared by color and position only.
+func linkFields(link *model.Link) map[string]any {
+	fields := jsonFields(link, "id", "node_id", "created_at", "updated_at", "color_stops")
+
+	stops := make([]any, 0, len(link.ColorStops))
+	for _, stop := range link.ColorStops {
+		stops = append(stops, map[string]any{"color": stop.Color, "position": stop.Position})
+	}
+	fields["color_stops"] = stops
+
+	return fields
+}
+
+func jsonFields(v any, skip ...string) map[string]any {
+	fields := map[string]any{}
+	raw, _ := json.Marshal(v)
+	json.Unmarshal(raw, &fields)
+
+	for _, key := range skip {
+		delete(fields, key)
+	}
+	return fields
+}
+
+func diffFields(from, to map[string]any) []FieldChange {
+	keys := map[string]bool{}
+	for k := range from {
+		keys[k] = true
+	}
+	for k := range to {
+		keys[k] = true
+	}
+
+	var changes []FieldChange
+	for k := range keys {
+		if !reflect.DeepEqual(from[k], to[k]) {
+			changes = append(changes, FieldChange{Field: k, From: from[k], To: to[k]})
+		}
+	}
+
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Field < changes[j].Field })
+	if changes == nil {
+		changes = []FieldChange{}
+	}
+	return changes
+}
@@ -5,21 +5,27 @@ import (
 	"time"
 
 	"github.com/akramboussanni/treenode/internal/middleware"
+	"github.com/akramboussanni/treenode/internal/model"
 	"github.com/akramboussanni/treenode/internal/repo"
 	"github.com/go-chi/chi/v5"
 )
 
 type NodeRouter struct {
-	UserRepo       *repo.UserRepo
-	TokenRepo      *repo.TokenRepo
-	LockoutRepo    *repo.LockoutRepo
-	NodeRepo       *repo.NodeRepo
-	LinkRepo       *repo.LinkRepo
-	InvitationRepo *repo.InvitationRepo
+	UserRepo        *repo.UserRepo
+	TokenRepo       *repo.TokenRepo
+	LockoutRepo     *repo.LockoutRepo
+	SessionRepo     *repo.SessionRepo
+	NodeRepo        *repo.NodeRepo
+	LinkRepo        *repo.LinkRepo
+	InvitationRepo  *repo.InvitationRepo
+	ApiTokenRepo    *repo.ApiTokenRepo
+	RevisionRepo    *repo.RevisionRepo
+	PublicationRepo *repo.PublicationRepo
+	SubdomainRepo   *repo.SubdomainRepo
 }
 
-func NewNodeRouter(userRepo *repo.UserRepo, tokenRepo *repo.TokenRepo, lockoutRepo *repo.LockoutRepo, nodeRepo *repo.NodeRepo, linkRepo *repo.LinkRepo, invitationRepo *repo.InvitationRepo) http.Handler {
-	nr := &NodeRouter{UserRepo: userRepo, TokenRepo: tokenRepo, LockoutRepo: lockoutRepo, NodeRepo: nodeRepo, LinkRepo: linkRepo, InvitationRepo: invitationRepo}
+func NewNodeRouter(userRepo *repo.UserRepo, tokenRepo *repo.TokenRepo, lockoutRepo *repo.LockoutRepo, sessionRepo *repo.SessionRepo, nodeRepo *repo.NodeRepo, linkRepo *repo.LinkRepo, invitationRepo *repo.InvitationRepo, apiTokenRepo *repo.ApiTokenRepo, revisionRepo *repo.RevisionRepo, publicationRepo *repo.PublicationRepo, subdomainRepo *repo.SubdomainRepo) http.Handler {
+	nr := &NodeRouter{UserRepo: userRepo, TokenRepo: tokenRepo, LockoutRepo: lockoutRepo, SessionRepo: sessionRepo, NodeRepo: nodeRepo, LinkRepo: linkRepo, InvitationRepo: invitationRepo, ApiTokenRepo: apiTokenRepo, RevisionRepo: revisionRepo, PublicationRepo: publicationRepo, SubdomainRepo: subdomainRepo}
 	r := chi.NewRouter()
 
 	r.Use(middleware.MaxBytesMiddleware(1 << 20))
@@ -33,15 +39,19 @@ func NewNodeRouter(userRepo *repo.UserRepo, tokenRepo *repo.TokenRepo, lockoutRe
 		r.Get("/subdomain/{subdomain}", nr.HandleGetNodeBySubdomain)
 		r.Get("/subdomain/{subdomain}/links", nr.HandleGetPublicLinksBySubdomain)
 		r.Get("/subdomain/{subdomain}/links/{linkName}", nr.HandleGetPublicLinkBySubdomain)
+		r.Get("/subdomain/{subdomain}/page", nr.HandleGetNodePageBySubdomain)
+		r.Get("/subdomain/{subdomain}/og.png", nr.HandleGetNodeImageBySubdomain)
+		r.Get("/preview/{token}", nr.HandleGetPreview)
 		r.Get("/name/{name}", nr.HandleGetNodeByName)
 		r.Get("/name/{name}/links", nr.HandleGetPublicLinksByName)
 	})
 
 	r.Route("/api", func(r chi.Router) {
-		middleware.AddAuth(r, nr.UserRepo, nr.TokenRepo)
+		middleware.AddApiAuth(r, nr.UserRepo, nr.TokenRepo, nr.SessionRepo, nr.ApiTokenRepo)
 
 		r.Group(func(r chi.Router) {
 			middleware.AddRatelimit(r, 30, 1*time.Minute) // 30/min
+			middleware.AddScope(r, model.ScopeNodesRead, "")
 
 			r.Post("/", nr.HandleCreateNode)
 			r.Get("/", nr.HandleGetUserNodes)
@@ -50,10 +60,48 @@ func NewNodeRouter(userRepo *repo.UserRepo, tokenRepo *repo.TokenRepo, lockoutRe
 			r.Put("/{nodeID}", nr.HandleUpdateNode)
 			r.Delete("/{nodeID}", nr.HandleDeleteNode)
 			r.Post("/{nodeID}/transfer", nr.HandleTransferOwnership)
+			r.Post("/{nodeID}/clone", nr.HandleCloneNode)
+			r.Get("/{nodeID}/subdomains", nr.HandleGetSubdomainHistory)
+			r.Delete("/{nodeID}/subdomains/{subdomain}", nr.HandleReleaseSubdomain)
+		})
+
+		r.Group(func(r chi.Router) {
+			middleware.AddRatelimit(r, 30, 1*time.Minute) // 30/min
+			middleware.AddScope(r, model.ScopeNodesRead, "")
+
+			r.Get("/{nodeID}/revisions", nr.HandleGetRevisions)
+			r.Get("/{nodeID}/revisions/diff", nr.HandleDiffRevisions)
+			r.Get("/{nodeID}/revisions/{revisionID}", nr.HandleGetRevision)
+			r.Post("/{nodeID}/revisions/{revisionID}/restore", nr.HandleRestoreRevision)
+
+			r.Get("/{nodeID}/publication", nr.HandleGetPublication)
+			r.Post("/{nodeID}/publish", nr.HandlePublish)
+			r.Delete("/{nodeID}/publish", nr.HandleCancelPublish)
+			r.Post("/{nodeID}/discard", nr.HandleDiscardDraft)
+			r.Post("/{nodeID}/preview", nr.HandleCreatePreview)
+		})
+
+		r.Group(func(r chi.Router) {
+			middleware.AddRatelimit(r, 10, 1*time.Minute) // 10/min
+			middleware.AddScope(r, model.ScopeNodesRead, "")
+
+			r.Post("/import", nr.HandleImportNode)
+			r.Get("/{nodeID}/export", nr.HandleExportNode)
+		})
+
+		r.Group(func(r chi.Router) {
+			middleware.AddRatelimit(r, 10, 1*time.Minute) // 10/min, verification does DNS lookups
+			middleware.AddScope(r, model.ScopeNodesRead, "")
+
+			r.Get("/{nodeID}/domain", nr.HandleGetDomain)
+			r.Put("/{nodeID}/domain", nr.HandleSetDomain)
+			r.Delete("/{nodeID}/domain", nr.HandleDeleteDomain)
+			r.Post("/{nodeID}/domain/verify", nr.HandleVerifyDomain)
 		})
 
 		r.Group(func(r chi.Router) {
 			middleware.AddRatelimit(r, 20, 1*time.Minute) // 20/min
+			middleware.AddScope(r, model.ScopeCollaboratorsManage, model.ScopeCollaboratorsManage)
 
 			r.Post("/{nodeID}/collaborators", nr.HandleAddCollaborator)
 			r.Delete("/{nodeID}/collaborators/{userID}", nr.HandleRemoveCollaborator)
@@ -62,6 +110,7 @@ func NewNodeRouter(userRepo *repo.UserRepo, tokenRepo *repo.TokenRepo, lockoutRe
 
 		r.Group(func(r chi.Router) {
 			middleware.AddRatelimit(r, 10, 1*time.Minute) // 10/min
+			middleware.AddScope(r, model.ScopeCollaboratorsManage, model.ScopeCollaboratorsManage)
 			middleware.AddRecaptcha(r)
 
 			r.Post("/{nodeID}/invite", nr.HandleInviteCollaborator)
@@ -71,6 +120,7 @@ func NewNodeRouter(userRepo *repo.UserRepo, tokenRepo *repo.TokenRepo, lockoutRe
 
 		r.Group(func(r chi.Router) {
 			middleware.AddRatelimit(r, 40, 1*time.Minute) // 40/min
+			middleware.AddScope(r, model.ScopeNodesRead, model.ScopeLinksWrite)
 
 			r.Post("/{nodeID}/links", nr.HandleCreateLink)
 			r.Get("/{nodeID}/links", nr.HandleGetLinks)
@@ -81,8 +131,16 @@ func NewNodeRouter(userRepo *repo.UserRepo, tokenRepo *repo.TokenRepo, lockoutRe
 			r.Put("/{nodeID}/links/{linkID}/name", nr.HandleUpdateLinkName)
 		})
 
+		r.Group(func(r chi.Router) {
+			middleware.AddRatelimit(r, 10, 1*time.Minute) // 10/min
+			middleware.AddScope(r, model.ScopeNodesRead, model.ScopeLinksWrite)
+
+			r.Post("/{nodeID}/links/import", nr.HandleImportLinks)
+		})
+
 		r.Group(func(r chi.Router) {
 			middleware.AddRatelimit(r, 50, 1*time.Minute) // 50/min
+			middleware.AddScope(r, model.ScopeNodesRead, model.ScopeLinksWrite)
 
 			r.Post("/{nodeID}/links/{linkID}/color-stops", nr.HandleCreateColorStop)
 			r.Put("/{nodeID}/links/{linkID}/color-stops/{colorStopID}", nr.HandleUpdateColorStop)
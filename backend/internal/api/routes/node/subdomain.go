@@ -0,0 +1,166 @@
+package node
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/akramboussanni/treenode/config"
+	"github.com/akramboussanni/treenode/internal/api"
+	"github.com/akramboussanni/treenode/internal/applog"
+	"github.com/akramboussanni/treenode/internal/model"
+	"github.com/akramboussanni/treenode/internal/utils"
+	"github.com/go-chi/chi/v5"
+)
+
+// @Summary Get a node's previous subdomains
+// @Description List the subdomains the node used before, newest first. Unreleased ones redirect to its current subdomain until another node takes them, and other nodes can't take them before held_until. Owners and collaborators can see them.
+// @Tags nodes
+// @Produce json
+// @Param nodeID path string true "Node ID"
+// @Success 200 {array} model.RetiredSubdomain
+// @Failure 400 {string} string "Bad request"
+// @Failure 401 {string} string "Unauthorized"
+// @Failure 403 {string} string "Forbidden"
+// @Failure 500 {string} string "Internal server error"
+// @Router /nodes/{nodeID}/subdomains [get]
+func (nr *NodeRouter) HandleGetSubdomainHistory(w http.ResponseWriter, r *http.Request) {
+	nodeID, ok := nr.accessibleNodeID(w, r)
+	if !ok {
+		return
+	}
+
+	retired, err := nr.SubdomainRepo.GetRetiredSubdomains(r.Context(), nodeID)
+	if err != nil {
+		applog.Error("Failed to get subdomain history:", err)
+		api.WriteInternalError(w)
+		return
+	}
+
+	api.WriteJSON(w, 200, retired)
+}
+
+// @Summary Release a previous subdomain
+// @Description Stop redirecting a previous subdomain of the node and let any node take it right away (owner only). It stays in the history.
+// @Tags nodes
+// @Produce json
+// @Param nodeID path string true "Node ID"
+// @Param subdomain path string true "Previous subdomain"
+// @Success 200 {object} api.SuccessResponse
+// @Failure 400 {string} string "Bad request"
+// @Failure 401 {string} string "Unauthorized"
+// @Failure 403 {string} string "Forbidden"
+// @Failure 404 {string} string "Not a previous subdomain of the node, or already released"
+// @Failure 500 {string} string "Internal server error"
+// @Router /nodes/{nodeID}/subdomains/{subdomain} [delete]
+func (nr *NodeRouter) HandleReleaseSubdomain(w http.ResponseWriter, r *http.Request) {
+	node, ok := nr.ownedNode(w, r)
+	if !ok {
+		return
+	}
+
+	subdomain := chi.URLParam(r, "subdomain")
+	if subdomain == "" {
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return
+	}
+
+	released, err := nr.SubdomainRepo.Release(r.Context(), node.ID, subdomain, time.Now().UTC().Unix())
+	if err != nil {
+		applog.Error("Failed to release subdomain:", err)
+		api.WriteInternalError(w)
+		return
+	}
+
+	if !released {
+		http.Error(w, "not found", http.StatusNotFound)
+		return
+	}
+
+	applog.Info("Subdomain released", "nodeID:", node.ID, "subdomain:", subdomain)
+	api.WriteMessage(w, 200, "message", "Subdomain released")
+}
+
+// checkSubdomainHeld checks no other node than nodeID retired the subdomain recently,
+// writing the error response otherwise. nodeID is 0 for new nodes.
+func (nr *NodeRouter) checkSubdomainHeld(w http.ResponseWriter, r *http.Request, subdomain string, nodeID int64) bool {
+	held, err := nr.SubdomainRepo.IsHeld(r.Context(), subdomain, nodeID, time.Now().UTC().Unix())
+	if err != nil {
+		applog.Error("Failed to check held subdomain:", err)
+		api.WriteInternalError(w)
+		return false
+	}
+
+	if held {
+		applog.Warn("Held subdomain name used", "subdomain:", subdomain)
+		api.WriteMessage(w, 409, "error", "This name was recently used by another page and can't be taken yet")
+		return false
+	}
+
+	return true
+}
+
+// retiredSubdomain is the history entry for the node's old subdomain when it's renamed,
+// so it redirects and is held back for SUBDOMAIN_HOLD
+func retiredSubdomain(nodeID int64, oldName string) *model.RetiredSubdomain {
+	now := time.Now().UTC().Unix()
+	return &model.RetiredSubdomain{
+		ID:            utils.GenerateSnowflakeID(),
+		NodeID:        nodeID,
+		SubdomainName: oldName,
+		RetiredAt:     now,
+		HeldUntil:     now + config.App.SubdomainHold,
+	}
+}
+
+// publicNode looks up the node of a public subdomain route, redirecting to the same route
+// on the current subdomain when the name was retired. writes the response otherwise.
+func (nr *NodeRouter) publicNode(w http.ResponseWriter, r *http.Request, subdomain string) (*model.Node, bool) {
+	node, err := nr.NodeRepo.GetNodeBySubdomain(r.Context(), subdomain)
+	if err == nil && node.TakenDownAt == 0 {
+		return node, true
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		http.Error(w, "not found", http.StatusNotFound)
+		return nil, false
+	}
+
+	current, err := nr.SubdomainRepo.GetRedirect(r.Context(), subdomain)
+	if err != nil {
+		if !errors.Is(err, sql.ErrNoRows) {
+			applog.Error("Failed to look up retired subdomain:", err)
+		}
+		http.Error(w, "not found", http.StatusNotFound)
+		return nil, false
+	}
+
+	// the path segment matched by the subdomain param, routes are mounted without wildcards
+	segments := strings.Split(r.URL.EscapedPath(), "/")
+	pattern := strings.Split(chi.RouteContext(r.Context()).RoutePattern(), "/")
+	if len(segments) != len(pattern) {
+		http.Error(w, "not found", http.StatusNotFound)
+		return nil, false
+	}
+	for i, part := range pattern {
+		if part == "{subdomain}" || part == "{name}" {
+			segments[i] = url.PathEscape(current)
+		}
+	}
+
+	target := strings.Join(segments, "/")
+	if r.URL.RawQuery != "" {
+		target += "?" + r.URL.RawQuery
+	}
+	redirectRetired(w, r, target)
+	return nil, false
+}
+
+// redirectRetired redirects from a retired subdomain. not permanent, the name can go to
+// another node once it's released or its hold ends.
+func redirectRetired(w http.ResponseWriter, r *http.Request, target string) {
+	w.Header().Set("Cache-Control", "no-store")
+	http.Redirect(w, r, target, http.StatusTemporaryRedirect)
+}
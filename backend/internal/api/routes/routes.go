@@ -3,16 +3,20 @@ package routes
 import (
 	"net/http"
 
+	"github.com/akramboussanni/treenode/config"
 	"github.com/akramboussanni/treenode/internal/api"
+	"github.com/akramboussanni/treenode/internal/api/routes/admin"
 	"github.com/akramboussanni/treenode/internal/api/routes/auth"
 	"github.com/akramboussanni/treenode/internal/api/routes/node"
+	"github.com/akramboussanni/treenode/internal/jwt"
 	"github.com/akramboussanni/treenode/internal/middleware"
 	"github.com/akramboussanni/treenode/internal/repo"
+	"github.com/akramboussanni/treenode/internal/scheduler"
 	"github.com/go-chi/chi/v5"
 	chimiddleware "github.com/go-chi/chi/v5/middleware"
 )
 
-func SetupRouter(repos *repo.Repos) http.Handler {
+func SetupRouter(repos *repo.Repos, jobs *scheduler.Scheduler) http.Handler {
 	r := chi.NewRouter()
 
 	r.Use(middleware.SecurityHeaders)
@@ -21,17 +25,29 @@ func SetupRouter(repos *repo.Repos) http.Handler {
 	r.Use(chimiddleware.Logger)
 	r.Use(chimiddleware.Recoverer)
 
+	if config.App.HostRouting {
+		r.Use(routeByHost(node.NewHostRouter(repos.Node, repos.Link, repos.Publication, repos.Subdomain)))
+	}
+
 	r.Get("/", func(w http.ResponseWriter, r *http.Request) {
 		w.Write([]byte("still love you!"))
 	})
 
 	api.AddSwaggerRoutes(r)
 
-	authRouter := auth.NewAuthRouter(repos.User, repos.Token, repos.Lockout)
-	nodeRouter := node.NewNodeRouter(repos.User, repos.Token, repos.Lockout, repos.Node, repos.Link, repos.Invitation)
+	// public keys for services verifying treenode tokens, empty while signing with HS256
+	r.Get("/.well-known/jwks.json", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "public, max-age=300")
+		api.WriteJSON(w, 200, map[string]any{"keys": jwt.JWKS()})
+	})
+
+	authRouter := auth.NewAuthRouter(repos.User, repos.Token, repos.Lockout, repos.Mfa, repos.Session, repos.ApiToken, repos.Identity, repos.Node, repos.Link, repos.Invitation)
+	nodeRouter := node.NewNodeRouter(repos.User, repos.Token, repos.Lockout, repos.Session, repos.Node, repos.Link, repos.Invitation, repos.ApiToken, repos.Revision, repos.Publication, repos.Subdomain)
+	adminRouter := admin.NewAdminRouter(repos.User, repos.Token, repos.Lockout, repos.Session, repos.Node, repos.Audit, jobs)
 
 	r.Mount("/auth", authRouter)
 	r.Mount("/nodes", nodeRouter)
+	r.Mount("/admin", adminRouter)
 
 	return r
 }
@@ -0,0 +1,126 @@
+package breach
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/binary"
+	"errors"
+	"io"
+	"math"
+	"os"
+)
+
+// bloom filter file layout: magic, k (uint32), m in bits (uint64), then the bit array,
+// all little endian
+var bloomMagic = []byte("TNBLOOM1")
+
+var errBadBloomFilter = errors.New("not a treenode bloom filter")
+
+// BloomFilter is a compact, lossy copy of the corpus. lookups never miss a breached
+// password but may flag a clean one at the configured false positive rate.
+type BloomFilter struct {
+	k    uint32
+	m    uint64
+	bits []byte
+}
+
+// NewBloomFilter sizes a filter for n hashes at false positive rate p
+func NewBloomFilter(n uint64, p float64) *BloomFilter {
+	if n == 0 {
+		n = 1
+	}
+
+	m := uint64(math.Ceil(-float64(n) * math.Log(p) / (math.Ln2 * math.Ln2)))
+	k := uint32(math.Max(1, math.Round(float64(m)/float64(n)*math.Ln2)))
+	return &BloomFilter{k: k, m: m, bits: make([]byte, (m+7)/8)}
+}
+
+// the digest is already uniformly distributed, so two halves of it drive double hashing
+func (b *BloomFilter) positions(digest [sha1.Size]byte, fn func(bit uint64) bool) {
+	h1 := binary.LittleEndian.Uint64(digest[0:8])
+	h2 := binary.LittleEndian.Uint64(digest[8:16]) | 1
+	for i := uint64(0); i < uint64(b.k); i++ {
+		if !fn((h1 + i*h2) % b.m) {
+			return
+		}
+	}
+}
+
+func (b *BloomFilter) Add(digest [sha1.Size]byte) {
+	b.positions(digest, func(bit uint64) bool {
+		b.bits[bit/8] |= 1 << (bit % 8)
+		return true
+	})
+}
+
+func (b *BloomFilter) contains(digest [sha1.Size]byte) (bool, error) {
+	found := true
+	b.positions(digest, func(bit uint64) bool {
+		found = b.bits[bit/8]&(1<<(bit%8)) != 0
+		return found
+	})
+	return found, nil
+}
+
+func (b *BloomFilter) WriteTo(w io.Writer) (int64, error) {
+	header := make([]byte, len(bloomMagic)+12)
+	copy(header, bloomMagic)
+	binary.LittleEndian.PutUint32(header[len(bloomMagic):], b.k)
+	binary.LittleEndian.PutUint64(header[len(bloomMagic)+4:], b.m)
+
+	n, err := w.Write(header)
+	if err != nil {
+		return int64(n), err
+	}
+
+	written, err := w.Write(b.bits)
+	return int64(n + written), err
+}
+
+func LoadBloomFilter(path string) (*BloomFilter, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	reader := bufio.NewReader(f)
+	header := make([]byte, len(bloomMagic)+12)
+	if _, err := io.ReadFull(reader, header); err != nil {
+		return nil, err
+	}
+	if string(header[:len(bloomMagic)]) != string(bloomMagic) {
+		return nil, errBadBloomFilter
+	}
+
+	b := &BloomFilter{
+		k: binary.LittleEndian.Uint32(header[len(bloomMagic):]),
+		m: binary.LittleEndian.Uint64(header[len(bloomMagic)+4:]),
+	}
+	if b.k == 0 || b.m == 0 {
+		return nil, errBadBloomFilter
+	}
+
+	b.bits = make([]byte, (b.m+7)/8)
+	if _, err := io.ReadFull(reader, b.bits); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+func hasBloomMagic(path string) (bool, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+
+	magic := make([]byte, len(bloomMagic))
+	if _, err := io.ReadFull(f, magic); err != nil {
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			return false, nil
+		}
+		return false, err
+	}
+	return string(magic) == string(bloomMagic), nil
+}
@@ -0,0 +1,62 @@
+// Package breach screens passwords against a local copy of the Have I Been Pwned
+// SHA-1 corpus, so no password or hash prefix ever leaves the server.
+package breach
+
+import (
+	"crypto/sha1"
+	"fmt"
+	"os"
+)
+
+// corpus answers whether a SHA-1 digest is in the breach corpus
+type corpus interface {
+	contains(digest [sha1.Size]byte) (bool, error)
+}
+
+var loaded corpus
+
+// Init loads the corpus at path, either a directory of HIBP range files, a single HIBP
+// file sorted by hash, or a bloom filter written by cmd/breachbloom. an empty path
+// disables screening.
+func Init(path string) error {
+	loaded = nil
+	if path == "" {
+		return nil
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+
+	if info.IsDir() {
+		loaded = rangeDir(path)
+		return nil
+	}
+
+	isBloom, err := hasBloomMagic(path)
+	if err != nil {
+		return err
+	}
+
+	if isBloom {
+		filter, err := LoadBloomFilter(path)
+		if err != nil {
+			return fmt.Errorf("load bloom filter: %w", err)
+		}
+		loaded = filter
+		return nil
+	}
+
+	loaded = sortedFile(path)
+	return nil
+}
+
+// IsBreached reports whether the password appears in the corpus, always false when
+// screening is disabled. bloom filters have a small false positive rate.
+func IsBreached(password string) (bool, error) {
+	if loaded == nil {
+		return false, nil
+	}
+	return loaded.contains(sha1.Sum([]byte(password)))
+}
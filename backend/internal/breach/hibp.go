@@ -0,0 +1,181 @@
+package breach
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+const (
+	prefixLength = 5
+	hashLength   = sha1.Size * 2
+)
+
+var errMalformedLine = errors.New("malformed hibp line")
+
+// rangeDir is the layout of the HIBP range API and the official downloader: one
+// PREFIX.txt file per 5 hex digit prefix, with SUFFIX:COUNT lines
+type rangeDir string
+
+func (d rangeDir) contains(digest [sha1.Size]byte) (bool, error) {
+	full := strings.ToUpper(hex.EncodeToString(digest[:]))
+	f, err := os.Open(filepath.Join(string(d), full[:prefixLength]+".txt"))
+	if errors.Is(err, os.ErrNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+
+	suffix := full[prefixLength:]
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		hash, _, _ := strings.Cut(strings.TrimSpace(scanner.Text()), ":")
+		if strings.EqualFold(hash, suffix) {
+			return true, nil
+		}
+	}
+	return false, scanner.Err()
+}
+
+// sortedFile is the single file download with HASH:COUNT lines sorted by hash. it is
+// binary searched on disk since the full corpus does not fit in memory.
+type sortedFile string
+
+func (s sortedFile) contains(digest [sha1.Size]byte) (bool, error) {
+	f, err := os.Open(string(s))
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return false, err
+	}
+
+	target := []byte(strings.ToUpper(hex.EncodeToString(digest[:])))
+
+	// find the first line starting at or after lo whose hash is >= target
+	lo, hi := int64(0), info.Size()
+	for lo < hi {
+		mid := lo + (hi-lo)/2
+		hash, _, err := hashAfter(f, mid)
+		if err != nil && !errors.Is(err, io.EOF) {
+			return false, err
+		}
+		if hash == nil || bytes.Compare(hash, target) >= 0 {
+			hi = mid
+		} else {
+			lo = mid + 1
+		}
+	}
+
+	hash, _, err := hashAfter(f, lo)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return false, err
+	}
+	return bytes.Equal(hash, target), nil
+}
+
+// hashAfter reads the hash on the first line that starts at or after offset. a nil hash
+// means there is no such line.
+func hashAfter(f *os.File, offset int64) ([]byte, int64, error) {
+	start := offset
+	if offset > 0 {
+		// skip the rest of the line offset falls in, unless it starts a line
+		prev := make([]byte, 1)
+		if _, err := f.ReadAt(prev, offset-1); err != nil {
+			return nil, 0, err
+		}
+		if prev[0] != '\n' {
+			reader := bufio.NewReader(io.NewSectionReader(f, offset, 1<<16))
+			skipped, err := reader.ReadBytes('\n')
+			if err != nil {
+				return nil, 0, err
+			}
+			start = offset + int64(len(skipped))
+		}
+	}
+
+	line := make([]byte, hashLength)
+	n, err := f.ReadAt(line, start)
+	if n < hashLength {
+		return nil, start, err
+	}
+	return bytes.ToUpper(line), start, nil
+}
+
+// ReadHashes calls fn with every hash in a HIBP range directory or sorted file seen at
+// least minCount times
+func ReadHashes(path string, minCount int, fn func(digest [sha1.Size]byte)) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+
+	if !info.IsDir() {
+		return readHashFile(path, "", minCount, fn)
+	}
+
+	entries, err := os.ReadDir(path)
+	if err != nil {
+		return err
+	}
+
+	for _, entry := range entries {
+		name := entry.Name()
+		prefix := strings.TrimSuffix(name, ".txt")
+		if entry.IsDir() || len(prefix) != prefixLength || prefix == name {
+			continue
+		}
+		if err := readHashFile(filepath.Join(path, name), prefix, minCount, fn); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func readHashFile(path, prefix string, minCount int, fn func(digest [sha1.Size]byte)) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+
+		hash, countStr, hasCount := strings.Cut(line, ":")
+		if hasCount && minCount > 1 {
+			count, err := strconv.Atoi(countStr)
+			if err != nil {
+				return errMalformedLine
+			}
+			if count < minCount {
+				continue
+			}
+		}
+
+		var digest [sha1.Size]byte
+		b, err := hex.DecodeString(prefix + hash)
+		if err != nil || len(b) != sha1.Size {
+			return errMalformedLine
+		}
+		copy(digest[:], b)
+		fn(digest)
+	}
+	return scanner.Err()
+}
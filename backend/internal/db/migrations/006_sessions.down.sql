@@ -0,0 +1,2 @@
+-- Remove per-device sessions
+DROP TABLE IF EXISTS sessions;
@@ -0,0 +1,14 @@
+-- Per-device sessions, referenced by the sid claim of session and refresh tokens
+CREATE TABLE sessions (
+    id BIGINT PRIMARY KEY,
+    user_id BIGINT NOT NULL,
+    created_at BIGINT NOT NULL,
+    last_seen_at BIGINT NOT NULL,
+    ip_address VARCHAR(45),
+    user_agent TEXT,
+    label VARCHAR(255),
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_sessions_user ON sessions(user_id);
+CREATE INDEX idx_sessions_last_seen_at ON sessions(last_seen_at);
@@ -0,0 +1,2 @@
+-- Remove personal access tokens
+DROP TABLE IF EXISTS api_tokens;
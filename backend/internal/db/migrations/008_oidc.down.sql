@@ -0,0 +1,3 @@
+-- Remove OpenID Connect identities
+DROP TABLE IF EXISTS oidc_states;
+DROP TABLE IF EXISTS user_identities;
@@ -0,0 +1,25 @@
+-- External OpenID Connect identities linked to users
+CREATE TABLE user_identities (
+    id BIGINT PRIMARY KEY,
+    user_id BIGINT NOT NULL,
+    provider VARCHAR(64) NOT NULL,
+    subject VARCHAR(255) NOT NULL,
+    email TEXT,
+    created_at BIGINT NOT NULL,
+    UNIQUE (provider, subject),
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_user_identities_user ON user_identities(user_id);
+
+-- In-flight authorization requests, keyed by the sha256 of the state parameter
+CREATE TABLE oidc_states (
+    state_hash VARCHAR(64) PRIMARY KEY,
+    provider VARCHAR(64) NOT NULL,
+    code_verifier VARCHAR(128) NOT NULL,
+    nonce VARCHAR(128) NOT NULL,
+    link_user_id BIGINT NOT NULL DEFAULT 0,
+    expires_at BIGINT NOT NULL
+);
+
+CREATE INDEX idx_oidc_states_expires_at ON oidc_states(expires_at);
@@ -0,0 +1,3 @@
+-- Remove passwordless sign-in tokens
+ALTER TABLE users DROP COLUMN magic_link_issuedat;
+ALTER TABLE users DROP COLUMN magic_link_token;
@@ -0,0 +1,3 @@
+-- Single-use passwordless sign-in tokens, stored as sha256 hashes
+ALTER TABLE users ADD COLUMN magic_link_token VARCHAR(64) NOT NULL DEFAULT '';
+ALTER TABLE users ADD COLUMN magic_link_issuedat BIGINT NOT NULL DEFAULT 0;
@@ -0,0 +1,2 @@
+-- Remove refresh token family tracking
+ALTER TABLE sessions DROP COLUMN refresh_jti;
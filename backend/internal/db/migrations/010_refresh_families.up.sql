@@ -0,0 +1,2 @@
+-- Each session is a refresh token family, only its latest refresh token may be redeemed
+ALTER TABLE sessions ADD COLUMN refresh_jti VARCHAR(255) NOT NULL DEFAULT '';
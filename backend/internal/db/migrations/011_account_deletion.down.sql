@@ -0,0 +1,4 @@
+-- Revert scheduled account deletion
+DROP INDEX IF EXISTS idx_users_deletion_scheduled_at;
+DROP TABLE IF EXISTS node_transfers;
+ALTER TABLE users DROP COLUMN deletion_scheduled_at;
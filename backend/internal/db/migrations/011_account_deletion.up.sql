@@ -0,0 +1,13 @@
+-- Scheduled account deletion, purged once the grace period ends
+ALTER TABLE users ADD COLUMN deletion_scheduled_at BIGINT NOT NULL DEFAULT 0;
+
+-- Owned nodes handed to a collaborator when the account is purged, the rest are deleted
+CREATE TABLE node_transfers (
+    node_id BIGINT PRIMARY KEY,
+    user_id BIGINT NOT NULL,
+    new_owner_id BIGINT NOT NULL,
+    FOREIGN KEY (node_id) REFERENCES nodes(id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_node_transfers_user ON node_transfers(user_id);
+CREATE INDEX idx_users_deletion_scheduled_at ON users(deletion_scheduled_at);
@@ -0,0 +1,5 @@
+-- Remove pending email address changes
+ALTER TABLE users DROP COLUMN email_change_issuedat;
+ALTER TABLE users DROP COLUMN email_change_cancel_token;
+ALTER TABLE users DROP COLUMN email_change_token;
+ALTER TABLE users DROP COLUMN pending_email;
@@ -0,0 +1,5 @@
+-- Pending email address change, confirmed from the new address and cancellable from the old one
+ALTER TABLE users ADD COLUMN pending_email TEXT NOT NULL DEFAULT '';
+ALTER TABLE users ADD COLUMN email_change_token VARCHAR(64) NOT NULL DEFAULT '';
+ALTER TABLE users ADD COLUMN email_change_cancel_token VARCHAR(64) NOT NULL DEFAULT '';
+ALTER TABLE users ADD COLUMN email_change_issuedat BIGINT NOT NULL DEFAULT 0;
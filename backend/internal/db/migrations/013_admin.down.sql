@@ -0,0 +1,6 @@
+-- Remove moderation state and the admin audit log
+DROP TABLE IF EXISTS audit_log;
+ALTER TABLE nodes DROP COLUMN takedown_reason;
+ALTER TABLE nodes DROP COLUMN taken_down_at;
+ALTER TABLE users DROP COLUMN status_reason;
+ALTER TABLE users DROP COLUMN account_status;
@@ -0,0 +1,22 @@
+-- Account moderation by administrators
+ALTER TABLE users ADD COLUMN account_status VARCHAR(16) NOT NULL DEFAULT 'active';
+ALTER TABLE users ADD COLUMN status_reason TEXT NOT NULL DEFAULT '';
+
+-- Nodes hidden from public pages by an administrator
+ALTER TABLE nodes ADD COLUMN taken_down_at BIGINT NOT NULL DEFAULT 0;
+ALTER TABLE nodes ADD COLUMN takedown_reason TEXT NOT NULL DEFAULT '';
+
+-- Record of every change made through the admin API
+CREATE TABLE audit_log (
+    id BIGINT PRIMARY KEY,
+    actor_id BIGINT NOT NULL,
+    action VARCHAR(64) NOT NULL,
+    target_type VARCHAR(32) NOT NULL,
+    target_id BIGINT NOT NULL,
+    details TEXT NOT NULL DEFAULT '',
+    ip_address VARCHAR(45) NOT NULL DEFAULT '',
+    created_at BIGINT NOT NULL
+);
+
+CREATE INDEX idx_audit_log_created_at ON audit_log(created_at);
+CREATE INDEX idx_audit_log_target ON audit_log(target_type, target_id);
@@ -0,0 +1,9 @@
+-- Back to lockouts on the exact (user, ip) pair
+DROP INDEX IF EXISTS idx_lockouts_unlock_token;
+DROP INDEX IF EXISTS idx_lockouts_ip_prefix;
+DROP INDEX IF EXISTS idx_failed_logins_ip_prefix;
+ALTER TABLE lockouts DROP COLUMN created_at;
+ALTER TABLE lockouts DROP COLUMN unlock_token;
+ALTER TABLE lockouts DROP COLUMN scope;
+ALTER TABLE lockouts DROP COLUMN ip_prefix;
+ALTER TABLE failed_logins DROP COLUMN ip_prefix;
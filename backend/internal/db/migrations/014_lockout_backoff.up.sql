@@ -0,0 +1,13 @@
+-- Lockouts keyed by address prefix (the address for IPv4, the /64 for IPv6) and scope
+ALTER TABLE failed_logins ADD COLUMN ip_prefix VARCHAR(45) NOT NULL DEFAULT '';
+UPDATE failed_logins SET ip_prefix = ip_address;
+
+ALTER TABLE lockouts ADD COLUMN ip_prefix VARCHAR(45) NOT NULL DEFAULT '';
+ALTER TABLE lockouts ADD COLUMN scope VARCHAR(16) NOT NULL DEFAULT 'pair';
+ALTER TABLE lockouts ADD COLUMN unlock_token VARCHAR(255) NOT NULL DEFAULT '';
+ALTER TABLE lockouts ADD COLUMN created_at BIGINT NOT NULL DEFAULT 0;
+UPDATE lockouts SET ip_prefix = ip_address WHERE ip_address IS NOT NULL;
+
+CREATE INDEX idx_failed_logins_ip_prefix ON failed_logins(ip_prefix);
+CREATE INDEX idx_lockouts_ip_prefix ON lockouts(ip_prefix);
+CREATE INDEX idx_lockouts_unlock_token ON lockouts(unlock_token);
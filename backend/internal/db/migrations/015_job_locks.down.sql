@@ -0,0 +1,2 @@
+-- Remove background job leases
+DROP TABLE IF EXISTS job_locks;
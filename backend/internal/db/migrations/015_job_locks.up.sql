@@ -0,0 +1,7 @@
+-- Leases so only one instance runs each background job at a time
+CREATE TABLE job_locks (
+    name VARCHAR(64) PRIMARY KEY,
+    holder VARCHAR(64) NOT NULL DEFAULT '',
+    locked_until BIGINT NOT NULL DEFAULT 0,
+    last_run_at BIGINT NOT NULL DEFAULT 0
+);
@@ -0,0 +1,7 @@
+-- Remove custom domain verification
+DROP INDEX IF EXISTS idx_nodes_domain;
+DROP INDEX IF EXISTS idx_nodes_verified_domain;
+ALTER TABLE nodes DROP COLUMN domain_check_failures;
+ALTER TABLE nodes DROP COLUMN domain_checked_at;
+ALTER TABLE nodes DROP COLUMN domain_verified_at;
+ALTER TABLE nodes DROP COLUMN domain_token;
@@ -0,0 +1,9 @@
+-- Custom domain verification through DNS
+ALTER TABLE nodes ADD COLUMN domain_token VARCHAR(64) NOT NULL DEFAULT '';
+ALTER TABLE nodes ADD COLUMN domain_verified_at BIGINT NOT NULL DEFAULT 0;
+ALTER TABLE nodes ADD COLUMN domain_checked_at BIGINT NOT NULL DEFAULT 0;
+ALTER TABLE nodes ADD COLUMN domain_check_failures INTEGER NOT NULL DEFAULT 0;
+
+-- several nodes may claim a domain, only one can prove it
+CREATE UNIQUE INDEX idx_nodes_verified_domain ON nodes(domain) WHERE domain_verified = TRUE;
+CREATE INDEX idx_nodes_domain ON nodes(domain);
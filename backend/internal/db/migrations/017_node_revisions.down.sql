@@ -0,0 +1,3 @@
+-- Remove node revisions
+DROP INDEX IF EXISTS idx_node_revisions_node;
+DROP TABLE IF EXISTS node_revisions;
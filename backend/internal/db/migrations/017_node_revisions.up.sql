@@ -0,0 +1,12 @@
+-- Immutable snapshots of a node's page and links, taken after every change
+CREATE TABLE node_revisions (
+    id BIGINT PRIMARY KEY,
+    node_id BIGINT NOT NULL,
+    user_id BIGINT NOT NULL DEFAULT 0,
+    action VARCHAR(64) NOT NULL DEFAULT '',
+    snapshot TEXT NOT NULL,
+    created_at BIGINT NOT NULL,
+    FOREIGN KEY (node_id) REFERENCES nodes(id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_node_revisions_node ON node_revisions(node_id, created_at);
@@ -0,0 +1,3 @@
+-- Remove node publications
+DROP INDEX IF EXISTS idx_node_publications_scheduled;
+DROP TABLE IF EXISTS node_publications;
@@ -0,0 +1,13 @@
+-- Published state of node pages, edits stay in the draft until they're published
+CREATE TABLE node_publications (
+    node_id BIGINT PRIMARY KEY,
+    snapshot TEXT NOT NULL,
+    published_at BIGINT NOT NULL,
+    published_by BIGINT NOT NULL DEFAULT 0,
+    scheduled_snapshot TEXT NOT NULL DEFAULT '',
+    scheduled_at BIGINT NOT NULL DEFAULT 0,
+    scheduled_by BIGINT NOT NULL DEFAULT 0,
+    FOREIGN KEY (node_id) REFERENCES nodes(id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_node_publications_scheduled ON node_publications(scheduled_at);
@@ -0,0 +1,4 @@
+-- Remove subdomain history
+DROP INDEX IF EXISTS idx_subdomain_history_node;
+DROP INDEX IF EXISTS idx_subdomain_history_name;
+DROP TABLE IF EXISTS subdomain_history;
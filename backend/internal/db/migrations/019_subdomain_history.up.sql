@@ -0,0 +1,13 @@
+-- Previous subdomains of nodes, redirected to the node's current one and held back from reuse for a while
+CREATE TABLE subdomain_history (
+    id BIGINT PRIMARY KEY,
+    node_id BIGINT NOT NULL,
+    subdomain_name VARCHAR(255) NOT NULL,
+    retired_at BIGINT NOT NULL,
+    held_until BIGINT NOT NULL,
+    released_at BIGINT NOT NULL DEFAULT 0,
+    FOREIGN KEY (node_id) REFERENCES nodes(id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_subdomain_history_name ON subdomain_history(subdomain_name);
+CREATE INDEX idx_subdomain_history_node ON subdomain_history(node_id);
@@ -0,0 +1,3 @@
+-- Restore the plain subdomain history name index
+DROP INDEX IF EXISTS idx_subdomain_history_lower_name;
+CREATE INDEX idx_subdomain_history_name ON subdomain_history(subdomain_name);
@@ -0,0 +1,3 @@
+-- Lookups ignore case, index the lowercased name instead
+DROP INDEX IF EXISTS idx_subdomain_history_name;
+CREATE INDEX idx_subdomain_history_lower_name ON subdomain_history(LOWER(subdomain_name));
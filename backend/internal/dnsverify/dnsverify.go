@@ -0,0 +1,109 @@
+// Package dnsverify checks that whoever controls a custom domain meant to point it at
+// a node, through a TXT record holding the node's token or a CNAME to the node's
+// subdomain on the platform host.
+package dnsverify
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strings"
+	"time"
+)
+
+// TXT records are looked up at this label under the custom domain
+const TxtLabel = "_treenode"
+
+// verification methods
+const (
+	MethodTxt   = "txt"
+	MethodCname = "cname"
+)
+
+var ErrNoRecord = errors.New("no matching TXT or CNAME record")
+
+// Resolver is the part of *net.Resolver used for verification, replaceable for tests
+type Resolver interface {
+	LookupTXT(ctx context.Context, name string) ([]string, error)
+	LookupCNAME(ctx context.Context, host string) (string, error)
+}
+
+var resolver Resolver = net.DefaultResolver
+
+// Init sends lookups to the DNS server at addr (host:port) instead of the system
+// resolver, so verification can run against a local stub. an empty addr keeps the
+// system resolver.
+func Init(addr string) {
+	if addr == "" {
+		resolver = net.DefaultResolver
+		return
+	}
+
+	dialer := &net.Dialer{Timeout: 5 * time.Second}
+	resolver = &net.Resolver{
+		PreferGo: true,
+		Dial: func(ctx context.Context, network, _ string) (net.Conn, error) {
+			return dialer.DialContext(ctx, network, addr)
+		},
+	}
+}
+
+// SetResolver replaces the resolver outright
+func SetResolver(r Resolver) {
+	resolver = r
+}
+
+// TxtName is the record name the token has to be published at
+func TxtName(domain string) string {
+	return TxtLabel + "." + domain
+}
+
+// CnameTarget is the name a custom domain may CNAME to, the node's subdomain on the
+// platform host. empty when either is unset.
+func CnameTarget(subdomain, platformHost string) string {
+	if subdomain == "" || platformHost == "" {
+		return ""
+	}
+	return subdomain + "." + platformHost
+}
+
+// Verify looks for the token in a TXT record at TxtName, then for a CNAME from the
+// domain to cnameTarget. it returns the method that matched, or ErrNoRecord when the
+// records exist but don't match. lookup failures other than a missing record are
+// returned as is, so callers can tell a broken resolver from a removed record.
+func Verify(ctx context.Context, domain, token, cnameTarget string) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	records, txtErr := resolver.LookupTXT(ctx, TxtName(domain))
+	for _, record := range records {
+		if strings.TrimSpace(record) == token {
+			return MethodTxt, nil
+		}
+	}
+
+	if cnameTarget != "" {
+		cname, err := resolver.LookupCNAME(ctx, domain)
+		if err == nil && normalize(cname) == normalize(cnameTarget) {
+			return MethodCname, nil
+		}
+		if err != nil && !isNotFound(err) {
+			return "", err
+		}
+	}
+
+	if txtErr != nil && !isNotFound(txtErr) {
+		return "", txtErr
+	}
+
+	return "", ErrNoRecord
+}
+
+func normalize(name string) string {
+	return strings.ToLower(strings.TrimSuffix(name, "."))
+}
+
+func isNotFound(err error) bool {
+	var dnsErr *net.DNSError
+	return errors.As(err, &dnsErr) && dnsErr.IsNotFound
+}
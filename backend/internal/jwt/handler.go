@@ -1,8 +1,6 @@
 package jwt
 
 import (
-	"crypto/hmac"
-	"crypto/sha256"
 	"encoding/base64"
 	"encoding/json"
 	"errors"
@@ -16,39 +14,88 @@ import (
 )
 
 func (jwt Jwt) GenerateToken() string {
+	alg := AlgHS256
+	if ring.current != nil {
+		alg = ring.current.Algorithm
+		jwt.Header.KeyID = ring.current.ID
+	}
+	jwt.Header.Algorithm = alg
+
 	header, _ := json.Marshal(jwt.Header)
 	payload, _ := json.Marshal(jwt.Payload)
 
-	data := base64.URLEncoding.EncodeToString(header) + "." + base64.URLEncoding.EncodeToString(payload)
+	data := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
 
-	h := hmac.New(sha256.New, config.JwtSecretBytes)
-	h.Write([]byte(data))
-	rawSig := h.Sum(nil)
+	rawSig, err := sign(alg, ring.current, config.JwtSecretBytes, []byte(data))
+	if err != nil {
+		// only reachable with a broken key, which Init already rejects
+		panic("failed to sign token: " + err.Error())
+	}
 
-	return data + "." + base64.URLEncoding.EncodeToString(rawSig)
+	return data + "." + base64.RawURLEncoding.EncodeToString(rawSig)
 }
 
 func ValidateToken(token string, secret []byte, tr *repo.TokenRepo) (*Claims, error) {
+	claims, err := ParseToken(token, secret)
+	if err != nil {
+		return nil, err
+	}
+
+	revoked, err := tr.IsTokenRevoked(claims.TokenID)
+	if err != nil || revoked {
+		return nil, errors.New("token revoked")
+	}
+
+	return claims, nil
+}
+
+// decodeSegment decodes unpadded base64url as RFC 7515 requires. padded segments
+// come from older releases and are only accepted while JWT_ACCEPT_LEGACY is on.
+func decodeSegment(segment string) ([]byte, error) {
+	if strings.HasSuffix(segment, "=") {
+		if !config.App.JwtAcceptLegacy {
+			return nil, errors.New("padded encoding not accepted")
+		}
+		return base64.URLEncoding.DecodeString(segment)
+	}
+	return base64.RawURLEncoding.DecodeString(segment)
+}
+
+// ParseToken checks the signature, issuer, audience and lifetime of a token without
+// consulting the blacklist, for callers that need to recognise revoked tokens being replayed
+func ParseToken(token string, secret []byte) (*Claims, error) {
 	parts := strings.Split(token, ".")
 	if len(parts) != 3 {
 		return nil, errors.New("invalid token format")
 	}
 
+	headerBytes, err := decodeSegment(parts[0])
+	if err != nil {
+		return nil, errors.New("invalid header encoding")
+	}
+
+	var header Header
+	if err := json.Unmarshal(headerBytes, &header); err != nil {
+		return nil, errors.New("invalid header json")
+	}
+
+	switch header.Algorithm {
+	case AlgHS256, AlgEdDSA, AlgES256:
+	default:
+		return nil, errors.New("unsupported token algorithm")
+	}
+
 	data := parts[0] + "." + parts[1]
-	signature, err := base64.URLEncoding.DecodeString(parts[2])
+	signature, err := decodeSegment(parts[2])
 	if err != nil {
 		return nil, errors.New("invalid signature encoding")
 	}
 
-	h := hmac.New(sha256.New, secret)
-	h.Write([]byte(data))
-	expectedSig := h.Sum(nil)
-
-	if !hmac.Equal(signature, expectedSig) {
-		return nil, errors.New("invalid token signature")
+	if err := verify(header.Algorithm, header.KeyID, secret, []byte(data), signature); err != nil {
+		return nil, err
 	}
 
-	payloadBytes, err := base64.URLEncoding.DecodeString(parts[1])
+	payloadBytes, err := decodeSegment(parts[1])
 	if err != nil {
 		return nil, errors.New("invalid payload encoding")
 	}
@@ -58,16 +105,27 @@ func ValidateToken(token string, secret []byte, tr *repo.TokenRepo) (*Claims, er
 		return nil, errors.New("invalid payload json")
 	}
 
+	// tokens from older releases carry neither claim
+	legacy := claims.Issuer == "" && len(claims.Audience) == 0 && config.App.JwtAcceptLegacy
+	if !legacy {
+		if claims.Issuer != config.App.JwtIssuer {
+			return nil, errors.New("invalid token issuer")
+		}
+		if !claims.HasAudience(config.App.JwtAudience) {
+			return nil, errors.New("invalid token audience")
+		}
+	}
+
 	now := time.Now().UTC().Unix()
-	if claims.Expiration != 0 && now > claims.Expiration {
+	leeway := config.App.JwtLeeway
+	if claims.Expiration != 0 && now > claims.Expiration+leeway {
 		return nil, errors.New("token expired")
 	}
-	if claims.IssuedAt != 0 && now < claims.IssuedAt {
+	if claims.NotBefore != 0 && now+leeway < claims.NotBefore {
 		return nil, errors.New("token not valid yet")
 	}
-	revoked, err := tr.IsTokenRevoked(claims.TokenID)
-	if err != nil || revoked {
-		return nil, errors.New("token revoked")
+	if claims.IssuedAt != 0 && now+leeway < claims.IssuedAt {
+		return nil, errors.New("token not valid yet")
 	}
 
 	return &claims, nil
@@ -76,22 +134,25 @@ func ValidateToken(token string, secret []byte, tr *repo.TokenRepo) (*Claims, er
 func CreateJwt(claims Claims) Jwt {
 	return Jwt{
 		Header: Header{
-			Algorithm: "HS256",
+			Algorithm: AlgHS256,
 			Type:      "JWT",
 		},
 		Payload: claims,
 	}
 }
 
-func CreateJwtFromUser(user *model.User) Jwt {
+// CreateJwtFromUser builds claims for the user, bound to the given device session
+func CreateJwtFromUser(user *model.User, sessionID int64) Jwt {
 	now := time.Now().UTC().Unix()
 	claims := Claims{
+		Issuer:    config.App.JwtIssuer,
 		UserID:    user.ID,
+		Audience:  []string{config.App.JwtAudience},
 		TokenID:   uuid.New().String(),
 		IssuedAt:  now,
-		Email:     user.Email,
+		NotBefore: now,
 		Role:      user.Role,
-		SessionID: user.JwtSessionID,
+		SessionID: sessionID,
 	}
 
 	return CreateJwt(claims)
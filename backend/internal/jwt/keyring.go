@@ -0,0 +1,281 @@
+package jwt
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"strings"
+
+	"github.com/akramboussanni/treenode/config"
+	"github.com/akramboussanni/treenode/internal/applog"
+)
+
+const (
+	AlgHS256 = "HS256"
+	AlgEdDSA = "EdDSA"
+	AlgES256 = "ES256"
+)
+
+var (
+	ErrUnknownKey         = errors.New("unknown signing key")
+	ErrUnsupportedKeyType = errors.New("unsupported key type, use ed25519 or P-256")
+)
+
+// Key is an asymmetric key in the keyring. verify-only keys have no private half.
+type Key struct {
+	ID        string
+	Algorithm string
+	private   crypto.Signer
+	public    crypto.PublicKey
+}
+
+// JWK is the public form of a key as served from /.well-known/jwks.json
+type JWK struct {
+	KeyType   string `json:"kty"`
+	Curve     string `json:"crv"`
+	X         string `json:"x"`
+	Y         string `json:"y,omitempty"`
+	KeyID     string `json:"kid"`
+	Algorithm string `json:"alg"`
+	Use       string `json:"use"`
+}
+
+type keyring struct {
+	current *Key // nil means HS256 with JWT_SECRET
+	keys    map[string]*Key
+}
+
+var ring = &keyring{keys: map[string]*Key{}}
+
+// Init loads the signing key and the verify-only keys from the files configured in
+// JWT_SIGNING_KEY_FILE and JWT_VERIFY_KEY_FILES. with no signing key, tokens keep
+// being signed with HS256 and JWT_SECRET. once a signing key is set, HS256 tokens are
+// only accepted while JWT_ACCEPT_HS256 is on.
+func Init() error {
+	loaded := &keyring{keys: map[string]*Key{}}
+
+	if path := strings.TrimSpace(config.App.JwtSigningKeyFile); path != "" {
+		key, err := loadKeyFile(path)
+		if err != nil {
+			return fmt.Errorf("JWT_SIGNING_KEY_FILE: %w", err)
+		}
+		if key.private == nil {
+			return errors.New("JWT_SIGNING_KEY_FILE must contain a private key")
+		}
+		loaded.current = key
+		loaded.keys[key.ID] = key
+	}
+
+	for _, path := range strings.Split(config.App.JwtVerifyKeyFiles, ",") {
+		path = strings.TrimSpace(path)
+		if path == "" {
+			continue
+		}
+
+		key, err := loadKeyFile(path)
+		if err != nil {
+			return fmt.Errorf("JWT_VERIFY_KEY_FILES %s: %w", path, err)
+		}
+
+		// old keys are only ever used to check signatures
+		key.private = nil
+		if _, ok := loaded.keys[key.ID]; !ok {
+			loaded.keys[key.ID] = key
+		}
+	}
+
+	if loaded.current != nil && config.App.JwtAcceptHS256 {
+		applog.Warn("JWT_ACCEPT_HS256 is on, tokens signed with JWT_SECRET are still accepted. turn it off once they have expired")
+	}
+
+	ring = loaded
+	return nil
+}
+
+// JWKS returns the public keys other services can verify treenode tokens with
+func JWKS() []JWK {
+	jwks := make([]JWK, 0, len(ring.keys))
+	if ring.current != nil {
+		jwks = append(jwks, ring.current.jwk())
+	}
+	for id, key := range ring.keys {
+		if ring.current != nil && id == ring.current.ID {
+			continue
+		}
+		jwks = append(jwks, key.jwk())
+	}
+	return jwks
+}
+
+func loadKeyFile(path string) (*Key, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM block found")
+	}
+
+	return parseKey(block)
+}
+
+func parseKey(block *pem.Block) (*Key, error) {
+	var parsed any
+	var err error
+
+	switch block.Type {
+	case "PRIVATE KEY":
+		parsed, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "EC PRIVATE KEY":
+		parsed, err = x509.ParseECPrivateKey(block.Bytes)
+	case "PUBLIC KEY":
+		parsed, err = x509.ParsePKIXPublicKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("unsupported PEM block %q", block.Type)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	key := &Key{}
+	switch k := parsed.(type) {
+	case ed25519.PrivateKey:
+		key.Algorithm, key.private, key.public = AlgEdDSA, k, k.Public()
+	case ed25519.PublicKey:
+		key.Algorithm, key.public = AlgEdDSA, k
+	case *ecdsa.PrivateKey:
+		if k.Curve != elliptic.P256() {
+			return nil, ErrUnsupportedKeyType
+		}
+		key.Algorithm, key.private, key.public = AlgES256, k, &k.PublicKey
+	case *ecdsa.PublicKey:
+		if k.Curve != elliptic.P256() {
+			return nil, ErrUnsupportedKeyType
+		}
+		key.Algorithm, key.public = AlgES256, k
+	default:
+		return nil, ErrUnsupportedKeyType
+	}
+
+	key.ID = key.thumbprint()
+	return key, nil
+}
+
+func (k *Key) jwk() JWK {
+	jwk := JWK{KeyID: k.ID, Algorithm: k.Algorithm, Use: "sig"}
+	switch pub := k.public.(type) {
+	case ed25519.PublicKey:
+		jwk.KeyType, jwk.Curve = "OKP", "Ed25519"
+		jwk.X = base64.RawURLEncoding.EncodeToString(pub)
+	case *ecdsa.PublicKey:
+		jwk.KeyType, jwk.Curve = "EC", "P-256"
+		jwk.X = base64.RawURLEncoding.EncodeToString(pub.X.FillBytes(make([]byte, 32)))
+		jwk.Y = base64.RawURLEncoding.EncodeToString(pub.Y.FillBytes(make([]byte, 32)))
+	}
+	return jwk
+}
+
+// thumbprint derives the kid from the public key (RFC 7638), so it never has to be configured
+func (k *Key) thumbprint() string {
+	jwk := k.jwk()
+	var canonical []byte
+	if jwk.KeyType == "EC" {
+		canonical, _ = json.Marshal(struct {
+			Crv string `json:"crv"`
+			Kty string `json:"kty"`
+			X   string `json:"x"`
+			Y   string `json:"y"`
+		}{jwk.Curve, jwk.KeyType, jwk.X, jwk.Y})
+	} else {
+		canonical, _ = json.Marshal(struct {
+			Crv string `json:"crv"`
+			Kty string `json:"kty"`
+			X   string `json:"x"`
+		}{jwk.Curve, jwk.KeyType, jwk.X})
+	}
+
+	sum := sha256.Sum256(canonical)
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// sign produces a JWS signature over data. ES256 signatures are the raw r||s form
+// required by RFC 7518, not ASN.1.
+func sign(alg string, key *Key, secret, data []byte) ([]byte, error) {
+	switch alg {
+	case AlgHS256:
+		h := hmac.New(sha256.New, secret)
+		h.Write(data)
+		return h.Sum(nil), nil
+	case AlgEdDSA:
+		return ed25519.Sign(key.private.(ed25519.PrivateKey), data), nil
+	case AlgES256:
+		digest := sha256.Sum256(data)
+		r, s, err := ecdsa.Sign(rand.Reader, key.private.(*ecdsa.PrivateKey), digest[:])
+		if err != nil {
+			return nil, err
+		}
+		raw := make([]byte, 64)
+		r.FillBytes(raw[:32])
+		s.FillBytes(raw[32:])
+		return raw, nil
+	}
+	return nil, fmt.Errorf("unsupported algorithm %q", alg)
+}
+
+func verify(alg, kid string, secret, data, signature []byte) error {
+	if alg == AlgHS256 {
+		// JWT_SECRET may be shared with other services, it can't be trusted once a key replaced it
+		if ring.current != nil && !config.App.JwtAcceptHS256 {
+			return errors.New("HS256 tokens not accepted")
+		}
+		expected, _ := sign(AlgHS256, nil, secret, data)
+		if !hmac.Equal(signature, expected) {
+			return errors.New("invalid token signature")
+		}
+		return nil
+	}
+
+	key, ok := ring.keys[kid]
+	if !ok {
+		return ErrUnknownKey
+	}
+
+	// the key decides the algorithm, never the token
+	if key.Algorithm != alg {
+		return errors.New("algorithm does not match key")
+	}
+
+	switch pub := key.public.(type) {
+	case ed25519.PublicKey:
+		if !ed25519.Verify(pub, data, signature) {
+			return errors.New("invalid token signature")
+		}
+	case *ecdsa.PublicKey:
+		if len(signature) != 64 {
+			return errors.New("invalid token signature")
+		}
+		digest := sha256.Sum256(data)
+		r := new(big.Int).SetBytes(signature[:32])
+		s := new(big.Int).SetBytes(signature[32:])
+		if !ecdsa.Verify(pub, digest[:], r, s) {
+			return errors.New("invalid token signature")
+		}
+	default:
+		return ErrUnsupportedKeyType
+	}
+	return nil
+}
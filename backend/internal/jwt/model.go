@@ -1,6 +1,9 @@
 package jwt
 
 import (
+	"encoding/json"
+	"errors"
+	"strconv"
 	"time"
 
 	"github.com/akramboussanni/treenode/config"
@@ -21,15 +24,98 @@ func (j Jwt) WithType(t model.JwtType) Jwt {
 type Header struct {
 	Algorithm string `json:"alg"`
 	Type      string `json:"typ"`
+	KeyID     string `json:"kid,omitempty"`
 }
 
 type Claims struct {
+	Issuer     string        `json:"iss,omitempty"`
 	UserID     int64         `json:"sub"`
+	Audience   []string      `json:"aud,omitempty"`
 	TokenID    string        `json:"jti"`
 	SessionID  int64         `json:"sid"`
 	IssuedAt   int64         `json:"iat"`
+	NotBefore  int64         `json:"nbf,omitempty"`
 	Expiration int64         `json:"exp"`
-	Email      string        `json:"email"`
 	Role       string        `json:"role"`
 	Type       model.JwtType `json:"type"`
+	NodeID     int64         `json:"nid,string,omitempty"` // node a preview token shows the draft of
+}
+
+// PairExpiry is when every token sharing this jti has expired. the session and refresh
+// tokens from a login share their jti, so a blacklist entry has to outlive both.
+func (c *Claims) PairExpiry() int64 {
+	exp := c.IssuedAt + config.App.JwtExpirations[string(model.RefreshJwt)]
+	if c.Expiration > exp {
+		return c.Expiration
+	}
+	return exp
+}
+
+// HasAudience reports whether aud lists the given audience
+func (c *Claims) HasAudience(aud string) bool {
+	for _, a := range c.Audience {
+		if a == aud {
+			return true
+		}
+	}
+	return false
+}
+
+// MarshalJSON writes sub as a string and a single audience as a plain string, as
+// RFC 7519 expects
+func (c Claims) MarshalJSON() ([]byte, error) {
+	type alias Claims
+	var aud any
+	switch len(c.Audience) {
+	case 0:
+	case 1:
+		aud = c.Audience[0]
+	default:
+		aud = c.Audience
+	}
+
+	return json.Marshal(struct {
+		alias
+		UserID   string `json:"sub"`
+		Audience any    `json:"aud,omitempty"`
+	}{alias(c), strconv.FormatInt(c.UserID, 10), aud})
+}
+
+// UnmarshalJSON accepts sub as a string or a number (legacy tokens) and aud as a
+// string or an array
+func (c *Claims) UnmarshalJSON(b []byte) error {
+	type alias Claims
+	aux := struct {
+		*alias
+		UserID   json.RawMessage `json:"sub"`
+		Audience json.RawMessage `json:"aud"`
+	}{alias: (*alias)(c)}
+
+	if err := json.Unmarshal(b, &aux); err != nil {
+		return err
+	}
+
+	if len(aux.UserID) > 0 {
+		sub := string(aux.UserID)
+		if unquoted, err := strconv.Unquote(sub); err == nil {
+			sub = unquoted
+		}
+		id, err := strconv.ParseInt(sub, 10, 64)
+		if err != nil {
+			return errors.New("invalid sub claim")
+		}
+		c.UserID = id
+	}
+
+	c.Audience = nil
+	if len(aux.Audience) > 0 && string(aux.Audience) != "null" {
+		var single string
+		if err := json.Unmarshal(aux.Audience, &single); err == nil {
+			c.Audience = []string{single}
+		} else if err := json.Unmarshal(aux.Audience, &c.Audience); err != nil {
+			return errors.New("invalid aud claim")
+		}
+	}
+
+	return nil
 }
@@ -2,7 +2,11 @@ package middleware
 
 import (
 	"context"
+	"database/sql"
+	"errors"
 	"net/http"
+	"strings"
+	"time"
 
 	"github.com/akramboussanni/treenode/config"
 	"github.com/akramboussanni/treenode/internal/api"
@@ -13,15 +17,76 @@ import (
 	"github.com/go-chi/chi/v5"
 )
 
-func AddAuth(r chi.Router, ur *repo.UserRepo, tr *repo.TokenRepo) {
+// how often last_used_at is bumped for a personal access token, to avoid a write per request
+const apiTokenTouchInterval = 60
+
+func AddAuth(r chi.Router, ur *repo.UserRepo, tr *repo.TokenRepo, sr *repo.SessionRepo) {
+	r.Use(func(next http.Handler) http.Handler {
+		return JWTAuth(config.JwtSecretBytes, ur, tr, sr, nil, model.CredentialJwt)(next)
+	})
+}
+
+// AddApiAuth is AddAuth that also accepts personal access tokens. every route group
+// below it must declare what tokens may do there with AddScope.
+func AddApiAuth(r chi.Router, ur *repo.UserRepo, tr *repo.TokenRepo, sr *repo.SessionRepo, atr *repo.ApiTokenRepo) {
+	r.Use(func(next http.Handler) http.Handler {
+		return JWTAuth(config.JwtSecretBytes, ur, tr, sr, atr, model.CredentialJwt)(next)
+	})
+}
+
+// AddRole limits a route group to users with the given role. the role is read from
+// the user loaded by AddAuth, never from the token, so demotions apply immediately.
+func AddRole(r chi.Router, role string) {
+	r.Use(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			user, ok := utils.UserFromContext(r.Context())
+			if !ok || user.Role != role {
+				api.WriteMessage(w, 403, "error", "forbidden")
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	})
+}
+
+// AddScope limits personal access tokens in a route group. reads need the read scope,
+// anything else needs the write scope. an empty scope keeps that half cookie-only.
+// requests authenticated with the session cookie are not affected.
+func AddScope(r chi.Router, read, write model.ApiScope) {
 	r.Use(func(next http.Handler) http.Handler {
-		return JWTAuth(config.JwtSecretBytes, ur, tr, model.CredentialJwt)(next)
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			token, ok := utils.ApiTokenFromContext(r.Context())
+			if !ok {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			scope := write
+			if r.Method == http.MethodGet || r.Method == http.MethodHead {
+				scope = read
+			}
+
+			if scope == "" || !token.HasScope(scope) {
+				api.WriteMessage(w, 403, "error", "token lacks required scope")
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
 	})
 }
 
-func JWTAuth(secret []byte, ur *repo.UserRepo, tr *repo.TokenRepo, expectedType model.JwtType) func(http.Handler) http.Handler {
+// JWTAuth authenticates with the session cookie. when atr is set, an Authorization
+// Bearer personal access token is accepted instead.
+func JWTAuth(secret []byte, ur *repo.UserRepo, tr *repo.TokenRepo, sr *repo.SessionRepo, atr *repo.ApiTokenRepo, expectedType model.JwtType) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if raw, ok := bearerToken(r); ok && atr != nil {
+				apiTokenAuth(w, r, next, raw, ur, atr)
+				return
+			}
+
 			claims := GetClaimsFromCookie(w, r, secret, ur, tr)
 			if claims == nil {
 				return
@@ -33,22 +98,84 @@ func JWTAuth(secret []byte, ur *repo.UserRepo, tr *repo.TokenRepo, expectedType
 			}
 
 			user, err := ur.GetUserByID(r.Context(), claims.UserID)
+			if errors.Is(err, sql.ErrNoRows) {
+				// the account was deleted
+				api.WriteInvalidCredentials(w)
+				return
+			}
 			if err != nil {
 				api.WriteInternalError(w)
 				return
 			}
 
-			if claims.SessionID != user.JwtSessionID {
+			if !user.IsActive() {
+				api.WriteMessage(w, 403, "error", "account disabled")
+				return
+			}
+
+			session, err := sr.GetSessionByID(r.Context(), claims.SessionID)
+			if err != nil || session.UserID != user.ID {
 				api.WriteInvalidCredentials(w)
 				return
 			}
 
 			ctx := context.WithValue(r.Context(), utils.UserKey, user)
+			ctx = context.WithValue(ctx, utils.SessionKey, session.ID)
 			next.ServeHTTP(w, r.WithContext(ctx))
 		})
 	}
 }
 
+func bearerToken(r *http.Request) (string, bool) {
+	header := r.Header.Get("Authorization")
+	if len(header) < 7 || !strings.EqualFold(header[:7], "Bearer ") {
+		return "", false
+	}
+	return strings.TrimSpace(header[7:]), true
+}
+
+func apiTokenAuth(w http.ResponseWriter, r *http.Request, next http.Handler, raw string, ur *repo.UserRepo, atr *repo.ApiTokenRepo) {
+	hash, ok := utils.HashApiToken(raw)
+	if !ok {
+		api.WriteInvalidCredentials(w)
+		return
+	}
+
+	token, err := atr.GetApiTokenByHash(r.Context(), hash)
+	if err != nil {
+		api.WriteInvalidCredentials(w)
+		return
+	}
+
+	now := time.Now().UTC().Unix()
+	if token.ExpiresAt != 0 && token.ExpiresAt <= now {
+		api.WriteInvalidCredentials(w)
+		return
+	}
+
+	user, err := ur.GetUserByID(r.Context(), token.UserID)
+	if err != nil {
+		api.WriteInternalError(w)
+		return
+	}
+
+	if !user.IsActive() {
+		api.WriteMessage(w, 403, "error", "account disabled")
+		return
+	}
+
+	if now-token.LastUsedAt >= apiTokenTouchInterval {
+		if err := atr.TouchApiToken(r.Context(), token.ID, now); err != nil {
+			api.WriteInternalError(w)
+			return
+		}
+	}
+
+	ctx := context.WithValue(r.Context(), utils.UserKey, user)
+	ctx = context.WithValue(ctx, utils.ApiTokenKey, token)
+	next.ServeHTTP(w, r.WithContext(ctx))
+}
+
 func GetClaimsFromCookie(w http.ResponseWriter, r *http.Request, secret []byte, ur *repo.UserRepo, tr *repo.TokenRepo) *jwt.Claims {
 	sessionCookie, err := r.Cookie("session")
 	if err != nil {
@@ -20,6 +20,12 @@ func AddRecaptcha(r chi.Router) {
 
 func validateRecaptcha(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// scripted clients can't solve a captcha, a personal access token already proves who they are
+		if _, ok := utils.ApiTokenFromContext(r.Context()); ok {
+			next.ServeHTTP(w, r)
+			return
+		}
+
 		token := r.Header.Get("X-Recaptcha-Token")
 		ip := utils.GetClientIP(r)
 
@@ -0,0 +1,44 @@
+package model
+
+import "strings"
+
+type ApiScope string
+
+const (
+	ScopeNodesRead           ApiScope = "nodes:read"
+	ScopeLinksWrite          ApiScope = "links:write"
+	ScopeCollaboratorsManage ApiScope = "collaborators:manage"
+)
+
+var ApiScopes = []ApiScope{ScopeNodesRead, ScopeLinksWrite, ScopeCollaboratorsManage}
+
+// ApiToken is a long-lived personal access token. scopes are stored comma separated
+// and an ExpiresAt of 0 means the token never expires.
+type ApiToken struct {
+	ID         int64  `json:"id,string" db:"id"`
+	UserID     int64  `json:"-" db:"user_id"`
+	Name       string `json:"name" db:"name"`
+	TokenHash  string `json:"-" db:"token_hash"`
+	Scopes     string `json:"scopes" db:"scopes"`
+	ExpiresAt  int64  `json:"expires_at,string" db:"expires_at"`
+	LastUsedAt int64  `json:"last_used_at,string" db:"last_used_at"`
+	CreatedAt  int64  `json:"created_at,string" db:"created_at"`
+}
+
+func (t *ApiToken) HasScope(scope ApiScope) bool {
+	for _, s := range strings.Split(t.Scopes, ",") {
+		if ApiScope(s) == scope {
+			return true
+		}
+	}
+	return false
+}
+
+func IsValidApiScope(scope ApiScope) bool {
+	for _, s := range ApiScopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,26 @@
+package model
+
+// AuditEntry records a change made through the admin API
+type AuditEntry struct {
+	ID         int64  `json:"id,string" db:"id"`
+	ActorID    int64  `json:"actor_id,string" db:"actor_id"`
+	Action     string `json:"action" db:"action" example:"user.status"`
+	TargetType string `json:"target_type" db:"target_type" example:"user"`
+	TargetID   int64  `json:"target_id,string" db:"target_id"`
+	Details    string `json:"details" db:"details" example:"disabled: spam"`
+	IPAddress  string `json:"ip_address" db:"ip_address"`
+	CreatedAt  int64  `json:"created_at,string" db:"created_at"`
+}
+
+const (
+	AuditTargetUser = "user"
+	AuditTargetNode = "node"
+)
+
+const (
+	AuditUserConfirmEmail = "user.confirm_email"
+	AuditUserUnlock       = "user.unlock"
+	AuditUserStatus       = "user.status"
+	AuditNodeTakedown     = "node.takedown"
+	AuditNodeRestore      = "node.restore"
+)
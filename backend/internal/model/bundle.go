@@ -0,0 +1,112 @@
+package model
+
+// NodeBundleVersion is the schema version written in exports. imports take bundles up
+// to this version.
+const NodeBundleVersion = 1
+
+// NodeBundle is a portable export of a node's page, links and color stops. it carries
+// no ids, owner, collaborators or domain, so it can be imported on any instance.
+type NodeBundle struct {
+	SchemaVersion int          `json:"schema_version" example:"1"`
+	ExportedAt    int64        `json:"exported_at,string"`
+	SubdomainName string       `json:"subdomain_name" example:"johndoe"`
+	Node          BundleNode   `json:"node"`
+	Links         []BundleLink `json:"links"` // in order
+}
+
+type BundleNode struct {
+	DisplayName         string `json:"display_name"`
+	Description         string `json:"description"`
+	BackgroundColor     string `json:"background_color"`
+	TitleFontColor      string `json:"title_font_color"`
+	CaptionFontColor    string `json:"caption_font_color"`
+	AccentColor         string `json:"accent_color"`
+	ThemeColor          string `json:"theme_color"`
+	ShowShareButton     bool   `json:"show_share_button"`
+	Theme               string `json:"theme"`
+	MouseEffectsEnabled bool   `json:"mouse_effects_enabled"`
+	TextShadowsEnabled  bool   `json:"text_shadows_enabled"`
+	HidePoweredBy       bool   `json:"hide_powered_by"`
+	PageTitle           string `json:"page_title"`
+}
+
+type BundleLink struct {
+	Name                          string            `json:"name"`
+	DisplayName                   string            `json:"display_name"`
+	Link                          string            `json:"link"`
+	Description                   string            `json:"description"`
+	Icon                          string            `json:"icon"`
+	Visible                       bool              `json:"visible"`
+	Enabled                       bool              `json:"enabled"`
+	Mini                          bool              `json:"mini"`
+	GradientType                  string            `json:"gradient_type"`
+	GradientAngle                 float64           `json:"gradient_angle"`
+	ColorStops                    []BundleColorStop `json:"color_stops"`
+	CustomAccentColorEnabled      bool              `json:"custom_accent_color_enabled"`
+	CustomAccentColor             string            `json:"custom_accent_color"`
+	CustomTitleColorEnabled       bool              `json:"custom_title_color_enabled"`
+	CustomTitleColor              string            `json:"custom_title_color"`
+	CustomDescriptionColorEnabled bool              `json:"custom_description_color_enabled"`
+	CustomDescriptionColor        string            `json:"custom_description_color"`
+	MiniBackgroundEnabled         bool              `json:"mini_background_enabled"`
+}
+
+type BundleColorStop struct {
+	Color    string  `json:"color"`
+	Position float64 `json:"position"`
+}
+
+// NewNodeBundle exports the node with its links, which must have their color stops loaded
+func NewNodeBundle(node *Node, links []Link, now int64) NodeBundle {
+	bundle := NodeBundle{
+		SchemaVersion: NodeBundleVersion,
+		ExportedAt:    now,
+		SubdomainName: node.SubdomainName,
+		Node: BundleNode{
+			DisplayName:         node.DisplayName,
+			Description:         node.Description,
+			BackgroundColor:     node.BackgroundColor,
+			TitleFontColor:      node.TitleFontColor,
+			CaptionFontColor:    node.CaptionFontColor,
+			AccentColor:         node.AccentColor,
+			ThemeColor:          node.ThemeColor,
+			ShowShareButton:     node.ShowShareButton,
+			Theme:               node.Theme,
+			MouseEffectsEnabled: node.MouseEffectsEnabled,
+			TextShadowsEnabled:  node.TextShadowsEnabled,
+			HidePoweredBy:       node.HidePoweredBy,
+			PageTitle:           node.PageTitle,
+		},
+		Links: []BundleLink{},
+	}
+
+	for _, link := range links {
+		stops := []BundleColorStop{}
+		for _, stop := range link.ColorStops {
+			stops = append(stops, BundleColorStop{Color: stop.Color, Position: stop.Position})
+		}
+
+		bundle.Links = append(bundle.Links, BundleLink{
+			Name:                          link.Name,
+			DisplayName:                   link.DisplayName,
+			Link:                          link.Link,
+			Description:                   link.Description,
+			Icon:                          link.Icon,
+			Visible:                       link.Visible,
+			Enabled:                       link.Enabled,
+			Mini:                          link.Mini,
+			GradientType:                  link.GradientType,
+			GradientAngle:                 link.GradientAngle,
+			ColorStops:                    stops,
+			CustomAccentColorEnabled:      link.CustomAccentColorEnabled,
+			CustomAccentColor:             link.CustomAccentColor,
+			CustomTitleColorEnabled:       link.CustomTitleColorEnabled,
+			CustomTitleColor:              link.CustomTitleColor,
+			CustomDescriptionColorEnabled: link.CustomDescriptionColorEnabled,
+			CustomDescriptionColor:        link.CustomDescriptionColor,
+			MiniBackgroundEnabled:         link.MiniBackgroundEnabled,
+		})
+	}
+
+	return bundle
+}
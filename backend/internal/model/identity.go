@@ -0,0 +1,21 @@
+package model
+
+type Identity struct {
+	ID        int64  `json:"id,string" db:"id"`
+	UserID    int64  `json:"-" db:"user_id"`
+	Provider  string `json:"provider" db:"provider"`
+	Subject   string `json:"-" db:"subject"`
+	Email     string `json:"email" db:"email"`
+	CreatedAt int64  `json:"created_at,string" db:"created_at"`
+}
+
+// OidcState is a pending authorization request. LinkUserID is set when an
+// authenticated user is attaching a new identity rather than logging in.
+type OidcState struct {
+	StateHash    string `db:"state_hash"`
+	Provider     string `db:"provider"`
+	CodeVerifier string `db:"code_verifier"`
+	Nonce        string `db:"nonce"`
+	LinkUserID   int64  `db:"link_user_id"`
+	ExpiresAt    int64  `db:"expires_at"`
+}
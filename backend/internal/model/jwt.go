@@ -5,6 +5,8 @@ type JwtType string
 const (
 	CredentialJwt JwtType = "credential"
 	RefreshJwt    JwtType = "refresh"
+	MfaPendingJwt JwtType = "mfa"
+	PreviewJwt    JwtType = "preview" // read-only access to a node's draft
 )
 
 type JwtBlacklist struct {
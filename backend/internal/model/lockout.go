@@ -1,18 +1,32 @@
 package model
 
 type FailedLogin struct {
-	ID          int64  `db:"id"`
-	UserID      int64  `db:"user_id"`
-	IPAddress   string `db:"ip_address"`
-	AttemptedAt int64  `db:"attempted_at"`
-	Active      bool   `db:"active"`
+	ID          int64  `json:"-" db:"id"`
+	UserID      int64  `json:"-" db:"user_id"` // 0 for attempts on unknown emails
+	IPAddress   string `json:"ip_address" db:"ip_address"`
+	IPPrefix    string `json:"-" db:"ip_prefix"`
+	AttemptedAt int64  `json:"attempted_at,string" db:"attempted_at"`
+	Active      bool   `json:"-" db:"active"`
 }
 
+// lockout scopes. pair locks one user from one address prefix, ip locks an address
+// prefix out of every account, account locks a user out from everywhere until the
+// owner unlocks it by email or it expires.
+const (
+	LockoutPair    = "pair"
+	LockoutIP      = "ip"
+	LockoutAccount = "account"
+)
+
 type Lockout struct {
 	ID          int64  `db:"id"`
 	UserID      int64  `db:"user_id"`
 	IPAddress   string `db:"ip_address"`
+	IPPrefix    string `db:"ip_prefix"`
+	Scope       string `db:"scope"`
 	LockedUntil int64  `db:"locked_until"`
 	Reason      string `db:"reason"`
+	UnlockToken string `db:"unlock_token"`
+	CreatedAt   int64  `db:"created_at"`
 	Active      bool   `db:"active"`
 }
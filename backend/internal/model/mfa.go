@@ -0,0 +1,9 @@
+package model
+
+type RecoveryCode struct {
+	ID        int64  `db:"id"`
+	UserID    int64  `db:"user_id"`
+	CodeHash  string `db:"code_hash"`
+	UsedAt    int64  `db:"used_at"`
+	CreatedAt int64  `db:"created_at"`
+}
@@ -6,7 +6,7 @@ type Node struct {
 	ID                  int64   `json:"id,string" safe:"true" db:"id"`
 	OwnerID             int64   `json:"owner_id,string" safe:"true" db:"owner_id"`
 	DisplayName         string  `json:"display_name" safe:"true" db:"display_name"`
-	SubdomainName       string  `json:"subdomain_name" safe:"true"db:"subdomain_name"`
+	SubdomainName       string  `json:"subdomain_name" safe:"true" db:"subdomain_name"`
 	Description         string  `json:"description" safe:"true" db:"description"`
 	BackgroundColor     string  `json:"background_color" safe:"true" db:"background_color"`
 	TitleFontColor      string  `json:"title_font_color" safe:"true" db:"title_font_color"`
@@ -21,8 +21,14 @@ type Node struct {
 	PageTitle           string  `json:"page_title" safe:"true" db:"page_title"`
 	Domain              string  `json:"domain" safe:"true" db:"domain"`
 	DomainVerified      bool    `json:"domain_verified" safe:"true" db:"domain_verified"`
+	DomainToken         string  `json:"-" db:"domain_token"`
+	DomainVerifiedAt    int64   `json:"domain_verified_at,string" safe:"true" db:"domain_verified_at"`
+	DomainCheckedAt     int64   `json:"-" db:"domain_checked_at"`
+	DomainCheckFailures int     `json:"-" db:"domain_check_failures"`
 	CreatedAt           int64   `json:"created_at" safe:"true" db:"created_at"`
 	UpdatedAt           int64   `json:"updated_at" safe:"true" db:"updated_at"`
+	TakenDownAt         int64   `json:"taken_down_at,string" safe:"true" db:"taken_down_at"`
+	TakedownReason      string  `json:"takedown_reason" safe:"true" db:"takedown_reason"`
 	Collaborators       []int64 `json:"collaborators,omitempty" safe:"true" db:"-"`
 }
 
@@ -37,3 +43,10 @@ func (n *Node) MarshalJSON() ([]byte, error) {
 		Collaborators: n.Collaborators,
 	})
 }
+
+// NodeTransfer hands a node to one of its collaborators when its owner's account is purged
+type NodeTransfer struct {
+	NodeID     int64 `json:"node_id,string" db:"node_id"`
+	UserID     int64 `json:"-" db:"user_id"`
+	NewOwnerID int64 `json:"new_owner_id,string" db:"new_owner_id"`
+}
@@ -0,0 +1,14 @@
+package model
+
+// NodePublication is the state of a node's page the public sees. edits to the node and
+// its links are a draft until published, nodes without a publication were never edited
+// as a draft and are served as they are.
+type NodePublication struct {
+	NodeID            int64  `json:"node_id,string" db:"node_id"`
+	Snapshot          string `json:"-" db:"snapshot"` // NodeSnapshot as json
+	PublishedAt       int64  `json:"published_at,string" db:"published_at"`
+	PublishedBy       int64  `json:"published_by,string" db:"published_by"`
+	ScheduledSnapshot string `json:"-" db:"scheduled_snapshot"`             // draft to publish at ScheduledAt
+	ScheduledAt       int64  `json:"scheduled_at,string" db:"scheduled_at"` // 0 when nothing is scheduled
+	ScheduledBy       int64  `json:"scheduled_by,string" db:"scheduled_by"`
+}
@@ -0,0 +1,88 @@
+package model
+
+// NodeRevision is a snapshot of a node's page and links taken after a change. revisions
+// are never edited, restoring one records a new revision.
+type NodeRevision struct {
+	ID        int64  `json:"id,string" db:"id"`
+	NodeID    int64  `json:"node_id,string" db:"node_id"`
+	UserID    int64  `json:"user_id,string" db:"user_id"`
+	Action    string `json:"action" db:"action" example:"link.update"`
+	Snapshot  string `json:"-" db:"snapshot"` // NodeSnapshot as json
+	CreatedAt int64  `json:"created_at,string" db:"created_at"`
+}
+
+const (
+	RevisionBaseline        = "baseline" // state before the first recorded change
+	RevisionNodeCreate      = "node.create"
+	RevisionNodeUpdate      = "node.update"
+	RevisionNodeRestore     = "node.restore"
+	RevisionDraftDiscard    = "draft.discard" // draft put back to the published state
+	RevisionLinkCreate      = "link.create"
+	RevisionLinkUpdate      = "link.update"
+	RevisionLinkDelete      = "link.delete"
+	RevisionLinkRename      = "link.rename"
+	RevisionLinkReorder     = "link.reorder"
+	RevisionLinkImport      = "link.import"
+	RevisionColorStopCreate = "color_stop.create"
+	RevisionColorStopUpdate = "color_stop.update"
+	RevisionColorStopDelete = "color_stop.delete"
+)
+
+// NodeSnapshot is the page content a revision restores. the subdomain, custom domain,
+// owner and collaborators are managed separately and aren't part of it.
+type NodeSnapshot struct {
+	DisplayName         string `json:"display_name"`
+	Description         string `json:"description"`
+	BackgroundColor     string `json:"background_color"`
+	TitleFontColor      string `json:"title_font_color"`
+	CaptionFontColor    string `json:"caption_font_color"`
+	AccentColor         string `json:"accent_color"`
+	ThemeColor          string `json:"theme_color"`
+	ShowShareButton     bool   `json:"show_share_button"`
+	Theme               string `json:"theme"`
+	MouseEffectsEnabled bool   `json:"mouse_effects_enabled"`
+	TextShadowsEnabled  bool   `json:"text_shadows_enabled"`
+	HidePoweredBy       bool   `json:"hide_powered_by"`
+	PageTitle           string `json:"page_title"`
+	Links               []Link `json:"links"` // with their color stops, in order
+}
+
+func NewNodeSnapshot(node *Node, links []Link) NodeSnapshot {
+	if links == nil {
+		links = []Link{}
+	}
+
+	return NodeSnapshot{
+		DisplayName:         node.DisplayName,
+		Description:         node.Description,
+		BackgroundColor:     node.BackgroundColor,
+		TitleFontColor:      node.TitleFontColor,
+		CaptionFontColor:    node.CaptionFontColor,
+		AccentColor:         node.AccentColor,
+		ThemeColor:          node.ThemeColor,
+		ShowShareButton:     node.ShowShareButton,
+		Theme:               node.Theme,
+		MouseEffectsEnabled: node.MouseEffectsEnabled,
+		TextShadowsEnabled:  node.TextShadowsEnabled,
+		HidePoweredBy:       node.HidePoweredBy,
+		PageTitle:           node.PageTitle,
+		Links:               links,
+	}
+}
+
+// Apply copies the snapshot's page settings onto the node
+func (s *NodeSnapshot) Apply(node *Node) {
+	node.DisplayName = s.DisplayName
+	node.Description = s.Description
+	node.BackgroundColor = s.BackgroundColor
+	node.TitleFontColor = s.TitleFontColor
+	node.CaptionFontColor = s.CaptionFontColor
+	node.AccentColor = s.AccentColor
+	node.ThemeColor = s.ThemeColor
+	node.ShowShareButton = s.ShowShareButton
+	node.Theme = s.Theme
+	node.MouseEffectsEnabled = s.MouseEffectsEnabled
+	node.TextShadowsEnabled = s.TextShadowsEnabled
+	node.HidePoweredBy = s.HidePoweredBy
+	node.PageTitle = s.PageTitle
+}
@@ -0,0 +1,12 @@
+package model
+
+type Session struct {
+	ID         int64  `json:"id,string" db:"id"`
+	UserID     int64  `json:"-" db:"user_id"`
+	CreatedAt  int64  `json:"created_at,string" db:"created_at"`
+	LastSeenAt int64  `json:"last_seen_at,string" db:"last_seen_at"`
+	IPAddress  string `json:"ip_address" db:"ip_address"`
+	UserAgent  string `json:"user_agent" db:"user_agent"`
+	Label      string `json:"label" db:"label"`
+	RefreshJti string `json:"-" db:"refresh_jti"`
+}
@@ -0,0 +1,13 @@
+package model
+
+// RetiredSubdomain is a subdomain a node used before. it redirects to the node's current
+// subdomain until another node takes it or the owner releases it, and other nodes can't
+// take it before HeldUntil.
+type RetiredSubdomain struct {
+	ID            int64  `json:"id,string" db:"id"`
+	NodeID        int64  `json:"node_id,string" db:"node_id"`
+	SubdomainName string `json:"subdomain_name" db:"subdomain_name" example:"johndoe"`
+	RetiredAt     int64  `json:"retired_at,string" db:"retired_at"`
+	HeldUntil     int64  `json:"held_until,string" db:"held_until"`
+	ReleasedAt    int64  `json:"released_at,string" db:"released_at"` // 0 until released
+}
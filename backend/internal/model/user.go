@@ -1,17 +1,50 @@
 package model
 
+const (
+	RoleUser  = "user"
+	RoleAdmin = "admin"
+)
+
+// account statuses. disabled and banned accounts can't sign in or use existing
+// sessions, banning also takes down every node the user owns.
+const (
+	StatusActive   = "active"
+	StatusDisabled = "disabled"
+	StatusBanned   = "banned"
+)
+
 // @Description User model with profile information
 type User struct {
-	ID                    int64  `db:"id" safe:"true" json:"id,string" example:"123456789"`
-	Username              string `db:"username" safe:"true" json:"username" example:"johndoe"`
-	Email                 string `db:"email" safe:"true" json:"email" example:"john@example.com"`
-	PasswordHash          string `db:"password_hash" json:"-"`
-	CreatedAt             int64  `db:"created_at" safe:"true" json:"created_at,string" example:"1640995200"`
-	Role                  string `db:"user_role" safe:"true" json:"role" example:"user"`
-	EmailConfirmed        bool   `db:"email_confirmed" json:"-"`
-	EmailConfirmToken     string `db:"email_confirm_token" json:"-"`
-	EmailConfirmIssuedAt  int64  `db:"email_confirm_issuedat" json:"-"`
-	PasswordResetToken    string `db:"password_reset_token" json:"-"`
-	PasswordResetIssuedAt int64  `db:"password_reset_issuedat" json:"-"`
-	JwtSessionID          int64  `db:"jwt_session_id" json:"-"`
+	ID                     int64  `db:"id" safe:"true" json:"id,string" example:"123456789"`
+	Username               string `db:"username" safe:"true" json:"username" example:"johndoe"`
+	Email                  string `db:"email" safe:"true" json:"email" example:"john@example.com"`
+	PasswordHash           string `db:"password_hash" json:"-"`
+	CreatedAt              int64  `db:"created_at" safe:"true" json:"created_at,string" example:"1640995200"`
+	Role                   string `db:"user_role" safe:"true" json:"role" example:"user"`
+	EmailConfirmed         bool   `db:"email_confirmed" json:"-"`
+	EmailConfirmToken      string `db:"email_confirm_token" json:"-"`
+	EmailConfirmIssuedAt   int64  `db:"email_confirm_issuedat" json:"-"`
+	PasswordResetToken     string `db:"password_reset_token" json:"-"`
+	PasswordResetIssuedAt  int64  `db:"password_reset_issuedat" json:"-"`
+	JwtSessionID           int64  `db:"jwt_session_id" json:"-"`
+	TotpSecret             string `db:"totp_secret" json:"-"`
+	TotpEnabled            bool   `db:"totp_enabled" safe:"true" json:"totp_enabled"`
+	TotpLastStep           int64  `db:"totp_last_step" json:"-"`
+	MagicLinkToken         string `db:"magic_link_token" json:"-"`
+	MagicLinkIssuedAt      int64  `db:"magic_link_issuedat" json:"-"`
+	DeletionScheduledAt    int64  `db:"deletion_scheduled_at" safe:"true" json:"deletion_scheduled_at,string"`
+	PendingEmail           string `db:"pending_email" safe:"true" json:"pending_email" example:"new@example.com"`
+	EmailChangeToken       string `db:"email_change_token" json:"-"`
+	EmailChangeCancelToken string `db:"email_change_cancel_token" json:"-"`
+	EmailChangeIssuedAt    int64  `db:"email_change_issuedat" json:"-"`
+	Status                 string `db:"account_status" safe:"true" json:"status" example:"active"`
+	StatusReason           string `db:"status_reason" json:"-"`
+}
+
+func (u *User) IsActive() bool {
+	return u.Status == "" || u.Status == StatusActive
+}
+
+func IsValidStatus(status string) bool {
+	return status == StatusActive || status == StatusDisabled || status == StatusBanned
 }
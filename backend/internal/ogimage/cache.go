@@ -0,0 +1,51 @@
+package ogimage
+
+import "sync"
+
+// rendered cards kept in memory, roughly 50KB each
+const maxCached = 256
+
+type cached struct {
+	key string
+	png []byte
+}
+
+var (
+	cacheMu sync.Mutex
+	cache   = map[int64]cached{}
+)
+
+// Cached returns the image rendered for the node, if it was rendered from a card with
+// the same key. the key check keeps instances that missed an invalidation correct.
+func Cached(nodeID int64, key string) ([]byte, bool) {
+	cacheMu.Lock()
+	defer cacheMu.Unlock()
+
+	entry, ok := cache[nodeID]
+	if !ok || entry.key != key {
+		return nil, false
+	}
+	return entry.png, true
+}
+
+// Store keeps the image rendered for the node, evicting an arbitrary entry when full
+func Store(nodeID int64, key string, png []byte) {
+	cacheMu.Lock()
+	defer cacheMu.Unlock()
+
+	if _, ok := cache[nodeID]; !ok && len(cache) >= maxCached {
+		for id := range cache {
+			delete(cache, id)
+			break
+		}
+	}
+	cache[nodeID] = cached{key: key, png: png}
+}
+
+// Invalidate drops the node's image, called whenever the node or its links change
+func Invalidate(nodeID int64) {
+	cacheMu.Lock()
+	defer cacheMu.Unlock()
+
+	delete(cache, nodeID)
+}
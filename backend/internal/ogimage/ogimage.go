@@ -0,0 +1,264 @@
+// Package ogimage draws the preview card shown when a node's page is shared on social
+// media, a 1200x630 PNG rasterized in pure Go with the embedded Go fonts.
+package ogimage
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"strconv"
+	"strings"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/gofont/gobold"
+	"golang.org/x/image/font/gofont/goregular"
+	"golang.org/x/image/font/opentype"
+	"golang.org/x/image/math/fixed"
+	"golang.org/x/image/vector"
+)
+
+const (
+	Width  = 1200
+	Height = 630
+
+	padding   = 80
+	barWidth  = 24
+	maxLinks  = 3
+	linkH     = 60
+	linkGap   = 16
+	linkTextY = 39 // baseline inside a link pill
+)
+
+// fallbacks match the public page
+var (
+	defaultBackground = color.RGBA{0xF5, 0xF1, 0xE8, 0xFF}
+	defaultAccent     = color.RGBA{0x8B, 0x9A, 0x47, 0xFF}
+)
+
+var regularFont, boldFont *opentype.Font
+
+func init() {
+	var err error
+	if regularFont, err = opentype.Parse(goregular.TTF); err != nil {
+		panic("failed to parse embedded font: " + err.Error())
+	}
+	if boldFont, err = opentype.Parse(gobold.TTF); err != nil {
+		panic("failed to parse embedded font: " + err.Error())
+	}
+}
+
+// Card is what the preview shows
+type Card struct {
+	DisplayName string
+	Description string
+	Links       []string // display names of the links, only the first few are drawn
+	Background  string   // hex color, the default when invalid
+	Accent      string   // hex color, the default when invalid
+	Footer      string   // usually the page address
+}
+
+// Key identifies the rendered image, cards with the same key draw the same image
+func (c Card) Key() string {
+	shown := c.Links[:min(len(c.Links), maxLinks)]
+	h := sha256.New()
+	fmt.Fprintf(h, "%q %q %q %d %q %q %q", c.DisplayName, c.Description, shown, len(c.Links), c.Background, c.Accent, c.Footer)
+	return hex.EncodeToString(h.Sum(nil)[:16])
+}
+
+// Render draws the card and encodes it as PNG
+func Render(c Card) ([]byte, error) {
+	faces, err := newFaces()
+	if err != nil {
+		return nil, err
+	}
+	defer faces.close()
+
+	bg := parseHex(c.Background, defaultBackground)
+	accent := parseHex(c.Accent, defaultAccent)
+	text := contrast(bg)
+	muted := color.NRGBA{text.R, text.G, text.B, 0xB0}
+
+	img := image.NewRGBA(image.Rect(0, 0, Width, Height))
+	draw.Draw(img, img.Bounds(), image.NewUniform(bg), image.Point{}, draw.Src)
+	fillRect(img, image.Rect(0, 0, barWidth, Height), accent)
+
+	x := padding
+	width := Width - 2*padding
+	y := padding + 64
+
+	drawText(img, faces.title, text, x, y, truncate(faces.title, c.DisplayName, width))
+	y += 24
+
+	for _, line := range wrap(faces.body, c.Description, width, 2) {
+		y += 44
+		drawText(img, faces.body, muted, x, y, line)
+	}
+	y += 40
+
+	linkText := contrast(accent)
+	for _, name := range c.Links[:min(len(c.Links), maxLinks)] {
+		pillWidth := min(width, font.MeasureString(faces.link, name).Ceil()+64)
+		roundedRect(img, image.Rect(x, y, x+pillWidth, y+linkH), linkH/2, accent)
+		drawText(img, faces.link, linkText, x+32, y+linkTextY, truncate(faces.link, name, pillWidth-64))
+		y += linkH + linkGap
+	}
+
+	if more := len(c.Links) - maxLinks; more > 0 {
+		drawText(img, faces.small, muted, x, y+28, fmt.Sprintf("+%d more", more))
+	}
+
+	if c.Footer != "" {
+		footer := truncate(faces.small, c.Footer, width)
+		footerX := Width - padding - font.MeasureString(faces.small, footer).Ceil()
+		drawText(img, faces.small, accent, footerX, Height-padding/2-8, footer)
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// faces hold glyph caches and aren't safe for concurrent use, every render gets its own
+type faces struct {
+	title, body, link, small font.Face
+}
+
+func newFaces() (*faces, error) {
+	newFace := func(f *opentype.Font, size float64) (font.Face, error) {
+		return opentype.NewFace(f, &opentype.FaceOptions{Size: size, DPI: 72, Hinting: font.HintingFull})
+	}
+
+	var fs faces
+	var err error
+	if fs.title, err = newFace(boldFont, 64); err != nil {
+		return nil, err
+	}
+	if fs.body, err = newFace(regularFont, 32); err != nil {
+		return nil, err
+	}
+	if fs.link, err = newFace(boldFont, 26); err != nil {
+		return nil, err
+	}
+	if fs.small, err = newFace(regularFont, 24); err != nil {
+		return nil, err
+	}
+	return &fs, nil
+}
+
+func (fs *faces) close() {
+	fs.title.Close()
+	fs.body.Close()
+	fs.link.Close()
+	fs.small.Close()
+}
+
+func drawText(dst draw.Image, face font.Face, c color.Color, x, y int, s string) {
+	d := font.Drawer{Dst: dst, Src: image.NewUniform(c), Face: face, Dot: fixed.P(x, y)}
+	d.DrawString(s)
+}
+
+// truncate shortens s with an ellipsis until it fits in width pixels
+func truncate(face font.Face, s string, width int) string {
+	s = strings.Join(strings.Fields(s), " ")
+	if font.MeasureString(face, s).Ceil() <= width {
+		return s
+	}
+
+	runes := []rune(s)
+	for len(runes) > 0 {
+		runes = runes[:len(runes)-1]
+		candidate := strings.TrimRight(string(runes), " ") + "…"
+		if font.MeasureString(face, candidate).Ceil() <= width {
+			return candidate
+		}
+	}
+	return ""
+}
+
+// wrap breaks s into at most maxLines lines of width pixels, the last one ellipsized
+func wrap(face font.Face, s string, width, maxLines int) []string {
+	var lines []string
+	line := ""
+	words := strings.Fields(s)
+	for i, word := range words {
+		candidate := strings.TrimSpace(line + " " + word)
+		if font.MeasureString(face, candidate).Ceil() <= width || line == "" {
+			line = candidate
+			continue
+		}
+
+		if len(lines) == maxLines-1 {
+			return append(lines, truncate(face, strings.Join(append([]string{line}, words[i:]...), " "), width))
+		}
+		lines = append(lines, truncate(face, line, width))
+		line = word
+	}
+
+	if line != "" {
+		lines = append(lines, truncate(face, line, width))
+	}
+	return lines
+}
+
+func fillRect(dst draw.Image, r image.Rectangle, c color.Color) {
+	draw.Draw(dst, r, image.NewUniform(c), image.Point{}, draw.Over)
+}
+
+// roundedRect fills r with corners of the given radius, anti-aliased
+func roundedRect(dst draw.Image, r image.Rectangle, radius int, c color.Color) {
+	// control point distance for a quarter circle drawn with a cubic curve
+	const k = 0.5523
+
+	x0, y0 := float32(r.Min.X), float32(r.Min.Y)
+	x1, y1 := float32(r.Max.X), float32(r.Max.Y)
+	rad := float32(min(radius, r.Dx()/2, r.Dy()/2))
+	off := rad * (1 - k)
+
+	z := vector.NewRasterizer(Width, Height)
+	z.MoveTo(x0+rad, y0)
+	z.LineTo(x1-rad, y0)
+	z.CubeTo(x1-off, y0, x1, y0+off, x1, y0+rad)
+	z.LineTo(x1, y1-rad)
+	z.CubeTo(x1, y1-off, x1-off, y1, x1-rad, y1)
+	z.LineTo(x0+rad, y1)
+	z.CubeTo(x0+off, y1, x0, y1-off, x0, y1-rad)
+	z.LineTo(x0, y0+rad)
+	z.CubeTo(x0, y0+off, x0+off, y0, x0+rad, y0)
+	z.ClosePath()
+	z.Draw(dst, dst.Bounds(), image.NewUniform(c), image.Point{})
+}
+
+// parseHex reads #rgb and #rrggbb colors, alpha is ignored since the card is opaque
+func parseHex(s string, fallback color.RGBA) color.RGBA {
+	s = strings.TrimPrefix(strings.TrimSpace(s), "#")
+	switch len(s) {
+	case 3, 4:
+		s = string([]byte{s[0], s[0], s[1], s[1], s[2], s[2]})
+	case 6, 8:
+		s = s[:6]
+	default:
+		return fallback
+	}
+
+	v, err := strconv.ParseUint(s, 16, 32)
+	if err != nil {
+		return fallback
+	}
+	return color.RGBA{uint8(v >> 16), uint8(v >> 8), uint8(v), 0xFF}
+}
+
+// contrast picks near black or white text for the background, by relative luminance
+func contrast(bg color.RGBA) color.RGBA {
+	luminance := 0.2126*float64(bg.R) + 0.7152*float64(bg.G) + 0.0722*float64(bg.B)
+	if luminance > 150 {
+		return color.RGBA{0x1F, 0x1F, 0x1F, 0xFF}
+	}
+	return color.RGBA{0xFF, 0xFF, 0xFF, 0xFF}
+}
@@ -0,0 +1,15 @@
+package oidc
+
+// ProviderConfig describes one OpenID Connect provider. endpoints are read from the
+// issuer's discovery document, so only the issuer and client credentials are needed.
+type ProviderConfig struct {
+	DisplayName  string   `json:"display_name"`
+	Issuer       string   `json:"issuer"`
+	ClientID     string   `json:"client_id"`
+	ClientSecret string   `json:"client_secret"`
+	RedirectURL  string   `json:"redirect_url"` // must point at /auth/oidc/{provider}/callback
+	Scopes       []string `json:"scopes"`
+	// AllowInsecure accepts plain http for a loopback issuer, for cmd/oidcstub. without
+	// it the issuer and every discovered endpoint must be https
+	AllowInsecure bool `json:"allow_insecure"`
+}
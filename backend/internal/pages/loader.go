@@ -0,0 +1,47 @@
+package pages
+
+import (
+	"embed"
+	"html/template"
+	"os"
+	"sync"
+)
+
+var (
+	tmplMu    sync.Mutex
+	tmplCache = map[string]*template.Template{}
+)
+
+//go:embed templates/*.html
+var embedded embed.FS
+
+// GetTemplate loads a page template, preferring templates/pages/<name>.html in the
+// working directory over the embedded one. templates are cached after the first load.
+func GetTemplate(name string) (*template.Template, error) {
+	tmplMu.Lock()
+	defer tmplMu.Unlock()
+
+	if tmpl, ok := tmplCache[name]; ok {
+		return tmpl, nil
+	}
+
+	tmpl, err := getTemplate(name + ".html")
+	if err != nil {
+		return nil, err
+	}
+
+	tmplCache[name] = tmpl
+	return tmpl, nil
+}
+
+func getTemplate(file string) (*template.Template, error) {
+	if path := "templates/pages/" + file; fileExists(path) {
+		return template.ParseFiles(path)
+	}
+	return template.ParseFS(embedded, "templates/"+file)
+}
+
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
@@ -0,0 +1,220 @@
+// Package pages renders a node's public link page as plain HTML, so the page works
+// without javascript and crawlers and link previews see its content and meta tags.
+package pages
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/akramboussanni/treenode/config"
+	"github.com/akramboussanni/treenode/internal/model"
+	"github.com/akramboussanni/treenode/internal/utils"
+)
+
+// fallbacks match the frontend's renderer
+const (
+	defaultBackgroundColor = "#F5F1E8"
+	defaultTitleColor      = "#8B7355"
+	defaultCaptionColor    = "#666666"
+	defaultAccentColor     = "#8B9A47"
+)
+
+const siteName = "Treenode"
+
+// NodePage is the data passed to the node template
+type NodePage struct {
+	Title       string // page title, the node's page title or display name
+	DisplayName string
+	Description string
+	Url         string // canonical url of the page, empty when unknown
+	ImageUrl    string // preview image for link previews, empty when unknown
+	ThemeColor  string
+	SiteName    string
+
+	Colors      template.CSS // custom properties for the page colors, for :root
+	TextShadows bool
+	ShowPowered bool
+	Links       []PageLink // regular links, in order
+	MiniLinks   []PageLink // mini links shown under the description
+}
+
+// PageLink is a link as the template draws it
+type PageLink struct {
+	Name        string
+	DisplayName string
+	Description string
+	Url         string
+	Style       template.CSS // background and color custom properties, validated
+}
+
+// cssColor returns the color if it is a plain hex, rgb(a), hsl(a) or named color, the
+// fallback otherwise. colors are user input and end up in css, nothing else may pass.
+func cssColor(color, fallback string) string {
+	color = strings.TrimSpace(color)
+	if utils.IsValidColor(color) {
+		return color
+	}
+	return fallback
+}
+
+// gradient builds the link background from its color stops, like the frontend. empty
+// when the link has no usable gradient.
+func gradient(link model.Link) string {
+	var first string
+	var stops []string
+	for _, stop := range link.ColorStops {
+		color := cssColor(stop.Color, "")
+		if color == "" {
+			continue
+		}
+		if first == "" {
+			first = color
+		}
+		stops = append(stops, color+" "+formatNumber(stop.Position)+"%")
+	}
+
+	if len(stops) == 0 {
+		return ""
+	}
+
+	switch link.GradientType {
+	case "solid":
+		return first
+	case "linear":
+		return fmt.Sprintf("linear-gradient(%sdeg, %s)", formatNumber(link.GradientAngle), strings.Join(stops, ", "))
+	case "radial":
+		return "radial-gradient(circle, " + strings.Join(stops, ", ") + ")"
+	}
+	return ""
+}
+
+// linkUrl returns the link target if it is a web, mail or phone link, empty otherwise.
+// anything else is shown without a link, like a link with no target.
+func linkUrl(raw string) string {
+	raw = strings.TrimSpace(raw)
+	u, err := url.Parse(raw)
+	if err != nil {
+		return ""
+	}
+
+	switch strings.ToLower(u.Scheme) {
+	case "http", "https", "mailto", "tel":
+		return raw
+	}
+	return ""
+}
+
+func formatNumber(f float64) string {
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
+
+// linkStyle resolves the link colors against the page ones. mini links only get their
+// gradient when the mini background is enabled.
+func linkStyle(link model.Link, accent, title, caption string) template.CSS {
+	if link.CustomAccentColorEnabled {
+		accent = cssColor(link.CustomAccentColor, accent)
+	}
+	if link.CustomTitleColorEnabled {
+		title = cssColor(link.CustomTitleColor, title)
+	}
+	if link.CustomDescriptionColorEnabled {
+		caption = cssColor(link.CustomDescriptionColor, caption)
+	}
+
+	style := fmt.Sprintf("--accent: %s; --title: %s; --caption: %s;", accent, title, caption)
+	if bg := gradient(link); bg != "" && (!link.Mini || link.MiniBackgroundEnabled) {
+		style += " --background: " + bg + ";"
+	}
+
+	// every value was validated above
+	return template.CSS(style)
+}
+
+// NodeUrl is the public address of the node's page, its verified custom domain or its
+// subdomain on the platform host. empty when neither is known.
+func NodeUrl(node *model.Node) string {
+	switch {
+	case node.DomainVerified && node.Domain != "":
+		return "https://" + node.Domain + "/"
+	case config.App.PlatformHost != "" && node.SubdomainName != "":
+		return "https://" + strings.ToLower(node.SubdomainName) + "." + config.App.PlatformHost + "/"
+	}
+	return ""
+}
+
+// ApiUrl is the public address of an API path, based on PUBLIC_API_URL. empty when unset,
+// the request's host can't be trusted to build it.
+func ApiUrl(path string) string {
+	if config.App.PublicApiUrl == "" {
+		return ""
+	}
+	return config.App.PublicApiUrl + path
+}
+
+// NewNodePage prepares the template data for a node and its visible links
+func NewNodePage(node *model.Node, links []model.Link, pageUrl, imageUrl string) NodePage {
+	background := cssColor(node.BackgroundColor, defaultBackgroundColor)
+	title := cssColor(node.TitleFontColor, defaultTitleColor)
+	caption := cssColor(node.CaptionFontColor, defaultCaptionColor)
+	accent := cssColor(node.AccentColor, defaultAccentColor)
+
+	page := NodePage{
+		Title:       node.PageTitle,
+		DisplayName: node.DisplayName,
+		Description: node.Description,
+		Url:         pageUrl,
+		ImageUrl:    imageUrl,
+		ThemeColor:  cssColor(node.ThemeColor, background),
+		SiteName:    siteName,
+		Colors: template.CSS(fmt.Sprintf("--page-background: %s; --page-title: %s; --page-caption: %s; --page-accent: %s;",
+			background, title, caption, accent)),
+		TextShadows: node.TextShadowsEnabled,
+		ShowPowered: !node.HidePoweredBy,
+	}
+
+	if page.Title == "" {
+		page.Title = node.DisplayName
+	}
+
+	for _, link := range links {
+		pl := PageLink{
+			Name:        link.Name,
+			DisplayName: link.DisplayName,
+			Description: link.Description,
+			Url:         linkUrl(link.Link),
+			Style:       linkStyle(link, accent, title, caption),
+		}
+
+		if link.Mini {
+			page.MiniLinks = append(page.MiniLinks, pl)
+			continue
+		}
+
+		// the frontend shows the target when there is no description
+		if pl.Description == "" {
+			pl.Description = pl.Url
+		}
+		page.Links = append(page.Links, pl)
+	}
+
+	return page
+}
+
+// RenderNode renders the node page template. the result is only written out once it
+// rendered fully, so a broken override can't send half a page.
+func RenderNode(node *model.Node, links []model.Link, pageUrl, imageUrl string) ([]byte, error) {
+	tmpl, err := GetTemplate("node")
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, NewNodePage(node, links, pageUrl, imageUrl)); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,60 @@
+package repo
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/akramboussanni/treenode/internal/model"
+	"github.com/jmoiron/sqlx"
+)
+
+type ApiTokenRepo struct {
+	Columns
+	db *sqlx.DB
+}
+
+func NewApiTokenRepo(db *sqlx.DB) *ApiTokenRepo {
+	repo := &ApiTokenRepo{db: db}
+	repo.Columns = ExtractColumns[model.ApiToken]()
+	return repo
+}
+
+func (r *ApiTokenRepo) CreateApiToken(ctx context.Context, token *model.ApiToken) error {
+	query := fmt.Sprintf(
+		"INSERT INTO api_tokens (%s) VALUES (%s)",
+		r.AllRaw,
+		r.AllPrefixed,
+	)
+	_, err := r.db.NamedExecContext(ctx, query, token)
+	return err
+}
+
+func (r *ApiTokenRepo) GetApiTokenByHash(ctx context.Context, hash string) (*model.ApiToken, error) {
+	var token model.ApiToken
+	query := fmt.Sprintf("SELECT %s FROM api_tokens WHERE token_hash = $1", r.AllRaw)
+	err := r.db.GetContext(ctx, &token, query, hash)
+	return &token, err
+}
+
+func (r *ApiTokenRepo) GetApiTokensByUserID(ctx context.Context, userID int64) ([]model.ApiToken, error) {
+	var tokens []model.ApiToken
+	query := fmt.Sprintf("SELECT %s FROM api_tokens WHERE user_id = $1 ORDER BY created_at DESC", r.AllRaw)
+	err := r.db.SelectContext(ctx, &tokens, query, userID)
+	return tokens, err
+}
+
+func (r *ApiTokenRepo) TouchApiToken(ctx context.Context, id int64, lastUsedAt int64) error {
+	_, err := r.db.ExecContext(ctx, `UPDATE api_tokens SET last_used_at = $1 WHERE id = $2`, lastUsedAt, id)
+	return err
+}
+
+// DeleteApiToken removes a token, scoped to its owner so users can't revoke each other
+func (r *ApiTokenRepo) DeleteApiToken(ctx context.Context, id int64, userID int64) (bool, error) {
+	res, err := r.db.ExecContext(ctx, `DELETE FROM api_tokens WHERE id = $1 AND user_id = $2`, id, userID)
+	if err != nil {
+		return false, err
+	}
+
+	affected, err := res.RowsAffected()
+	return affected == 1, err
+}
@@ -0,0 +1,59 @@
+package repo
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/akramboussanni/treenode/internal/model"
+	"github.com/jmoiron/sqlx"
+)
+
+type AuditRepo struct {
+	Columns
+	db *sqlx.DB
+}
+
+func NewAuditRepo(db *sqlx.DB) *AuditRepo {
+	repo := &AuditRepo{db: db}
+	repo.Columns = ExtractColumns[model.AuditEntry]()
+	return repo
+}
+
+// Record runs action and writes its entry in one transaction, so the log has every
+// action that happened and none that failed
+func (r *AuditRepo) Record(ctx context.Context, entry *model.AuditEntry, action func(tx *sqlx.Tx) error) error {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := action(tx); err != nil {
+		return err
+	}
+
+	query := fmt.Sprintf(
+		"INSERT INTO audit_log (%s) VALUES (%s)",
+		r.AllRaw,
+		r.AllPrefixed,
+	)
+	if _, err := tx.NamedExecContext(ctx, query, entry); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// GetEntries lists the log newest first. a targetType of "" returns every entry.
+func (r *AuditRepo) GetEntries(ctx context.Context, targetType string, targetID int64, limit, offset int) ([]model.AuditEntry, error) {
+	var entries []model.AuditEntry
+	if targetType == "" {
+		query := fmt.Sprintf("SELECT %s FROM audit_log ORDER BY created_at DESC, id DESC LIMIT $1 OFFSET $2", r.AllRaw)
+		err := r.db.SelectContext(ctx, &entries, query, limit, offset)
+		return entries, err
+	}
+
+	query := fmt.Sprintf("SELECT %s FROM audit_log WHERE target_type = $1 AND target_id = $2 ORDER BY created_at DESC, id DESC LIMIT $3 OFFSET $4", r.AllRaw)
+	err := r.db.SelectContext(ctx, &entries, query, targetType, targetID, limit, offset)
+	return entries, err
+}
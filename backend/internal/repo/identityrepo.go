@@ -0,0 +1,140 @@
+package repo
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/akramboussanni/treenode/internal/model"
+	"github.com/jmoiron/sqlx"
+)
+
+type IdentityRepo struct {
+	identityColumns Columns
+	stateColumns    Columns
+	userColumns     Columns
+	db              *sqlx.DB
+}
+
+func NewIdentityRepo(db *sqlx.DB) *IdentityRepo {
+	repo := &IdentityRepo{db: db}
+	repo.identityColumns = ExtractColumns[model.Identity]()
+	repo.stateColumns = ExtractColumns[model.OidcState]()
+	repo.userColumns = ExtractColumns[model.User]()
+	return repo
+}
+
+func (r *IdentityRepo) CreateIdentity(ctx context.Context, identity *model.Identity) error {
+	query := fmt.Sprintf(
+		"INSERT INTO user_identities (%s) VALUES (%s)",
+		r.identityColumns.AllRaw,
+		r.identityColumns.AllPrefixed,
+	)
+	_, err := r.db.NamedExecContext(ctx, query, identity)
+	return err
+}
+
+// CreateUserWithIdentity registers a user that signed up through a provider, so a
+// failed identity insert never leaves behind an account nobody can log into
+func (r *IdentityRepo) CreateUserWithIdentity(ctx context.Context, user *model.User, identity *model.Identity) error {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	query := fmt.Sprintf(
+		"INSERT INTO users (%s) VALUES (%s)",
+		r.userColumns.AllRaw,
+		r.userColumns.AllPrefixed,
+	)
+	if _, err := tx.NamedExecContext(ctx, query, user); err != nil {
+		return err
+	}
+
+	query = fmt.Sprintf(
+		"INSERT INTO user_identities (%s) VALUES (%s)",
+		r.identityColumns.AllRaw,
+		r.identityColumns.AllPrefixed,
+	)
+	if _, err := tx.NamedExecContext(ctx, query, identity); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (r *IdentityRepo) GetIdentity(ctx context.Context, provider, subject string) (*model.Identity, error) {
+	var identity model.Identity
+	query := fmt.Sprintf("SELECT %s FROM user_identities WHERE provider = $1 AND subject = $2", r.identityColumns.AllRaw)
+	err := r.db.GetContext(ctx, &identity, query, provider, subject)
+	return &identity, err
+}
+
+func (r *IdentityRepo) GetIdentitiesByUserID(ctx context.Context, userID int64) ([]model.Identity, error) {
+	var identities []model.Identity
+	query := fmt.Sprintf("SELECT %s FROM user_identities WHERE user_id = $1 ORDER BY created_at", r.identityColumns.AllRaw)
+	err := r.db.SelectContext(ctx, &identities, query, userID)
+	return identities, err
+}
+
+func (r *IdentityRepo) CountIdentities(ctx context.Context, userID int64) (int, error) {
+	var count int
+	err := r.db.GetContext(ctx, &count, `SELECT COUNT(*) FROM user_identities WHERE user_id = $1`, userID)
+	return count, err
+}
+
+// DeleteIdentity unlinks an identity, scoped to its owner so users can't unlink each other
+func (r *IdentityRepo) DeleteIdentity(ctx context.Context, id int64, userID int64) (bool, error) {
+	res, err := r.db.ExecContext(ctx, `DELETE FROM user_identities WHERE id = $1 AND user_id = $2`, id, userID)
+	if err != nil {
+		return false, err
+	}
+
+	affected, err := res.RowsAffected()
+	return affected == 1, err
+}
+
+// CreateState stores a pending authorization request, clearing out abandoned ones
+func (r *IdentityRepo) CreateState(ctx context.Context, state *model.OidcState, now int64) error {
+	if _, err := r.db.ExecContext(ctx, `DELETE FROM oidc_states WHERE expires_at < $1`, now); err != nil {
+		return err
+	}
+
+	query := fmt.Sprintf(
+		"INSERT INTO oidc_states (%s) VALUES (%s)",
+		r.stateColumns.AllRaw,
+		r.stateColumns.AllPrefixed,
+	)
+	_, err := r.db.NamedExecContext(ctx, query, state)
+	return err
+}
+
+// DeleteExpiredStates deletes authorization requests that were never completed
+func (r *IdentityRepo) DeleteExpiredStates(ctx context.Context, now int64) (int64, error) {
+	res, err := r.db.ExecContext(ctx, `DELETE FROM oidc_states WHERE expires_at < $1`, now)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// ConsumeState loads and deletes a pending request, so each state is only usable once
+func (r *IdentityRepo) ConsumeState(ctx context.Context, stateHash string) (*model.OidcState, error) {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var state model.OidcState
+	query := fmt.Sprintf("SELECT %s FROM oidc_states WHERE state_hash = $1", r.stateColumns.AllRaw)
+	if err := tx.GetContext(ctx, &state, query, stateHash); err != nil {
+		return nil, err
+	}
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM oidc_states WHERE state_hash = $1`, stateHash); err != nil {
+		return nil, err
+	}
+
+	return &state, tx.Commit()
+}
@@ -44,6 +44,14 @@ func (ir *InvitationRepo) GetInvitationsByNodeID(ctx context.Context, nodeID int
 	return invitations, err
 }
 
+// GetInvitationsForUser returns invitations addressed to the user, by id or by email
+func (ir *InvitationRepo) GetInvitationsForUser(ctx context.Context, userID int64, email string) ([]*model.Invitation, error) {
+	var invitations []*model.Invitation
+	query := fmt.Sprintf("SELECT %s FROM invitations WHERE user_id = $1 OR email = $2 ORDER BY created_at DESC", ir.AllRaw)
+	err := ir.db.SelectContext(ctx, &invitations, query, userID, email)
+	return invitations, err
+}
+
 func (ir *InvitationRepo) UpdateInvitation(ctx context.Context, invitation *model.Invitation) error {
 	query := `
 		UPDATE invitations
@@ -60,14 +68,17 @@ func (ir *InvitationRepo) UpdateInvitation(ctx context.Context, invitation *mode
 	return err
 }
 
-func (ir *InvitationRepo) DeleteExpiredInvitations(ctx context.Context) error {
+func (ir *InvitationRepo) DeleteExpiredInvitations(ctx context.Context) (int64, error) {
 	query := `
 		DELETE FROM invitations
 		WHERE expires_at < $1
 	`
 
-	_, err := ir.db.ExecContext(ctx, query, time.Now().UTC().Unix())
-	return err
+	res, err := ir.db.ExecContext(ctx, query, time.Now().UTC().Unix())
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
 }
 
 func (ir *InvitationRepo) CheckExistingInvitation(ctx context.Context, nodeID int64, userID int64) (bool, error) {
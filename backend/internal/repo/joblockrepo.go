@@ -0,0 +1,54 @@
+package repo
+
+import (
+	"context"
+
+	"github.com/jmoiron/sqlx"
+)
+
+type JobLockRepo struct {
+	db *sqlx.DB
+}
+
+func NewJobLockRepo(db *sqlx.DB) *JobLockRepo {
+	return &JobLockRepo{db: db}
+}
+
+// AcquireJobLock takes the lease on a job until leaseUntil, unless another holder has it
+// or the job last finished after dueAfter, which means another instance already ran it
+// this interval
+func (r *JobLockRepo) AcquireJobLock(ctx context.Context, name, holder string, now, leaseUntil, dueAfter int64) (bool, error) {
+	_, err := r.db.ExecContext(ctx, `
+		INSERT INTO job_locks (name, holder, locked_until, last_run_at)
+		VALUES ($1, '', 0, 0)
+		ON CONFLICT(name) DO NOTHING
+	`, name)
+	if err != nil {
+		return false, err
+	}
+
+	res, err := r.db.ExecContext(ctx, `
+		UPDATE job_locks
+		SET holder = $1,
+		    locked_until = $2
+		WHERE name = $3 AND locked_until <= $4 AND last_run_at <= $5
+	`, holder, leaseUntil, name, now, dueAfter)
+	if err != nil {
+		return false, err
+	}
+
+	affected, err := res.RowsAffected()
+	return affected == 1, err
+}
+
+// ReleaseJobLock gives the lease back and records when the job finished. it does nothing
+// if the lease expired and was taken over in the meantime.
+func (r *JobLockRepo) ReleaseJobLock(ctx context.Context, name, holder string, finishedAt int64) error {
+	_, err := r.db.ExecContext(ctx, `
+		UPDATE job_locks
+		SET locked_until = 0,
+		    last_run_at = $1
+		WHERE name = $2 AND holder = $3
+	`, finishedAt, name, holder)
+	return err
+}
@@ -2,6 +2,8 @@ package repo
 
 import (
 	"context"
+	"database/sql"
+	"errors"
 	"fmt"
 	"time"
 
@@ -13,6 +15,7 @@ type LinkRepo struct {
 	linkColumns      Columns
 	colorStopColumns Columns
 	db               *sqlx.DB
+	onChange         ChangeHook
 }
 
 func NewLinkRepo(db *sqlx.DB) *LinkRepo {
@@ -40,7 +43,44 @@ func (r *LinkRepo) CreateLink(ctx context.Context, link *model.Link) error {
 		r.linkColumns.AllPrefixed,
 	)
 	_, err = r.db.NamedExecContext(ctx, insertQuery, link)
-	return err
+	if err != nil {
+		return err
+	}
+
+	r.onChange.notify(link.NodeID)
+	return nil
+}
+
+// CreateLinks appends the links to the node in order in one transaction, setting their
+// positions
+func (r *LinkRepo) CreateLinks(ctx context.Context, nodeID int64, links []model.Link) error {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var maxPosition int
+	err = tx.GetContext(ctx, &maxPosition, `SELECT COALESCE(MAX(position), -1) FROM links WHERE node_id = $1`, nodeID)
+	if err != nil {
+		return err
+	}
+
+	insertQuery := fmt.Sprintf("INSERT INTO links (%s) VALUES (%s)", r.linkColumns.AllRaw, r.linkColumns.AllPrefixed)
+	for i := range links {
+		links[i].NodeID = nodeID
+		links[i].Position = maxPosition + 1 + i
+		if _, err := tx.NamedExecContext(ctx, insertQuery, links[i]); err != nil {
+			return err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	r.onChange.notify(nodeID)
+	return nil
 }
 
 func (r *LinkRepo) GetLinkByID(ctx context.Context, id int64) (*model.Link, error) {
@@ -58,15 +98,44 @@ func (r *LinkRepo) GetLinksByNodeID(ctx context.Context, nodeID int64) ([]model.
 }
 
 func (r *LinkRepo) DeleteLink(ctx context.Context, id int64) error {
+	nodeID, err := r.linkNodeID(ctx, id)
+	if err != nil {
+		return err
+	}
+
 	query := `DELETE FROM links WHERE id = $1`
-	_, err := r.db.ExecContext(ctx, query, id)
-	return err
+	_, err = r.db.ExecContext(ctx, query, id)
+	if err != nil {
+		return err
+	}
+
+	r.onChange.notify(nodeID)
+	return nil
 }
 
 func (r *LinkRepo) DeleteLinksByNodeID(ctx context.Context, nodeID int64) error {
 	query := `DELETE FROM links WHERE node_id = $1`
 	_, err := r.db.ExecContext(ctx, query, nodeID)
-	return err
+	if err != nil {
+		return err
+	}
+
+	r.onChange.notify(nodeID)
+	return nil
+}
+
+// linkNodeID looks up the node of a link for the change hook, only when one is set
+func (r *LinkRepo) linkNodeID(ctx context.Context, linkID int64) (int64, error) {
+	if r.onChange == nil {
+		return 0, nil
+	}
+
+	var nodeID int64
+	err := r.db.GetContext(ctx, &nodeID, `SELECT node_id FROM links WHERE id = $1`, linkID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, nil
+	}
+	return nodeID, err
 }
 
 func (r *LinkRepo) CreateColorStop(ctx context.Context, colorStop *model.ColorStop) error {
@@ -117,7 +186,17 @@ func (r *LinkRepo) DeleteColorStopsByLinkID(ctx context.Context, linkID int64) e
 func (r *LinkRepo) UpdateLinkName(ctx context.Context, linkID int64, name string) error {
 	query := `UPDATE links SET name = $1 WHERE id = $2`
 	_, err := r.db.ExecContext(ctx, query, name, linkID)
-	return err
+	if err != nil {
+		return err
+	}
+
+	nodeID, err := r.linkNodeID(ctx, linkID)
+	if err != nil {
+		return err
+	}
+
+	r.onChange.notify(nodeID)
+	return nil
 }
 
 func (r *LinkRepo) CheckNameExists(ctx context.Context, name string) (bool, error) {
@@ -136,14 +215,14 @@ func (r *LinkRepo) CheckNameExistsInNode(ctx context.Context, name string, nodeI
 
 func (r *LinkRepo) GetVisibleLinksByNodeID(ctx context.Context, nodeID int64) ([]model.Link, error) {
 	var links []model.Link
-	query := fmt.Sprintf("SELECT %s FROM links WHERE node_id = $1 AND visible = true AND enabled = true ORDER BY position ASC, created_at ASC", r.linkColumns.AllRaw)
+	query := fmt.Sprintf("SELECT %s FROM links WHERE node_id = $1 AND visible = true AND enabled = true AND node_id IN (SELECT id FROM nodes WHERE taken_down_at = 0) ORDER BY position ASC, created_at ASC", r.linkColumns.AllRaw)
 	err := r.db.SelectContext(ctx, &links, query, nodeID)
 	return links, err
 }
 
 func (r *LinkRepo) GetLinkRedirectByNameAndNodeID(ctx context.Context, name string, nodeID int64) (string, error) {
 	var link string
-	query := `SELECT link FROM links WHERE name = $1 AND node_id = $2 AND visible = true AND enabled = true`
+	query := `SELECT link FROM links WHERE name = $1 AND node_id = $2 AND visible = true AND enabled = true AND node_id IN (SELECT id FROM nodes WHERE taken_down_at = 0)`
 	err := r.db.GetContext(ctx, &link, query, name, nodeID)
 	return link, err
 }
@@ -169,7 +248,12 @@ func (r *LinkRepo) UpdateLink(ctx context.Context, link *model.Link) error {
 		link.GradientType, link.GradientAngle, link.CustomAccentColorEnabled, link.CustomAccentColor,
 		link.CustomTitleColorEnabled, link.CustomTitleColor, link.CustomDescriptionColorEnabled, link.CustomDescriptionColor,
 		link.MiniBackgroundEnabled, time.Now().UTC().Unix(), link.ID)
-	return err
+	if err != nil {
+		return err
+	}
+
+	r.onChange.notify(link.NodeID)
+	return nil
 }
 
 func (r *LinkRepo) UpdateColorStop(ctx context.Context, colorStop *model.ColorStop) error {
@@ -240,5 +324,10 @@ func (r *LinkRepo) UpdateLinkOrder(ctx context.Context, linkID int64, newPositio
 		}
 	}
 
-	return tx.Commit()
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	r.onChange.notify(link.NodeID)
+	return nil
 }
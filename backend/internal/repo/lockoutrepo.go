@@ -23,19 +23,49 @@ func NewLockoutRepo(db *sqlx.DB) *LockoutRepo {
 	return repo
 }
 
-func (r *LockoutRepo) IsLockedOut(ctx context.Context, userID int64, ipAddress string) (bool, error) {
+// IsLockedOut reports whether logins for the user from the address prefix are blocked,
+// by a lockout on the pair, on the prefix or on the whole account. a userID of 0 only
+// checks the prefix.
+func (r *LockoutRepo) IsLockedOut(ctx context.Context, userID int64, ipPrefix string) (bool, error) {
 	now := time.Now().UTC().Unix()
 
 	var exists bool
 	err := r.db.GetContext(ctx, &exists, `
 		SELECT EXISTS(
-			SELECT 1 FROM lockouts 
-			WHERE user_id = $1 AND ip_address = $2 AND locked_until > $3 AND active = TRUE
+			SELECT 1 FROM lockouts
+			WHERE locked_until > $1 AND active = TRUE AND (
+				(scope = $2 AND user_id = $3 AND ip_prefix = $4) OR
+				(scope = $5 AND ip_prefix = $4) OR
+				(scope = $6 AND user_id = $3)
+			)
 		)
-	`, userID, ipAddress, now)
+	`, now, model.LockoutPair, userID, ipPrefix, model.LockoutIP, model.LockoutAccount)
 	return exists, err
 }
 
+// CountRecentLockouts counts lockouts of a scope created since the given time, active or
+// not, for the backoff. the user is ignored for ip lockouts and the prefix for account ones.
+func (r *LockoutRepo) CountRecentLockouts(ctx context.Context, scope string, userID int64, ipPrefix string, since int64) (int, error) {
+	var count int
+	err := r.db.GetContext(ctx, &count, `
+		SELECT COUNT(*) FROM lockouts
+		WHERE scope = $1 AND created_at > $2
+		  AND ($1 = $3 OR user_id = $4)
+		  AND ($1 = $5 OR ip_prefix = $6)
+	`, scope, since, model.LockoutIP, userID, model.LockoutAccount, ipPrefix)
+	return count, err
+}
+
+func (r *LockoutRepo) GetLockoutByUnlockToken(ctx context.Context, tokenHash string) (*model.Lockout, error) {
+	var lockout model.Lockout
+	query := fmt.Sprintf(`
+		SELECT %s FROM lockouts
+		WHERE unlock_token = $1 AND unlock_token != '' AND scope = $2 AND active = TRUE
+	`, r.lockoutColumns.AllRaw)
+	err := r.db.GetContext(ctx, &lockout, query, tokenHash, model.LockoutAccount)
+	return &lockout, err
+}
+
 func (r *LockoutRepo) AddLockout(ctx context.Context, lockout model.Lockout) error {
 	query := fmt.Sprintf(
 		"INSERT INTO lockouts (%s) VALUES (%s)",
@@ -46,33 +76,40 @@ func (r *LockoutRepo) AddLockout(ctx context.Context, lockout model.Lockout) err
 	return err
 }
 
-func (r *LockoutRepo) UnlockAccount(ctx context.Context, userID int64, ipAddress string) error {
+// UnlockAccount forgives every failed login of the user and lifts the account lockout
+// along with the pair lockout on one address prefix, or on every prefix when ipPrefix is
+// empty. lockouts of the prefix itself are left alone.
+func (r *LockoutRepo) UnlockAccount(ctx context.Context, userID int64, ipPrefix string) error {
 	tx, err := r.db.BeginTxx(ctx, nil)
 	if err != nil {
 		return err
 	}
 
-	_, err = tx.ExecContext(ctx, `
-		UPDATE failed_logins
-		SET active = FALSE
-		WHERE user_id = $1 AND ip_address = $2;
-	`, userID, ipAddress)
-	if err != nil {
+	if err := r.UnlockAccountTx(ctx, tx, userID, ipPrefix); err != nil {
 		tx.Rollback()
 		return err
 	}
 
-	_, err = tx.ExecContext(ctx, `
-		UPDATE lockouts
+	return tx.Commit()
+}
+
+// UnlockAccountTx is UnlockAccount within tx
+func (r *LockoutRepo) UnlockAccountTx(ctx context.Context, tx *sqlx.Tx, userID int64, ipPrefix string) error {
+	_, err := tx.ExecContext(ctx, `
+		UPDATE failed_logins
 		SET active = FALSE
-		WHERE user_id = $1 AND ip_address = $2;
-	`, userID, ipAddress)
+		WHERE user_id = $1;
+	`, userID)
 	if err != nil {
-		tx.Rollback()
 		return err
 	}
 
-	return tx.Commit()
+	_, err = tx.ExecContext(ctx, `
+		UPDATE lockouts
+		SET active = FALSE
+		WHERE user_id = $1 AND (scope = $2 OR $3 = '' OR ip_prefix = $3);
+	`, userID, model.LockoutAccount, ipPrefix)
+	return err
 }
 
 func (r *LockoutRepo) AddFailedLogin(ctx context.Context, failedLogin model.FailedLogin) error {
@@ -85,14 +122,71 @@ func (r *LockoutRepo) AddFailedLogin(ctx context.Context, failedLogin model.Fail
 	return err
 }
 
-func (r *LockoutRepo) CountRecentFailures(ctx context.Context, userID int64, ipAddress string) (int, error) {
-	now := time.Now().UTC().Unix()
-	ago := now - config.App.FailedLoginBacktrack
+// CountRecentFailures counts the user's failed logins from the address prefix within the
+// backtrack window
+func (r *LockoutRepo) CountRecentFailures(ctx context.Context, userID int64, ipPrefix string) (int, error) {
+	ago := time.Now().UTC().Unix() - config.App.FailedLoginBacktrack
 
 	var count int
 	err := r.db.GetContext(ctx, &count, `
 		SELECT COUNT(*) FROM failed_logins
-		WHERE user_id = $1 AND ip_address = $2 AND attempted_at > $3 AND active = TRUE
-	`, userID, ipAddress, ago)
+		WHERE user_id = $1 AND ip_prefix = $2 AND attempted_at > $3 AND active = TRUE
+	`, userID, ipPrefix, ago)
 	return count, err
 }
+
+// CountRecentFailuresByPrefix counts failed logins from the address prefix against any
+// account, unknown emails included
+func (r *LockoutRepo) CountRecentFailuresByPrefix(ctx context.Context, ipPrefix string) (int, error) {
+	ago := time.Now().UTC().Unix() - config.App.FailedLoginBacktrack
+
+	var count int
+	err := r.db.GetContext(ctx, &count, `
+		SELECT COUNT(*) FROM failed_logins
+		WHERE ip_prefix = $1 AND attempted_at > $2 AND active = TRUE
+	`, ipPrefix, ago)
+	return count, err
+}
+
+// CountRecentFailuresByUser counts failed logins against the user from any address
+func (r *LockoutRepo) CountRecentFailuresByUser(ctx context.Context, userID int64) (int, error) {
+	ago := time.Now().UTC().Unix() - config.App.FailedLoginBacktrack
+
+	var count int
+	err := r.db.GetContext(ctx, &count, `
+		SELECT COUNT(*) FROM failed_logins
+		WHERE user_id = $1 AND attempted_at > $2 AND active = TRUE
+	`, userID, ago)
+	return count, err
+}
+
+// DeleteStaleLockouts deletes lockouts that are lifted or over and were created before the
+// given time. newer ones are kept since they still count towards the backoff.
+func (r *LockoutRepo) DeleteStaleLockouts(ctx context.Context, now, createdBefore int64) (int64, error) {
+	res, err := r.db.ExecContext(ctx, `
+		DELETE FROM lockouts
+		WHERE (active = FALSE OR locked_until <= $1) AND created_at < $2
+	`, now, createdBefore)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// DeleteFailedLoginsBefore deletes failed logins attempted before the given time
+func (r *LockoutRepo) DeleteFailedLoginsBefore(ctx context.Context, before int64) (int64, error) {
+	res, err := r.db.ExecContext(ctx, `
+		DELETE FROM failed_logins WHERE attempted_at < $1
+	`, before)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+func (r *LockoutRepo) GetFailedLoginsByUserID(ctx context.Context, userID int64) ([]model.FailedLogin, error) {
+	var failedLogins []model.FailedLogin
+	query := fmt.Sprintf("SELECT %s FROM failed_logins WHERE user_id = $1 ORDER BY attempted_at DESC", r.attemptColumns.AllRaw)
+	err := r.db.SelectContext(ctx, &failedLogins, query, userID)
+	return failedLogins, err
+}
@@ -0,0 +1,76 @@
+package repo
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/akramboussanni/treenode/internal/model"
+	"github.com/akramboussanni/treenode/internal/utils"
+	"github.com/jmoiron/sqlx"
+)
+
+type MfaRepo struct {
+	Columns
+	db *sqlx.DB
+}
+
+func NewMfaRepo(db *sqlx.DB) *MfaRepo {
+	repo := &MfaRepo{db: db}
+	repo.Columns = ExtractColumns[model.RecoveryCode]()
+	return repo
+}
+
+// ReplaceRecoveryCodes drops every existing code for the user and stores the new hashes
+func (r *MfaRepo) ReplaceRecoveryCodes(ctx context.Context, userID int64, hashes []string) error {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, `DELETE FROM mfa_recovery_codes WHERE user_id = $1`, userID)
+	if err != nil {
+		return err
+	}
+
+	query := fmt.Sprintf(
+		"INSERT INTO mfa_recovery_codes (%s) VALUES (%s)",
+		r.AllRaw,
+		r.AllPrefixed,
+	)
+	now := time.Now().UTC().Unix()
+	for _, hash := range hashes {
+		code := model.RecoveryCode{
+			ID:        utils.GenerateSnowflakeID(),
+			UserID:    userID,
+			CodeHash:  hash,
+			CreatedAt: now,
+		}
+		if _, err = tx.NamedExecContext(ctx, query, code); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// UseRecoveryCode marks a matching unused code as used, returning false if none matched
+func (r *MfaRepo) UseRecoveryCode(ctx context.Context, userID int64, hash string) (bool, error) {
+	res, err := r.db.ExecContext(ctx, `
+		UPDATE mfa_recovery_codes
+		SET used_at = $1
+		WHERE user_id = $2 AND code_hash = $3 AND used_at = 0
+	`, time.Now().UTC().Unix(), userID, hash)
+	if err != nil {
+		return false, err
+	}
+
+	affected, err := res.RowsAffected()
+	return affected == 1, err
+}
+
+func (r *MfaRepo) DeleteRecoveryCodes(ctx context.Context, userID int64) error {
+	_, err := r.db.ExecContext(ctx, `DELETE FROM mfa_recovery_codes WHERE user_id = $1`, userID)
+	return err
+}
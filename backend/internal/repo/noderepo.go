@@ -10,12 +10,19 @@ import (
 
 type NodeRepo struct {
 	Columns
-	db *sqlx.DB
+	linkColumns      Columns
+	colorStopColumns Columns
+	retiredColumns   Columns
+	db               *sqlx.DB
+	onChange         ChangeHook
 }
 
 func NewNodeRepo(db *sqlx.DB) *NodeRepo {
 	repo := &NodeRepo{db: db}
 	repo.Columns = ExtractColumns[model.Node]()
+	repo.linkColumns = ExtractColumns[model.Link]()
+	repo.colorStopColumns = ExtractColumns[model.ColorStop]()
+	repo.retiredColumns = ExtractColumns[model.RetiredSubdomain]()
 	return repo
 }
 
@@ -29,6 +36,43 @@ func (r *NodeRepo) CreateNode(ctx context.Context, node *model.Node) error {
 	return err
 }
 
+// CreateNodeWithLinks creates the node with its links, their color stops and its
+// collaborators in one transaction. ids are taken as they are.
+func (r *NodeRepo) CreateNodeWithLinks(ctx context.Context, node *model.Node, links []model.Link, collaborators []int64) error {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	nodeQuery := fmt.Sprintf("INSERT INTO nodes (%s) VALUES (%s)", r.AllRaw, r.AllPrefixed)
+	if _, err := tx.NamedExecContext(ctx, nodeQuery, node); err != nil {
+		return err
+	}
+
+	linkQuery := fmt.Sprintf("INSERT INTO links (%s) VALUES (%s)", r.linkColumns.AllRaw, r.linkColumns.AllPrefixed)
+	stopQuery := fmt.Sprintf("INSERT INTO color_stops (%s) VALUES (%s)", r.colorStopColumns.AllRaw, r.colorStopColumns.AllPrefixed)
+	for _, link := range links {
+		if _, err := tx.NamedExecContext(ctx, linkQuery, link); err != nil {
+			return err
+		}
+
+		for _, stop := range link.ColorStops {
+			if _, err := tx.NamedExecContext(ctx, stopQuery, stop); err != nil {
+				return err
+			}
+		}
+	}
+
+	for _, userID := range collaborators {
+		if _, err := tx.ExecContext(ctx, `INSERT INTO node_access (node_id, user_id) VALUES ($1, $2) ON CONFLICT DO NOTHING`, node.ID, userID); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
 func (r *NodeRepo) GetNodeByID(ctx context.Context, id int64) (*model.Node, error) {
 	var node model.Node
 	query := fmt.Sprintf("SELECT %s FROM nodes WHERE id = $1", r.AllRaw)
@@ -36,9 +80,10 @@ func (r *NodeRepo) GetNodeByID(ctx context.Context, id int64) (*model.Node, erro
 	return &node, err
 }
 
+// GetNodeByDomain returns the node that verified the custom domain
 func (r *NodeRepo) GetNodeByDomain(ctx context.Context, domain string) (*model.Node, error) {
 	var node model.Node
-	query := fmt.Sprintf("SELECT %s FROM nodes WHERE domain = $1", r.AllRaw)
+	query := fmt.Sprintf("SELECT %s FROM nodes WHERE domain = $1 AND domain_verified = TRUE", r.AllRaw)
 	err := r.db.GetContext(ctx, &node, query, domain)
 	return &node, err
 }
@@ -50,29 +95,182 @@ func (r *NodeRepo) GetNodesByOwnerID(ctx context.Context, ownerID int64) ([]mode
 	return nodes, err
 }
 
+// UpdateNode saves the page settings. the custom domain is only changed
+// through SetNodeDomain and the verification methods.
 func (r *NodeRepo) UpdateNode(ctx context.Context, node *model.Node) error {
+	if err := updateNode(ctx, r.db, node); err != nil {
+		return err
+	}
+
+	r.onChange.notify(node.ID)
+	return nil
+}
+
+// RenameNode updates the node and records its previous subdomain in one transaction, so
+// the history never lists a rename that didn't happen. the entry for the name the node
+// takes back, if any, is dropped since it's live again.
+func (r *NodeRepo) RenameNode(ctx context.Context, node *model.Node, retired *model.RetiredSubdomain) error {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	query := `DELETE FROM subdomain_history WHERE node_id = $1 AND (LOWER(subdomain_name) = LOWER($2) OR LOWER(subdomain_name) = LOWER($3))`
+	if _, err := tx.ExecContext(ctx, query, node.ID, retired.SubdomainName, node.SubdomainName); err != nil {
+		return err
+	}
+
+	insertQuery := fmt.Sprintf("INSERT INTO subdomain_history (%s) VALUES (%s)", r.retiredColumns.AllRaw, r.retiredColumns.AllPrefixed)
+	if _, err := tx.NamedExecContext(ctx, insertQuery, retired); err != nil {
+		return err
+	}
+
+	if err := updateNode(ctx, tx, node); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	r.onChange.notify(node.ID)
+	return nil
+}
+
+func updateNode(ctx context.Context, ex sqlx.ExecerContext, node *model.Node) error {
 	query := `
 		UPDATE nodes 
-		SET domain = $1, domain_verified = $2, subdomain_name = $3, display_name = $4, 
-		    description = $5, background_color = $6, title_font_color = $7, caption_font_color = $8, 
-		    accent_color = $9, theme_color = $10, show_share_button = $11, theme = $12, 
-		    mouse_effects_enabled = $13, text_shadows_enabled = $14, page_title = $15, updated_at = $16, hide_powered_by = $17
-		WHERE id = $18
+		SET subdomain_name = $1, display_name = $2, 
+		    description = $3, background_color = $4, title_font_color = $5, caption_font_color = $6, 
+		    accent_color = $7, theme_color = $8, show_share_button = $9, theme = $10, 
+		    mouse_effects_enabled = $11, text_shadows_enabled = $12, page_title = $13, updated_at = $14, hide_powered_by = $15
+		WHERE id = $16
 	`
-	_, err := r.db.ExecContext(ctx, query,
-		node.Domain, node.DomainVerified, node.SubdomainName, node.DisplayName,
+	_, err := ex.ExecContext(ctx, query,
+		node.SubdomainName, node.DisplayName,
 		node.Description, node.BackgroundColor, node.TitleFontColor, node.CaptionFontColor,
 		node.AccentColor, node.ThemeColor, node.ShowShareButton, node.Theme,
 		node.MouseEffectsEnabled, node.TextShadowsEnabled, node.PageTitle, node.UpdatedAt, node.HidePoweredBy, node.ID)
 	return err
 }
 
-func (r *NodeRepo) DeleteNode(ctx context.Context, id int64) error {
-	query := `DELETE FROM nodes WHERE id = $1`
-	_, err := r.db.ExecContext(ctx, query, id)
+// SetNodeDomain attaches an unverified domain with a fresh verification token, an
+// empty domain detaches it
+func (r *NodeRepo) SetNodeDomain(ctx context.Context, id int64, domain, token string, now int64) error {
+	query := `
+		UPDATE nodes
+		SET domain = $1, domain_token = $2, domain_verified = FALSE, domain_verified_at = 0,
+		    domain_checked_at = 0, domain_check_failures = 0, updated_at = $3
+		WHERE id = $4
+	`
+	_, err := r.db.ExecContext(ctx, query, domain, token, now, id)
+	return err
+}
+
+// DomainVerifiedElsewhere reports whether another node already proved the domain
+func (r *NodeRepo) DomainVerifiedElsewhere(ctx context.Context, domain string, excludeNodeID int64) (bool, error) {
+	var exists bool
+	err := r.db.GetContext(ctx, &exists, "SELECT EXISTS(SELECT 1 FROM nodes WHERE domain = $1 AND domain_verified = TRUE AND id != $2)", domain, excludeNodeID)
+	return exists, err
+}
+
+// MarkDomainVerified verifies the node's domain unless it changed since it was checked
+func (r *NodeRepo) MarkDomainVerified(ctx context.Context, id int64, domain string, now int64) (bool, error) {
+	res, err := r.db.ExecContext(ctx, `
+		UPDATE nodes
+		SET domain_verified = TRUE, domain_verified_at = $1, domain_checked_at = $1, domain_check_failures = 0
+		WHERE id = $2 AND domain = $3
+	`, now, id, domain)
+	if err != nil {
+		return false, err
+	}
+
+	affected, err := res.RowsAffected()
+	return affected == 1, err
+}
+
+// RecordDomainCheck stores the outcome of a periodic recheck, unless the domain
+// changed while it ran
+func (r *NodeRepo) RecordDomainCheck(ctx context.Context, id int64, domain string, now int64, failures int, verified bool) error {
+	query := `
+		UPDATE nodes
+		SET domain_checked_at = $1, domain_check_failures = $2, domain_verified = $3
+		WHERE id = $4 AND domain = $5
+	`
+	_, err := r.db.ExecContext(ctx, query, now, failures, verified, id, domain)
 	return err
 }
 
+// GetNodesDueForDomainCheck returns verified nodes last checked before the given time,
+// least recently checked first
+func (r *NodeRepo) GetNodesDueForDomainCheck(ctx context.Context, checkedBefore int64, limit int) ([]model.Node, error) {
+	var nodes []model.Node
+	query := fmt.Sprintf(`
+		SELECT %s FROM nodes
+		WHERE domain_verified = TRUE AND domain_checked_at < $1
+		ORDER BY domain_checked_at ASC
+		LIMIT $2
+	`, r.AllRaw)
+	err := r.db.SelectContext(ctx, &nodes, query, checkedBefore, limit)
+	return nodes, err
+}
+
+// SetNodeTakedown hides the node from public pages, an at of 0 restores it
+func (r *NodeRepo) SetNodeTakedown(ctx context.Context, id int64, at int64, reason string) error {
+	return setNodeTakedown(ctx, r.db, id, at, reason)
+}
+
+// SetNodeTakedownTx is SetNodeTakedown within tx
+func (r *NodeRepo) SetNodeTakedownTx(ctx context.Context, tx *sqlx.Tx, id int64, at int64, reason string) error {
+	return setNodeTakedown(ctx, tx, id, at, reason)
+}
+
+func setNodeTakedown(ctx context.Context, ex sqlx.ExecerContext, id int64, at int64, reason string) error {
+	query := `UPDATE nodes SET taken_down_at = $1, takedown_reason = $2 WHERE id = $3`
+	_, err := ex.ExecContext(ctx, query, at, reason, id)
+	return err
+}
+
+// TakeDownNodesByOwnerIDTx hides every public node of the owner within tx
+func (r *NodeRepo) TakeDownNodesByOwnerIDTx(ctx context.Context, tx *sqlx.Tx, ownerID int64, at int64, reason string) error {
+	query := `UPDATE nodes SET taken_down_at = $1, takedown_reason = $2 WHERE owner_id = $3 AND taken_down_at = 0`
+	_, err := tx.ExecContext(ctx, query, at, reason, ownerID)
+	return err
+}
+
+// DeleteNode deletes the node with its revisions, publication and subdomain history, sqlite doesn't enforce the cascade
+func (r *NodeRepo) DeleteNode(ctx context.Context, id int64) error {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM node_revisions WHERE node_id = $1`, id); err != nil {
+		return err
+	}
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM node_publications WHERE node_id = $1`, id); err != nil {
+		return err
+	}
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM subdomain_history WHERE node_id = $1`, id); err != nil {
+		return err
+	}
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM nodes WHERE id = $1`, id); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	r.onChange.notify(id)
+	return nil
+}
+
 func (r *NodeRepo) CheckNodeAccess(ctx context.Context, nodeID int64, userID int64) (bool, error) {
 	var exists bool
 	err := r.db.GetContext(ctx, &exists, `
@@ -129,6 +327,20 @@ func (r *NodeRepo) GetNodeBySubdomain(ctx context.Context, subdomainName string)
 	return &node, err
 }
 
+// GetNodeBySubdomainFold looks a subdomain up the way hostnames compare, ignoring case.
+// if several nodes only differ in case, an all lowercase one wins, then the oldest.
+func (r *NodeRepo) GetNodeBySubdomainFold(ctx context.Context, subdomainName string) (*model.Node, error) {
+	var node model.Node
+	query := fmt.Sprintf(`
+		SELECT %s FROM nodes
+		WHERE LOWER(subdomain_name) = LOWER($1)
+		ORDER BY CASE WHEN subdomain_name = LOWER($1) THEN 0 ELSE 1 END, created_at ASC
+		LIMIT 1
+	`, r.AllRaw)
+	err := r.db.GetContext(ctx, &node, query, subdomainName)
+	return &node, err
+}
+
 func (r *NodeRepo) GetSharedNodesByUserID(ctx context.Context, userID int64) ([]model.Node, error) {
 	var nodes []model.Node
 	query := fmt.Sprintf(`
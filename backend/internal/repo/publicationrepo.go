@@ -0,0 +1,112 @@
+package repo
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/akramboussanni/treenode/internal/model"
+	"github.com/jmoiron/sqlx"
+)
+
+type PublicationRepo struct {
+	Columns
+	db       *sqlx.DB
+	onChange ChangeHook
+}
+
+func NewPublicationRepo(db *sqlx.DB) *PublicationRepo {
+	repo := &PublicationRepo{db: db}
+	repo.Columns = ExtractColumns[model.NodePublication]()
+	return repo
+}
+
+func (r *PublicationRepo) GetPublication(ctx context.Context, nodeID int64) (*model.NodePublication, error) {
+	var pub model.NodePublication
+	query := fmt.Sprintf("SELECT %s FROM node_publications WHERE node_id = $1", r.AllRaw)
+	err := r.db.GetContext(ctx, &pub, query, nodeID)
+	return &pub, err
+}
+
+func (r *PublicationRepo) HasPublication(ctx context.Context, nodeID int64) (bool, error) {
+	var exists bool
+	query := `SELECT EXISTS(SELECT 1 FROM node_publications WHERE node_id = $1)`
+	err := r.db.GetContext(ctx, &exists, query, nodeID)
+	return exists, err
+}
+
+// CreatePublication publishes the node's first state, it's a no-op when the node was
+// published in the meantime
+func (r *PublicationRepo) CreatePublication(ctx context.Context, pub *model.NodePublication) error {
+	query := fmt.Sprintf(
+		"INSERT INTO node_publications (%s) VALUES (%s) ON CONFLICT(node_id) DO NOTHING",
+		r.AllRaw,
+		r.AllPrefixed,
+	)
+	_, err := r.db.NamedExecContext(ctx, query, pub)
+	return err
+}
+
+// Publish replaces the published snapshot and drops any scheduled publication
+func (r *PublicationRepo) Publish(ctx context.Context, nodeID int64, snapshot string, userID, at int64) error {
+	_, err := r.db.ExecContext(ctx, `
+		INSERT INTO node_publications (node_id, snapshot, published_at, published_by)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT(node_id) DO UPDATE SET snapshot = excluded.snapshot, published_at = excluded.published_at,
+			published_by = excluded.published_by, scheduled_snapshot = '', scheduled_at = 0, scheduled_by = 0`,
+		nodeID, snapshot, at, userID)
+	if err != nil {
+		return err
+	}
+
+	r.onChange.notify(nodeID)
+	return nil
+}
+
+// Schedule sets the snapshot to publish at the given time, replacing the scheduled one.
+// the node must already have a publication.
+func (r *PublicationRepo) Schedule(ctx context.Context, nodeID int64, snapshot string, userID, at int64) error {
+	query := `UPDATE node_publications SET scheduled_snapshot = $1, scheduled_at = $2, scheduled_by = $3 WHERE node_id = $4`
+	_, err := r.db.ExecContext(ctx, query, snapshot, at, userID, nodeID)
+	return err
+}
+
+func (r *PublicationRepo) CancelSchedule(ctx context.Context, nodeID int64) (bool, error) {
+	query := `UPDATE node_publications SET scheduled_snapshot = '', scheduled_at = 0, scheduled_by = 0 WHERE node_id = $1 AND scheduled_at != 0`
+	res, err := r.db.ExecContext(ctx, query, nodeID)
+	if err != nil {
+		return false, err
+	}
+
+	rows, err := res.RowsAffected()
+	return rows > 0, err
+}
+
+// PublishDue publishes the scheduled snapshots due by now, at most limit of them
+func (r *PublicationRepo) PublishDue(ctx context.Context, now int64, limit int) (int64, error) {
+	var nodeIDs []int64
+	query := `SELECT node_id FROM node_publications WHERE scheduled_at != 0 AND scheduled_at <= $1 ORDER BY scheduled_at LIMIT $2`
+	if err := r.db.SelectContext(ctx, &nodeIDs, query, now, limit); err != nil {
+		return 0, err
+	}
+
+	var published int64
+	for _, nodeID := range nodeIDs {
+		// the schedule may have been replaced or cancelled since the select
+		res, err := r.db.ExecContext(ctx, `
+			UPDATE node_publications
+			SET snapshot = scheduled_snapshot, published_at = scheduled_at, published_by = scheduled_by,
+				scheduled_snapshot = '', scheduled_at = 0, scheduled_by = 0
+			WHERE node_id = $1 AND scheduled_at != 0 AND scheduled_at <= $2`,
+			nodeID, now)
+		if err != nil {
+			return published, err
+		}
+
+		if rows, _ := res.RowsAffected(); rows > 0 {
+			published++
+			r.onChange.notify(nodeID)
+		}
+	}
+
+	return published, nil
+}
@@ -8,12 +8,21 @@ import (
 )
 
 type Repos struct {
-	User       *UserRepo
-	Token      *TokenRepo
-	Lockout    *LockoutRepo
-	Node       *NodeRepo
-	Link       *LinkRepo
-	Invitation *InvitationRepo
+	User        *UserRepo
+	Token       *TokenRepo
+	Lockout     *LockoutRepo
+	Node        *NodeRepo
+	Link        *LinkRepo
+	Invitation  *InvitationRepo
+	Mfa         *MfaRepo
+	Session     *SessionRepo
+	ApiToken    *ApiTokenRepo
+	Identity    *IdentityRepo
+	Audit       *AuditRepo
+	JobLock     *JobLockRepo
+	Revision    *RevisionRepo
+	Publication *PublicationRepo
+	Subdomain   *SubdomainRepo
 }
 
 type Columns struct {
@@ -27,15 +36,42 @@ type Columns struct {
 
 func NewRepos(db *sqlx.DB) *Repos {
 	return &Repos{
-		User:       NewUserRepo(db),
-		Token:      NewTokenRepo(db),
-		Lockout:    NewLockoutRepo(db),
-		Node:       NewNodeRepo(db),
-		Link:       NewLinkRepo(db),
-		Invitation: NewInvitationRepo(db),
+		User:        NewUserRepo(db),
+		Token:       NewTokenRepo(db),
+		Lockout:     NewLockoutRepo(db),
+		Node:        NewNodeRepo(db),
+		Link:        NewLinkRepo(db),
+		Invitation:  NewInvitationRepo(db),
+		Mfa:         NewMfaRepo(db),
+		Session:     NewSessionRepo(db),
+		ApiToken:    NewApiTokenRepo(db),
+		Identity:    NewIdentityRepo(db),
+		Audit:       NewAuditRepo(db),
+		JobLock:     NewJobLockRepo(db),
+		Revision:    NewRevisionRepo(db),
+		Publication: NewPublicationRepo(db),
+		Subdomain:   NewSubdomainRepo(db),
 	}
 }
 
+// ChangeHook is called with the id of a node after its page or its links changed
+type ChangeHook func(nodeID int64)
+
+func (h ChangeHook) notify(nodeID int64) {
+	if h != nil {
+		h(nodeID)
+	}
+}
+
+// OnNodeChange registers the hook called when a node, its links or its published state
+// are written, so anything derived from the page content can be dropped
+func (r *Repos) OnNodeChange(hook ChangeHook) {
+	r.Node.onChange = hook
+	r.Link.onChange = hook
+	r.Revision.onChange = hook
+	r.Publication.onChange = hook
+}
+
 func ExtractColumns[T any]() Columns {
 	var allCols, safeCols []string
 
@@ -0,0 +1,120 @@
+package repo
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/akramboussanni/treenode/internal/model"
+	"github.com/jmoiron/sqlx"
+)
+
+type RevisionRepo struct {
+	Columns
+	linkColumns      Columns
+	colorStopColumns Columns
+	db               *sqlx.DB
+	onChange         ChangeHook
+}
+
+func NewRevisionRepo(db *sqlx.DB) *RevisionRepo {
+	repo := &RevisionRepo{db: db}
+	repo.Columns = ExtractColumns[model.NodeRevision]()
+	repo.linkColumns = ExtractColumns[model.Link]()
+	repo.colorStopColumns = ExtractColumns[model.ColorStop]()
+	return repo
+}
+
+func (r *RevisionRepo) CreateRevision(ctx context.Context, rev *model.NodeRevision) error {
+	query := fmt.Sprintf(
+		"INSERT INTO node_revisions (%s) VALUES (%s)",
+		r.AllRaw,
+		r.AllPrefixed,
+	)
+	_, err := r.db.NamedExecContext(ctx, query, rev)
+	return err
+}
+
+func (r *RevisionRepo) HasRevisions(ctx context.Context, nodeID int64) (bool, error) {
+	var exists bool
+	query := `SELECT EXISTS(SELECT 1 FROM node_revisions WHERE node_id = $1)`
+	err := r.db.GetContext(ctx, &exists, query, nodeID)
+	return exists, err
+}
+
+// GetRevisions lists the node's revisions newest first, without their snapshots
+func (r *RevisionRepo) GetRevisions(ctx context.Context, nodeID int64, limit, offset int) ([]model.NodeRevision, error) {
+	var revs []model.NodeRevision
+	query := `SELECT id, node_id, user_id, action, created_at FROM node_revisions
+		WHERE node_id = $1 ORDER BY created_at DESC, id DESC LIMIT $2 OFFSET $3`
+	err := r.db.SelectContext(ctx, &revs, query, nodeID, limit, offset)
+	return revs, err
+}
+
+func (r *RevisionRepo) GetRevision(ctx context.Context, nodeID, id int64) (*model.NodeRevision, error) {
+	var rev model.NodeRevision
+	query := fmt.Sprintf("SELECT %s FROM node_revisions WHERE id = $1 AND node_id = $2", r.AllRaw)
+	err := r.db.GetContext(ctx, &rev, query, id, nodeID)
+	return &rev, err
+}
+
+// RestoreSnapshot puts the node's page settings, links and color stops back to the
+// snapshot and records rev, all in one transaction. links keep their ids.
+func (r *RevisionRepo) RestoreSnapshot(ctx context.Context, nodeID int64, snap *model.NodeSnapshot, rev *model.NodeRevision) error {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, `
+		UPDATE nodes
+		SET display_name = $1, description = $2, background_color = $3, title_font_color = $4,
+		    caption_font_color = $5, accent_color = $6, theme_color = $7, show_share_button = $8, theme = $9,
+		    mouse_effects_enabled = $10, text_shadows_enabled = $11, hide_powered_by = $12, page_title = $13, updated_at = $14
+		WHERE id = $15`,
+		snap.DisplayName, snap.Description, snap.BackgroundColor, snap.TitleFontColor,
+		snap.CaptionFontColor, snap.AccentColor, snap.ThemeColor, snap.ShowShareButton, snap.Theme,
+		snap.MouseEffectsEnabled, snap.TextShadowsEnabled, snap.HidePoweredBy, snap.PageTitle, rev.CreatedAt,
+		nodeID)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, `DELETE FROM color_stops WHERE link_id IN (SELECT id FROM links WHERE node_id = $1)`, nodeID)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, `DELETE FROM links WHERE node_id = $1`, nodeID)
+	if err != nil {
+		return err
+	}
+
+	linkQuery := fmt.Sprintf("INSERT INTO links (%s) VALUES (%s)", r.linkColumns.AllRaw, r.linkColumns.AllPrefixed)
+	stopQuery := fmt.Sprintf("INSERT INTO color_stops (%s) VALUES (%s)", r.colorStopColumns.AllRaw, r.colorStopColumns.AllPrefixed)
+	for _, link := range snap.Links {
+		link.NodeID = nodeID
+		if _, err := tx.NamedExecContext(ctx, linkQuery, link); err != nil {
+			return err
+		}
+
+		for _, stop := range link.ColorStops {
+			stop.LinkID = link.ID
+			if _, err := tx.NamedExecContext(ctx, stopQuery, stop); err != nil {
+				return err
+			}
+		}
+	}
+
+	revQuery := fmt.Sprintf("INSERT INTO node_revisions (%s) VALUES (%s)", r.AllRaw, r.AllPrefixed)
+	if _, err := tx.NamedExecContext(ctx, revQuery, rev); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	r.onChange.notify(nodeID)
+	return nil
+}
@@ -0,0 +1,83 @@
+package repo
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/akramboussanni/treenode/internal/model"
+	"github.com/jmoiron/sqlx"
+)
+
+type SessionRepo struct {
+	Columns
+	db *sqlx.DB
+}
+
+func NewSessionRepo(db *sqlx.DB) *SessionRepo {
+	repo := &SessionRepo{db: db}
+	repo.Columns = ExtractColumns[model.Session]()
+	return repo
+}
+
+func (r *SessionRepo) CreateSession(ctx context.Context, session *model.Session) error {
+	query := fmt.Sprintf(
+		"INSERT INTO sessions (%s) VALUES (%s)",
+		r.AllRaw,
+		r.AllPrefixed,
+	)
+	_, err := r.db.NamedExecContext(ctx, query, session)
+	return err
+}
+
+func (r *SessionRepo) GetSessionByID(ctx context.Context, id int64) (*model.Session, error) {
+	var session model.Session
+	query := fmt.Sprintf("SELECT %s FROM sessions WHERE id = $1", r.AllRaw)
+	err := r.db.GetContext(ctx, &session, query, id)
+	return &session, err
+}
+
+// GetSessionsByUserID lists sessions seen after the given unix time, most recent first
+func (r *SessionRepo) GetSessionsByUserID(ctx context.Context, userID int64, seenAfter int64) ([]model.Session, error) {
+	var sessions []model.Session
+	query := fmt.Sprintf("SELECT %s FROM sessions WHERE user_id = $1 AND last_seen_at > $2 ORDER BY last_seen_at DESC", r.AllRaw)
+	err := r.db.SelectContext(ctx, &sessions, query, userID, seenAfter)
+	return sessions, err
+}
+
+// RotateRefreshToken swaps the session's current refresh jti for the next one. it only
+// succeeds if oldJti is still current, so two redemptions of the same token can't both win.
+func (r *SessionRepo) RotateRefreshToken(ctx context.Context, id int64, oldJti, newJti string, lastSeenAt int64, ip, userAgent string) (bool, error) {
+	query := `
+		UPDATE sessions
+		SET refresh_jti = $1, last_seen_at = $2, ip_address = $3, user_agent = $4
+		WHERE id = $5 AND refresh_jti = $6
+	`
+	res, err := r.db.ExecContext(ctx, query, newJti, lastSeenAt, ip, userAgent, id, oldJti)
+	if err != nil {
+		return false, err
+	}
+
+	affected, err := res.RowsAffected()
+	return affected == 1, err
+}
+
+// DeleteSession removes a session, scoped to its owner so users can't revoke each other
+func (r *SessionRepo) DeleteSession(ctx context.Context, id int64, userID int64) (bool, error) {
+	res, err := r.db.ExecContext(ctx, `DELETE FROM sessions WHERE id = $1 AND user_id = $2`, id, userID)
+	if err != nil {
+		return false, err
+	}
+
+	affected, err := res.RowsAffected()
+	return affected == 1, err
+}
+
+// DeleteStaleSessions removes sessions last seen before the given unix time, their
+// refresh tokens can't be redeemed anymore
+func (r *SessionRepo) DeleteStaleSessions(ctx context.Context, seenBefore int64) (int64, error) {
+	res, err := r.db.ExecContext(ctx, `DELETE FROM sessions WHERE last_seen_at < $1`, seenBefore)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
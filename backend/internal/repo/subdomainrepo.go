@@ -0,0 +1,63 @@
+package repo
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/akramboussanni/treenode/internal/model"
+	"github.com/jmoiron/sqlx"
+)
+
+type SubdomainRepo struct {
+	Columns
+	db *sqlx.DB
+}
+
+func NewSubdomainRepo(db *sqlx.DB) *SubdomainRepo {
+	repo := &SubdomainRepo{db: db}
+	repo.Columns = ExtractColumns[model.RetiredSubdomain]()
+	return repo
+}
+
+func (r *SubdomainRepo) GetRetiredSubdomains(ctx context.Context, nodeID int64) ([]model.RetiredSubdomain, error) {
+	retired := []model.RetiredSubdomain{}
+	query := fmt.Sprintf("SELECT %s FROM subdomain_history WHERE node_id = $1 ORDER BY retired_at DESC", r.AllRaw)
+	err := r.db.SelectContext(ctx, &retired, query, nodeID)
+	return retired, err
+}
+
+// GetRedirect returns the current subdomain of the node that last retired the name,
+// ignoring case. sql.ErrNoRows when the name wasn't retired, was released or its node
+// is taken down.
+func (r *SubdomainRepo) GetRedirect(ctx context.Context, name string) (string, error) {
+	var current string
+	err := r.db.GetContext(ctx, &current, `
+		SELECT n.subdomain_name FROM subdomain_history h
+		INNER JOIN nodes n ON n.id = h.node_id
+		WHERE LOWER(h.subdomain_name) = LOWER($1) AND h.released_at = 0 AND n.taken_down_at = 0
+		ORDER BY h.retired_at DESC
+		LIMIT 1
+	`, name)
+	return current, err
+}
+
+// IsHeld reports whether another node than excludeNodeID retired the name, ignoring case,
+// and still holds it at now
+func (r *SubdomainRepo) IsHeld(ctx context.Context, name string, excludeNodeID, now int64) (bool, error) {
+	var held bool
+	query := `SELECT EXISTS(SELECT 1 FROM subdomain_history WHERE LOWER(subdomain_name) = LOWER($1) AND node_id != $2 AND released_at = 0 AND held_until > $3)`
+	err := r.db.GetContext(ctx, &held, query, name, excludeNodeID, now)
+	return held, err
+}
+
+// Release stops the redirect and the hold of a name the node retired
+func (r *SubdomainRepo) Release(ctx context.Context, nodeID int64, name string, now int64) (bool, error) {
+	query := `UPDATE subdomain_history SET released_at = $1 WHERE node_id = $2 AND LOWER(subdomain_name) = LOWER($3) AND released_at = 0`
+	res, err := r.db.ExecContext(ctx, query, now, nodeID, name)
+	if err != nil {
+		return false, err
+	}
+
+	rows, err := res.RowsAffected()
+	return rows > 0, err
+}
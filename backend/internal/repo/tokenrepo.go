@@ -33,9 +33,13 @@ func (r *TokenRepo) IsTokenRevoked(jti string) (bool, error) {
 	return exists, err
 }
 
-func (r *TokenRepo) CleanupTokens(ctx context.Context) error {
-	_, err := r.db.ExecContext(ctx, `
-		DELETE FROM jwt_blacklist WHERE expires_at < CURRENT_TIMESTAMP
-	`)
-	return err
+// CleanupTokens deletes blacklist entries for tokens that have expired anyway
+func (r *TokenRepo) CleanupTokens(ctx context.Context, now int64) (int64, error) {
+	res, err := r.db.ExecContext(ctx, `
+		DELETE FROM jwt_blacklist WHERE expires_at < $1
+	`, now)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
 }
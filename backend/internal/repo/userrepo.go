@@ -3,6 +3,7 @@ package repo
 import (
 	"context"
 	"fmt"
+	"strings"
 
 	"github.com/akramboussanni/treenode/internal/model"
 	"github.com/jmoiron/sqlx"
@@ -10,12 +11,14 @@ import (
 
 type UserRepo struct {
 	Columns
-	db *sqlx.DB
+	transferColumns Columns
+	db              *sqlx.DB
 }
 
 func NewUserRepo(db *sqlx.DB) *UserRepo {
 	repo := &UserRepo{db: db}
 	repo.Columns = ExtractColumns[model.User]()
+	repo.transferColumns = ExtractColumns[model.NodeTransfer]()
 	return repo
 }
 
@@ -62,6 +65,63 @@ func (r *UserRepo) GetUserByEmail(ctx context.Context, email string) (*model.Use
 	return &user, err
 }
 
+// SearchUsers matches the query against usernames and emails, newest accounts first.
+// an empty query lists every user.
+func (r *UserRepo) SearchUsers(ctx context.Context, query string, limit, offset int) ([]model.User, error) {
+	var users []model.User
+	pattern := "%" + strings.ToLower(query) + "%"
+	q := fmt.Sprintf(`
+		SELECT %s FROM users
+		WHERE LOWER(username) LIKE $1 OR LOWER(email) LIKE $1
+		ORDER BY created_at DESC, id DESC
+		LIMIT $2 OFFSET $3
+	`, r.AllRaw)
+	err := r.db.SelectContext(ctx, &users, q, pattern, limit, offset)
+	return users, err
+}
+
+func (r *UserRepo) SetUserStatus(ctx context.Context, userID int64, status, reason string) error {
+	return setUserStatus(ctx, r.db, userID, status, reason)
+}
+
+// SetUserStatusTx is SetUserStatus within tx
+func (r *UserRepo) SetUserStatusTx(ctx context.Context, tx *sqlx.Tx, userID int64, status, reason string) error {
+	return setUserStatus(ctx, tx, userID, status, reason)
+}
+
+func setUserStatus(ctx context.Context, ex sqlx.ExecerContext, userID int64, status, reason string) error {
+	query := `
+		UPDATE users
+		SET account_status = $1,
+		    status_reason = $2
+		WHERE id = $3
+	`
+	_, err := ex.ExecContext(ctx, query, status, reason, userID)
+	return err
+}
+
+// PromoteAdmins gives the admin role to the confirmed accounts owning these emails
+func (r *UserRepo) PromoteAdmins(ctx context.Context, emails []string) (int64, error) {
+	var promoted int64
+	for _, email := range emails {
+		res, err := r.db.ExecContext(ctx, `
+			UPDATE users
+			SET user_role = $1
+			WHERE email = $2 AND email_confirmed = TRUE AND user_role != $1
+		`, model.RoleAdmin, email)
+		if err != nil {
+			return promoted, err
+		}
+
+		affected, err := res.RowsAffected()
+		if err != nil {
+			return promoted, err
+		}
+		promoted += affected
+	}
+	return promoted, nil
+}
+
 func (r *UserRepo) DeleteUser(ctx context.Context, id int64) error {
 	query := `DELETE FROM users WHERE id = $1`
 	_, err := r.db.ExecContext(ctx, query, id)
@@ -90,6 +150,15 @@ func (r *UserRepo) AssignUserConfirmToken(ctx context.Context, token string, iat
 }
 
 func (r *UserRepo) MarkUserConfirmed(ctx context.Context, userID int64) error {
+	return markUserConfirmed(ctx, r.db, userID)
+}
+
+// MarkUserConfirmedTx is MarkUserConfirmed within tx
+func (r *UserRepo) MarkUserConfirmedTx(ctx context.Context, tx *sqlx.Tx, userID int64) error {
+	return markUserConfirmed(ctx, tx, userID)
+}
+
+func markUserConfirmed(ctx context.Context, ex sqlx.ExecerContext, userID int64) error {
 	query := `
 		UPDATE users
 		SET email_confirmed = TRUE,
@@ -97,10 +166,61 @@ func (r *UserRepo) MarkUserConfirmed(ctx context.Context, userID int64) error {
 		    email_confirm_issuedat = 0
 		WHERE id = $1
 	`
-	_, err := r.db.ExecContext(ctx, query, userID)
+	_, err := ex.ExecContext(ctx, query, userID)
 	return err
 }
 
+// ClaimUnconfirmedUser confirms an account whose address was only just proven, dropping
+// everything set up before: whoever registered it may not own the address. the password,
+// pending tokens, email change, totp, sessions and api tokens are cleared and the session
+// id rotated. false when the account was confirmed in the meantime.
+func (r *UserRepo) ClaimUnconfirmedUser(ctx context.Context, userID int64, newSessionID int64) (bool, error) {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	res, err := tx.ExecContext(ctx, `
+		UPDATE users
+		SET email_confirmed = TRUE,
+		    email_confirm_token = '',
+		    email_confirm_issuedat = 0,
+		    password_hash = '',
+		    password_reset_token = '',
+		    password_reset_issuedat = 0,
+		    magic_link_token = '',
+		    magic_link_issuedat = 0,
+		    pending_email = '',
+		    email_change_token = '',
+		    email_change_cancel_token = '',
+		    email_change_issuedat = 0,
+		    totp_secret = '',
+		    totp_enabled = FALSE,
+		    totp_last_step = 0,
+		    jwt_session_id = $1
+		WHERE id = $2 AND email_confirmed = FALSE
+	`, newSessionID, userID)
+	if err != nil {
+		return false, err
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil || rows == 0 {
+		return false, err
+	}
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM sessions WHERE user_id = $1`, userID); err != nil {
+		return false, err
+	}
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM api_tokens WHERE user_id = $1`, userID); err != nil {
+		return false, err
+	}
+
+	return true, tx.Commit()
+}
+
 func (r *UserRepo) AssignUserResetToken(ctx context.Context, token string, iat int64, userID int64) error {
 	query := `
 		UPDATE users
@@ -122,6 +242,166 @@ func (r *UserRepo) GetUserByResetToken(ctx context.Context, tokenHash string) (*
 	return &user, nil
 }
 
+func (r *UserRepo) AssignUserMagicLinkToken(ctx context.Context, token string, iat int64, userID int64) error {
+	query := `
+		UPDATE users
+		SET magic_link_token = $1,
+		    magic_link_issuedat = $2
+		WHERE id = $3
+	`
+	_, err := r.db.ExecContext(ctx, query, token, iat, userID)
+	return err
+}
+
+func (r *UserRepo) GetUserByMagicLinkToken(ctx context.Context, tokenHash string) (*model.User, error) {
+	var user model.User
+	query := fmt.Sprintf("SELECT %s FROM users WHERE magic_link_token = $1", r.AllRaw)
+	err := r.db.GetContext(ctx, &user, query, tokenHash)
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// ConsumeMagicLinkToken clears the token if it is still the one on record, so
+// concurrent verifications of the same link can't both succeed
+func (r *UserRepo) ConsumeMagicLinkToken(ctx context.Context, userID int64, tokenHash string) (bool, error) {
+	res, err := r.db.ExecContext(ctx, `
+		UPDATE users
+		SET magic_link_token = '',
+		    magic_link_issuedat = 0
+		WHERE id = $1 AND magic_link_token = $2
+	`, userID, tokenHash)
+	if err != nil {
+		return false, err
+	}
+
+	affected, err := res.RowsAffected()
+	return affected == 1, err
+}
+
+func (r *UserRepo) AssignEmailChange(ctx context.Context, userID int64, pendingEmail, tokenHash, cancelHash string, iat int64) error {
+	query := `
+		UPDATE users
+		SET pending_email = $1,
+		    email_change_token = $2,
+		    email_change_cancel_token = $3,
+		    email_change_issuedat = $4
+		WHERE id = $5
+	`
+	_, err := r.db.ExecContext(ctx, query, pendingEmail, tokenHash, cancelHash, iat, userID)
+	return err
+}
+
+func (r *UserRepo) GetUserByEmailChangeToken(ctx context.Context, tokenHash string) (*model.User, error) {
+	var user model.User
+	query := fmt.Sprintf("SELECT %s FROM users WHERE email_change_token = $1", r.AllRaw)
+	err := r.db.GetContext(ctx, &user, query, tokenHash)
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+func (r *UserRepo) GetUserByEmailChangeCancelToken(ctx context.Context, tokenHash string) (*model.User, error) {
+	var user model.User
+	query := fmt.Sprintf("SELECT %s FROM users WHERE email_change_cancel_token = $1", r.AllRaw)
+	err := r.db.GetContext(ctx, &user, query, tokenHash)
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// ConfirmEmailChange swaps in the pending address if the token is still the one on
+// record. reset and sign-in links already mailed to the old address stop working.
+func (r *UserRepo) ConfirmEmailChange(ctx context.Context, userID int64, tokenHash string) (bool, error) {
+	res, err := r.db.ExecContext(ctx, `
+		UPDATE users
+		SET email = pending_email,
+		    email_confirmed = TRUE,
+		    pending_email = '',
+		    email_change_token = '',
+		    email_change_cancel_token = '',
+		    email_change_issuedat = 0,
+		    password_reset_token = '',
+		    password_reset_issuedat = 0,
+		    magic_link_token = '',
+		    magic_link_issuedat = 0
+		WHERE id = $1 AND email_change_token = $2 AND pending_email != ''
+	`, userID, tokenHash)
+	if err != nil {
+		return false, err
+	}
+
+	affected, err := res.RowsAffected()
+	return affected == 1, err
+}
+
+func (r *UserRepo) ClearEmailChange(ctx context.Context, userID int64) error {
+	query := `
+		UPDATE users
+		SET pending_email = '',
+		    email_change_token = '',
+		    email_change_cancel_token = '',
+		    email_change_issuedat = 0
+		WHERE id = $1
+	`
+	_, err := r.db.ExecContext(ctx, query, userID)
+	return err
+}
+
+// ClearExpiredTokens blanks confirmation, reset, sign-in and email change tokens issued
+// before the given times, so stale hashes don't linger in the users table. returns the
+// number of tokens cleared.
+func (r *UserRepo) ClearExpiredTokens(ctx context.Context, confirmBefore, resetBefore, magicLinkBefore, emailChangeBefore int64) (int64, error) {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	updates := []struct {
+		query  string
+		before int64
+	}{
+		{`UPDATE users
+		SET email_confirm_token = '',
+		    email_confirm_issuedat = 0
+		WHERE email_confirm_token != '' AND email_confirm_issuedat < $1`, confirmBefore},
+		{`UPDATE users
+		SET password_reset_token = '',
+		    password_reset_issuedat = 0
+		WHERE password_reset_token != '' AND password_reset_issuedat < $1`, resetBefore},
+		{`UPDATE users
+		SET magic_link_token = '',
+		    magic_link_issuedat = 0
+		WHERE magic_link_token != '' AND magic_link_issuedat < $1`, magicLinkBefore},
+		{`UPDATE users
+		SET pending_email = '',
+		    email_change_token = '',
+		    email_change_cancel_token = '',
+		    email_change_issuedat = 0
+		WHERE email_change_token != '' AND email_change_issuedat < $1`, emailChangeBefore},
+	}
+
+	var cleared int64
+	for _, update := range updates {
+		res, err := tx.ExecContext(ctx, update.query, update.before)
+		if err != nil {
+			return 0, err
+		}
+
+		affected, err := res.RowsAffected()
+		if err != nil {
+			return 0, err
+		}
+		cleared += affected
+	}
+
+	return cleared, tx.Commit()
+}
+
 func (r *UserRepo) ChangeUserPassword(ctx context.Context, newPasswordHash string, userID int64) error {
 	query := `
 		UPDATE users
@@ -134,12 +414,230 @@ func (r *UserRepo) ChangeUserPassword(ctx context.Context, newPasswordHash strin
 	return err
 }
 
+// RehashPassword swaps the stored hash for one of the same password made under the
+// current policy. it does nothing if the password changed in the meantime.
+func (r *UserRepo) RehashPassword(ctx context.Context, userID int64, oldHash, newHash string) error {
+	query := `
+		UPDATE users
+		SET password_hash = $1
+		WHERE id = $2 AND password_hash = $3
+	`
+	_, err := r.db.ExecContext(ctx, query, newHash, userID, oldHash)
+	return err
+}
+
+// ChangeJwtSessionID rotates the user's session id and drops every device session,
+// which invalidates all previously issued session and refresh tokens
 func (r *UserRepo) ChangeJwtSessionID(ctx context.Context, userID int64, newID int64) error {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := r.ChangeJwtSessionIDTx(ctx, tx, userID, newID); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// ChangeJwtSessionIDTx is ChangeJwtSessionID within tx
+func (r *UserRepo) ChangeJwtSessionIDTx(ctx context.Context, tx *sqlx.Tx, userID int64, newID int64) error {
 	query := `
 		UPDATE users
 		SET jwt_session_id = $1
 		WHERE id = $2
 	`
-	_, err := r.db.ExecContext(ctx, query, newID, userID)
+	if _, err := tx.ExecContext(ctx, query, newID, userID); err != nil {
+		return err
+	}
+
+	_, err := tx.ExecContext(ctx, `DELETE FROM sessions WHERE user_id = $1`, userID)
+	return err
+}
+
+func (r *UserRepo) SetTotpSecret(ctx context.Context, userID int64, secret string) error {
+	query := `
+		UPDATE users
+		SET totp_secret = $1,
+		    totp_enabled = FALSE,
+		    totp_last_step = 0
+		WHERE id = $2
+	`
+	_, err := r.db.ExecContext(ctx, query, secret, userID)
+	return err
+}
+
+func (r *UserRepo) EnableTotp(ctx context.Context, userID int64, step int64) error {
+	query := `
+		UPDATE users
+		SET totp_enabled = TRUE,
+		    totp_last_step = $1
+		WHERE id = $2
+	`
+	_, err := r.db.ExecContext(ctx, query, step, userID)
+	return err
+}
+
+func (r *UserRepo) DisableTotp(ctx context.Context, userID int64) error {
+	query := `
+		UPDATE users
+		SET totp_secret = '',
+		    totp_enabled = FALSE,
+		    totp_last_step = 0
+		WHERE id = $1
+	`
+	_, err := r.db.ExecContext(ctx, query, userID)
 	return err
 }
+
+// UpdateTotpLastStep only moves the step forward, so a code can't be used twice
+func (r *UserRepo) UpdateTotpLastStep(ctx context.Context, userID int64, step int64) (bool, error) {
+	res, err := r.db.ExecContext(ctx, `
+		UPDATE users
+		SET totp_last_step = $1
+		WHERE id = $2 AND totp_last_step < $1
+	`, step, userID)
+	if err != nil {
+		return false, err
+	}
+
+	affected, err := res.RowsAffected()
+	return affected == 1, err
+}
+
+// ScheduleDeletion marks the account for purging at the given time, replacing any
+// node transfers chosen by an earlier request
+func (r *UserRepo) ScheduleDeletion(ctx context.Context, userID int64, at int64, transfers []model.NodeTransfer) error {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err = tx.ExecContext(ctx, `UPDATE users SET deletion_scheduled_at = $1 WHERE id = $2`, at, userID); err != nil {
+		return err
+	}
+
+	if _, err = tx.ExecContext(ctx, `DELETE FROM node_transfers WHERE user_id = $1`, userID); err != nil {
+		return err
+	}
+
+	query := fmt.Sprintf(
+		"INSERT INTO node_transfers (%s) VALUES (%s)",
+		r.transferColumns.AllRaw,
+		r.transferColumns.AllPrefixed,
+	)
+	for _, transfer := range transfers {
+		if _, err = tx.NamedExecContext(ctx, query, transfer); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+func (r *UserRepo) CancelDeletion(ctx context.Context, userID int64) error {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err = tx.ExecContext(ctx, `UPDATE users SET deletion_scheduled_at = 0 WHERE id = $1`, userID); err != nil {
+		return err
+	}
+
+	if _, err = tx.ExecContext(ctx, `DELETE FROM node_transfers WHERE user_id = $1`, userID); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (r *UserRepo) GetNodeTransfers(ctx context.Context, userID int64) ([]model.NodeTransfer, error) {
+	var transfers []model.NodeTransfer
+	query := fmt.Sprintf("SELECT %s FROM node_transfers WHERE user_id = $1", r.transferColumns.AllRaw)
+	err := r.db.SelectContext(ctx, &transfers, query, userID)
+	return transfers, err
+}
+
+func (r *UserRepo) GetUsersDueForDeletion(ctx context.Context, now int64) ([]int64, error) {
+	var ids []int64
+	err := r.db.SelectContext(ctx, &ids, `
+		SELECT id FROM users
+		WHERE deletion_scheduled_at != 0 AND deletion_scheduled_at <= $1
+	`, now)
+	return ids, err
+}
+
+// PurgeUser permanently deletes an account. owned nodes go to the collaborator picked
+// in node_transfers if they still have access, the rest are deleted with their links,
+// color stops, invitations, revisions and publication. rows are removed explicitly
+// since sqlite doesn't enforce the foreign key cascades.
+func (r *UserRepo) PurgeUser(ctx context.Context, userID int64, now int64) error {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// transfers, only to collaborators that still exist and still have access
+	if _, err = tx.ExecContext(ctx, `
+		UPDATE nodes
+		SET owner_id = (SELECT t.new_owner_id FROM node_transfers t WHERE t.node_id = nodes.id),
+		    updated_at = $2
+		WHERE owner_id = $1 AND id IN (
+			SELECT t.node_id FROM node_transfers t
+			INNER JOIN node_access a ON a.node_id = t.node_id AND a.user_id = t.new_owner_id
+			INNER JOIN users u ON u.id = t.new_owner_id
+			WHERE t.user_id = $1
+		)
+	`, userID, now); err != nil {
+		return err
+	}
+
+	statements := []string{
+		// new owners no longer need a collaborator row
+		`DELETE FROM node_access
+		WHERE node_id IN (SELECT node_id FROM node_transfers WHERE user_id = $1)
+		  AND user_id = (SELECT owner_id FROM nodes WHERE nodes.id = node_access.node_id)`,
+
+		// nodes that weren't transferred
+		`DELETE FROM color_stops WHERE link_id IN (
+			SELECT l.id FROM links l INNER JOIN nodes n ON n.id = l.node_id WHERE n.owner_id = $1
+		)`,
+		`DELETE FROM links WHERE node_id IN (SELECT id FROM nodes WHERE owner_id = $1)`,
+		`DELETE FROM invitations WHERE node_id IN (SELECT id FROM nodes WHERE owner_id = $1)`,
+		`DELETE FROM node_access WHERE node_id IN (SELECT id FROM nodes WHERE owner_id = $1)`,
+		`DELETE FROM node_revisions WHERE node_id IN (SELECT id FROM nodes WHERE owner_id = $1)`,
+		`DELETE FROM node_publications WHERE node_id IN (SELECT id FROM nodes WHERE owner_id = $1)`,
+		`DELETE FROM subdomain_history WHERE node_id IN (SELECT id FROM nodes WHERE owner_id = $1)`,
+		`DELETE FROM nodes WHERE owner_id = $1`,
+
+		// collaboration on other people's nodes
+		`DELETE FROM node_access WHERE user_id = $1`,
+		`DELETE FROM invitations WHERE user_id = $1`,
+		`DELETE FROM node_transfers WHERE user_id = $1 OR new_owner_id = $1`,
+
+		// credentials and login history
+		`DELETE FROM sessions WHERE user_id = $1`,
+		`DELETE FROM api_tokens WHERE user_id = $1`,
+		`DELETE FROM user_identities WHERE user_id = $1`,
+		`DELETE FROM oidc_states WHERE link_user_id = $1`,
+		`DELETE FROM mfa_recovery_codes WHERE user_id = $1`,
+		`DELETE FROM failed_logins WHERE user_id = $1`,
+		`DELETE FROM lockouts WHERE user_id = $1`,
+
+		`DELETE FROM users WHERE id = $1`,
+	}
+
+	for _, statement := range statements {
+		if _, err = tx.ExecContext(ctx, statement, userID); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
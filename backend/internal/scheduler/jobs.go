@@ -0,0 +1,189 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/akramboussanni/treenode/config"
+	"github.com/akramboussanni/treenode/internal/applog"
+	"github.com/akramboussanni/treenode/internal/dnsverify"
+	"github.com/akramboussanni/treenode/internal/model"
+	"github.com/akramboussanni/treenode/internal/repo"
+)
+
+// AddMaintenanceJobs registers the jobs that clear out expired security data, recheck
+// custom domains, publish scheduled node drafts and purge accounts whose deletion grace
+// period has ended
+func (s *Scheduler) AddMaintenanceJobs(repos *repo.Repos) {
+	s.Add(Job{
+		Name:     "cleanup-jwt-blacklist",
+		Interval: time.Hour,
+		Run: func(ctx context.Context) (int64, error) {
+			return repos.Token.CleanupTokens(ctx, time.Now().UTC().Unix())
+		},
+	})
+
+	// lockouts still count towards the backoff after they end, keep them for the window
+	s.Add(Job{
+		Name:     "cleanup-lockouts",
+		Interval: time.Hour,
+		Run: func(ctx context.Context) (int64, error) {
+			now := time.Now().UTC().Unix()
+			keep := max(config.App.SecurityLogRetention, config.App.LockoutBackoffWindow)
+			return repos.Lockout.DeleteStaleLockouts(ctx, now, now-keep)
+		},
+	})
+
+	s.Add(Job{
+		Name:     "cleanup-failed-logins",
+		Interval: time.Hour,
+		Run: func(ctx context.Context) (int64, error) {
+			keep := max(config.App.SecurityLogRetention, config.App.FailedLoginBacktrack)
+			return repos.Lockout.DeleteFailedLoginsBefore(ctx, time.Now().UTC().Unix()-keep)
+		},
+	})
+
+	// a session can only be resumed with a refresh token, which outlives the last use by
+	// at most its own lifetime
+	s.Add(Job{
+		Name:     "cleanup-sessions",
+		Interval: time.Hour,
+		Run: func(ctx context.Context) (int64, error) {
+			lifetime := config.App.JwtExpirations[string(model.RefreshJwt)]
+			return repos.Session.DeleteStaleSessions(ctx, time.Now().UTC().Unix()-lifetime)
+		},
+	})
+
+	s.Add(Job{
+		Name:     "cleanup-invitations",
+		Interval: 6 * time.Hour,
+		Run:      repos.Invitation.DeleteExpiredInvitations,
+	})
+
+	s.Add(Job{
+		Name:     "cleanup-user-tokens",
+		Interval: time.Hour,
+		Run: func(ctx context.Context) (int64, error) {
+			now := time.Now().UTC().Unix()
+			return repos.User.ClearExpiredTokens(ctx,
+				now-config.App.EmailConfirmExpiry,
+				now-config.App.ForgotPasswordExpiry,
+				now-config.App.MagicLinkExpiry,
+				now-config.App.EmailConfirmExpiry,
+			)
+		},
+	})
+
+	s.Add(Job{
+		Name:     "cleanup-oidc-states",
+		Interval: time.Hour,
+		Run: func(ctx context.Context) (int64, error) {
+			return repos.Identity.DeleteExpiredStates(ctx, time.Now().UTC().Unix())
+		},
+	})
+
+	s.Add(Job{
+		Name:     "recheck-domains",
+		Interval: time.Hour,
+		Run: func(ctx context.Context) (int64, error) {
+			return recheckDomains(ctx, repos.Node)
+		},
+	})
+
+	s.Add(Job{
+		Name:     "publish-scheduled-nodes",
+		Interval: time.Minute,
+		// owners were told their draft goes live at publish_at
+		Always: true,
+		Run: func(ctx context.Context) (int64, error) {
+			return repos.Publication.PublishDue(ctx, time.Now().UTC().Unix(), scheduledPublishBatch)
+		},
+	})
+
+	s.Add(Job{
+		Name:     "purge-deleted-accounts",
+		Interval: time.Hour,
+		// users were promised their data is gone after the grace period
+		Always: true,
+		Run: func(ctx context.Context) (int64, error) {
+			return purgeDeletedAccounts(ctx, repos.User)
+		},
+	})
+}
+
+// nodes rechecked per run, the rest wait for the next one
+const domainRecheckBatch = 200
+
+// scheduled publications applied per run, the rest wait for the next one
+const scheduledPublishBatch = 500
+
+// recheckDomains looks up the records of verified custom domains that haven't been
+// checked for DOMAIN_RECHECK_INTERVAL. a domain is unverified once its records are
+// missing DOMAIN_RECHECK_FAILURES times in a row. lookups that fail outright don't change
+// the count, so a resolver outage can't unverify every domain.
+func recheckDomains(ctx context.Context, nr *repo.NodeRepo) (int64, error) {
+	now := time.Now().UTC().Unix()
+
+	nodes, err := nr.GetNodesDueForDomainCheck(ctx, now-config.App.DomainRecheckInterval, domainRecheckBatch)
+	if err != nil {
+		return 0, err
+	}
+
+	var unverified int64
+	for _, node := range nodes {
+		target := dnsverify.CnameTarget(node.SubdomainName, config.App.PlatformHost)
+		_, err := dnsverify.Verify(ctx, node.Domain, node.DomainToken, target)
+
+		failures, verified := 0, true
+		switch {
+		case errors.Is(err, dnsverify.ErrNoRecord):
+			failures = node.DomainCheckFailures + 1
+			verified = failures < config.App.DomainRecheckFailures
+		case err != nil:
+			// still marked as checked so failing lookups can't take every batch, the
+			// failure count is left as it was
+			applog.Warn("Domain recheck lookup failed", "nodeID:", node.ID, "domain:", node.Domain, "err:", err)
+			failures = node.DomainCheckFailures
+		}
+
+		if err := nr.RecordDomainCheck(ctx, node.ID, node.Domain, now, failures, verified); err != nil {
+			return unverified, err
+		}
+
+		if !verified {
+			applog.Warn("Custom domain unverified, records missing", "nodeID:", node.ID, "domain:", node.Domain)
+			unverified++
+		}
+	}
+
+	return unverified, nil
+}
+
+// purgeDeletedAccounts deletes accounts whose deletion grace period has ended. one
+// failing account doesn't hold up the rest, the first error is returned at the end.
+func purgeDeletedAccounts(ctx context.Context, ur *repo.UserRepo) (int64, error) {
+	now := time.Now().UTC().Unix()
+
+	ids, err := ur.GetUsersDueForDeletion(ctx, now)
+	if err != nil {
+		return 0, err
+	}
+
+	var purged int64
+	var firstErr error
+	for _, id := range ids {
+		if err := ur.PurgeUser(ctx, id, now); err != nil {
+			applog.Error("Failed to purge account", "userID:", id, "err:", err)
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+
+		applog.Info("Purged deleted account", "userID:", id)
+		purged++
+	}
+
+	return purged, firstErr
+}
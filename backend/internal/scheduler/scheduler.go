@@ -0,0 +1,188 @@
+package scheduler
+
+import (
+	"context"
+	"math/rand/v2"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/akramboussanni/treenode/config"
+	"github.com/akramboussanni/treenode/internal/applog"
+	"github.com/akramboussanni/treenode/internal/repo"
+	"github.com/akramboussanni/treenode/internal/utils"
+)
+
+// Job is a named task run every Interval, give or take the jitter
+type Job struct {
+	Name     string
+	Interval time.Duration
+	// Always keeps the job running on instances with SCHEDULER_ENABLED=false, for work
+	// nothing else would ever do
+	Always bool
+	// Run does the work and returns how many rows it touched
+	Run func(ctx context.Context) (int64, error)
+}
+
+// @Description Counters for a background job on this instance
+type JobStats struct {
+	Name         string `json:"name" example:"cleanup-failed-logins"`
+	Interval     int64  `json:"interval" example:"3600"`        // sec
+	Always       bool   `json:"always" example:"false"`         // runs even with SCHEDULER_ENABLED=false
+	Runs         int64  `json:"runs" example:"12"`              // runs on this instance
+	Failures     int64  `json:"failures" example:"0"`           // runs that returned an error
+	Skipped      int64  `json:"skipped" example:"3"`            // turns taken by another instance
+	LastRunAt    int64  `json:"last_run_at,string" example:"0"` // start of the last run here
+	LastDuration int64  `json:"last_duration" example:"4"`      // ms
+	LastRows     int64  `json:"last_rows" example:"0"`
+	LastError    string `json:"last_error" example:""`
+}
+
+type job struct {
+	Job
+	stats JobStats
+}
+
+// Scheduler runs jobs in the background. every run first takes a lease in job_locks, so
+// with several instances on one database each job still runs once per interval.
+type Scheduler struct {
+	locks  *repo.JobLockRepo
+	holder string
+
+	mu   sync.Mutex
+	jobs []*job
+	wg   sync.WaitGroup
+}
+
+func New(locks *repo.JobLockRepo) *Scheduler {
+	host, _ := os.Hostname()
+	return &Scheduler{
+		locks:  locks,
+		holder: host + "-" + strconv.FormatInt(utils.GenerateSnowflakeID(), 10),
+	}
+}
+
+// Add registers a job, it must be called before Start
+func (s *Scheduler) Add(j Job) {
+	s.jobs = append(s.jobs, &job{
+		Job:   j,
+		stats: JobStats{Name: j.Name, Interval: int64(j.Interval / time.Second), Always: j.Always},
+	})
+}
+
+// Start runs every job on its own goroutine until ctx is cancelled, only the Always ones
+// when SCHEDULER_ENABLED is off. the first run of each job is spread over its first
+// interval so instances started together don't race.
+func (s *Scheduler) Start(ctx context.Context) {
+	for _, j := range s.jobs {
+		if !config.App.SchedulerEnabled && !j.Always {
+			continue
+		}
+
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			s.loop(ctx, j)
+		}()
+	}
+}
+
+// Wait blocks until every job has stopped after ctx was cancelled
+func (s *Scheduler) Wait() {
+	s.wg.Wait()
+}
+
+func (s *Scheduler) Stats() []JobStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stats := make([]JobStats, 0, len(s.jobs))
+	for _, j := range s.jobs {
+		stats = append(stats, j.stats)
+	}
+	return stats
+}
+
+func (s *Scheduler) loop(ctx context.Context, j *job) {
+	delay := time.Duration(rand.Int64N(int64(j.Interval/10) + 1))
+	for {
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+
+		s.run(ctx, j)
+		delay = jitter(j.Interval)
+	}
+}
+
+// jitter returns the interval moved by up to a tenth either way
+func jitter(interval time.Duration) time.Duration {
+	spread := int64(interval / 10)
+	if spread <= 0 {
+		return interval
+	}
+	return interval + time.Duration(rand.Int64N(2*spread+1)-spread)
+}
+
+// lease is how long a run may take before another instance can take the job over
+func lease(interval time.Duration) time.Duration {
+	return max(interval/2, time.Minute)
+}
+
+func (s *Scheduler) run(ctx context.Context, j *job) {
+	start := time.Now().UTC()
+	now := start.Unix()
+	leaseFor := lease(j.Interval)
+
+	// anything that finished within the last half interval ran this turn, jitter included
+	acquired, err := s.locks.AcquireJobLock(ctx, j.Name, s.holder, now, now+int64(leaseFor/time.Second), now-int64(j.Interval/2/time.Second))
+	if err != nil {
+		applog.Error("Failed to acquire job lock", "job:", j.Name, "err:", err)
+		s.record(j, func(st *JobStats) { st.Failures++; st.LastError = err.Error() })
+		return
+	}
+
+	if !acquired {
+		s.record(j, func(st *JobStats) { st.Skipped++ })
+		return
+	}
+
+	runCtx, cancel := context.WithTimeout(ctx, leaseFor)
+	rows, err := j.Run(runCtx)
+	cancel()
+	duration := time.Since(start)
+
+	if releaseErr := s.locks.ReleaseJobLock(context.Background(), j.Name, s.holder, time.Now().UTC().Unix()); releaseErr != nil {
+		applog.Error("Failed to release job lock", "job:", j.Name, "err:", releaseErr)
+	}
+
+	s.record(j, func(st *JobStats) {
+		st.Runs++
+		st.LastRunAt = now
+		st.LastDuration = duration.Milliseconds()
+		st.LastRows = rows
+		st.LastError = ""
+		if err != nil {
+			st.Failures++
+			st.LastError = err.Error()
+		}
+	})
+
+	if err != nil {
+		applog.Error("Job failed", "job:", j.Name, "duration:", duration, "err:", err)
+		return
+	}
+
+	applog.Info("Job finished", "job:", j.Name, "rows:", rows, "duration:", duration)
+}
+
+func (s *Scheduler) record(j *job, update func(*JobStats)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	update(&j.stats)
+}
@@ -9,8 +9,28 @@ import (
 type contextKey string
 
 const UserKey contextKey = "user"
+const SessionKey contextKey = "session"
+const ApiTokenKey contextKey = "apitoken"
+const NodeKey contextKey = "node"
 
 func UserFromContext(ctx context.Context) (*model.User, bool) {
 	user, ok := ctx.Value(UserKey).(*model.User)
 	return user, ok
 }
+
+func SessionIDFromContext(ctx context.Context) (int64, bool) {
+	id, ok := ctx.Value(SessionKey).(int64)
+	return id, ok
+}
+
+// ApiTokenFromContext returns the personal access token the request was authenticated with, if any
+func ApiTokenFromContext(ctx context.Context) (*model.ApiToken, bool) {
+	token, ok := ctx.Value(ApiTokenKey).(*model.ApiToken)
+	return token, ok
+}
+
+// NodeFromContext returns the node resolved from the Host header, on node hosts
+func NodeFromContext(ctx context.Context) (*model.Node, bool) {
+	node, ok := ctx.Value(NodeKey).(*model.Node)
+	return node, ok
+}
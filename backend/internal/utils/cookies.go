@@ -40,3 +40,17 @@ func ClearAllCookies(w http.ResponseWriter) {
 	ClearSessionCookie(w)
 	ClearRefreshCookie(w)
 }
+
+// the oidc state cookie has to survive the cross-site redirect back from the provider,
+// which strict cookies don't
+func SetOidcStateCookie(w http.ResponseWriter, state string) {
+	cookie := cookieOp("oidc_state", state, "/auth/oidc", int(config.App.OidcStateDuration))
+	cookie.SameSite = http.SameSiteLaxMode
+	http.SetCookie(w, cookie)
+}
+
+func ClearOidcStateCookie(w http.ResponseWriter) {
+	cookie := cookieOp("oidc_state", "", "/auth/oidc", -1)
+	cookie.SameSite = http.SameSiteLaxMode
+	http.SetCookie(w, cookie)
+}
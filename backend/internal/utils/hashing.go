@@ -6,10 +6,10 @@ import (
 	"crypto/sha256"
 	"encoding/base64"
 	"encoding/hex"
+	"strings"
 
 	"github.com/akramboussanni/treenode/config"
 	"github.com/akramboussanni/treenode/internal/model"
-	"golang.org/x/crypto/bcrypt"
 )
 
 func HashJwt(message string) string {
@@ -18,16 +18,6 @@ func HashJwt(message string) string {
 	return hex.EncodeToString(h.Sum(nil))
 }
 
-func HashPassword(password string) (string, error) {
-	bytes, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
-	return string(bytes), err
-}
-
-func ComparePassword(hashed, plain string) bool {
-	err := bcrypt.CompareHashAndPassword([]byte(hashed), []byte(plain))
-	return err == nil
-}
-
 func GenerateRandomBytes(bytes uint8) ([]byte, error) {
 	b := make([]byte, bytes)
 
@@ -53,3 +43,21 @@ func GetRandomToken(bytes uint8) (*model.Token, error) {
 		Hash: base64.URLEncoding.EncodeToString(hashed[:]),
 	}, err
 }
+
+// personal access tokens carry a prefix so they're easy to spot in logs and secret scanners
+const ApiTokenPrefix = "tn_"
+
+// HashApiToken hashes a presented personal access token the same way GetRandomToken hashes new ones
+func HashApiToken(raw string) (string, bool) {
+	if !strings.HasPrefix(raw, ApiTokenPrefix) {
+		return "", false
+	}
+
+	b, err := base64.URLEncoding.DecodeString(strings.TrimPrefix(raw, ApiTokenPrefix))
+	if err != nil {
+		return "", false
+	}
+
+	hashed := sha256.Sum256(b)
+	return base64.URLEncoding.EncodeToString(hashed[:]), true
+}
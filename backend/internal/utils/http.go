@@ -27,3 +27,84 @@ func GetClientIP(r *http.Request) string {
 	}
 	return ip
 }
+
+// RequestHost returns the lowercased host the request was made to, without the port.
+// X-Forwarded-Host is only used when proxy headers are trusted.
+func RequestHost(r *http.Request) string {
+	host := r.Host
+	if config.App.TrustIpHeaders {
+		if forwarded := r.Header.Get("X-Forwarded-Host"); forwarded != "" {
+			host = strings.TrimSpace(strings.Split(forwarded, ",")[0])
+		}
+	}
+
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	} else if strings.HasPrefix(host, "[") && strings.HasSuffix(host, "]") {
+		// an IPv6 literal without a port keeps its brackets
+		host = host[1 : len(host)-1]
+	}
+
+	return strings.ToLower(strings.TrimSuffix(host, "."))
+}
+
+// IPPrefix groups addresses the way lockouts count them. IPv4 addresses stand alone,
+// IPv6 addresses collapse to their /64 since one host usually gets the whole block.
+func IPPrefix(ip string) string {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return ip
+	}
+
+	if v4 := parsed.To4(); v4 != nil {
+		return v4.String()
+	}
+
+	return parsed.Mask(net.CIDRMask(64, 128)).String() + "/64"
+}
+
+// DescribeUserAgent turns a user agent into a short label such as "Firefox on Windows"
+func DescribeUserAgent(ua string) string {
+	browsers := []struct{ token, name string }{
+		{"Edg/", "Edge"},
+		{"OPR/", "Opera"},
+		{"Firefox/", "Firefox"},
+		{"Chrome/", "Chrome"},
+		{"Safari/", "Safari"},
+		{"curl/", "curl"},
+	}
+	systems := []struct{ token, name string }{
+		{"Windows", "Windows"},
+		{"Android", "Android"},
+		{"iPhone", "iOS"},
+		{"iPad", "iPadOS"},
+		{"Mac OS X", "macOS"},
+		{"CrOS", "ChromeOS"},
+		{"Linux", "Linux"},
+	}
+
+	browser, system := "", ""
+	for _, b := range browsers {
+		if strings.Contains(ua, b.token) {
+			browser = b.name
+			break
+		}
+	}
+	for _, s := range systems {
+		if strings.Contains(ua, s.token) {
+			system = s.name
+			break
+		}
+	}
+
+	switch {
+	case browser != "" && system != "":
+		return browser + " on " + system
+	case browser != "":
+		return browser
+	case system != "":
+		return system
+	default:
+		return "Unknown device"
+	}
+}
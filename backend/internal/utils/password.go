@@ -0,0 +1,173 @@
+package utils
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/akramboussanni/treenode/config"
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+var errMalformedHash = errors.New("malformed password hash")
+
+// PasswordHasher produces and checks PHC-style strings, which carry the algorithm and
+// its parameters so older hashes keep verifying after the policy changes
+type PasswordHasher interface {
+	Hash(password string) (string, error)
+	Verify(hash, password string) bool
+	// Identifies reports whether the hash was made by this algorithm
+	Identifies(hash string) bool
+	// Outdated reports whether a hash this hasher understands was made with other parameters
+	Outdated(hash string) bool
+}
+
+const (
+	argon2idPrefix = "$argon2id$"
+	argon2SaltLen  = 16
+	argon2KeyLen   = 32
+)
+
+type Argon2idHasher struct {
+	Memory      uint32 // KiB
+	Time        uint32
+	Parallelism uint8
+}
+
+type argon2idParams struct {
+	memory      uint32
+	time        uint32
+	parallelism uint8
+	salt        []byte
+	key         []byte
+}
+
+func (a Argon2idHasher) Hash(password string) (string, error) {
+	salt := make([]byte, argon2SaltLen)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+
+	key := argon2.IDKey([]byte(password), salt, a.Time, a.Memory, a.Parallelism, argon2KeyLen)
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2.Version, a.Memory, a.Time, a.Parallelism,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key),
+	), nil
+}
+
+func (a Argon2idHasher) Verify(hash, password string) bool {
+	p, err := parseArgon2id(hash)
+	if err != nil {
+		return false
+	}
+
+	key := argon2.IDKey([]byte(password), p.salt, p.time, p.memory, p.parallelism, uint32(len(p.key)))
+	return subtle.ConstantTimeCompare(key, p.key) == 1
+}
+
+func (a Argon2idHasher) Identifies(hash string) bool {
+	return strings.HasPrefix(hash, argon2idPrefix)
+}
+
+func (a Argon2idHasher) Outdated(hash string) bool {
+	p, err := parseArgon2id(hash)
+	if err != nil {
+		return true
+	}
+	return p.memory != a.Memory || p.time != a.Time || p.parallelism != a.Parallelism || len(p.key) != argon2KeyLen
+}
+
+// parseArgon2id reads $argon2id$v=19$m=...,t=...,p=...$salt$key
+func parseArgon2id(hash string) (*argon2idParams, error) {
+	parts := strings.Split(hash, "$")
+	if len(parts) != 6 || parts[1] != "argon2id" {
+		return nil, errMalformedHash
+	}
+
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return nil, errMalformedHash
+	}
+
+	p := &argon2idParams{}
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &p.memory, &p.time, &p.parallelism); err != nil {
+		return nil, errMalformedHash
+	}
+	if p.memory == 0 || p.time == 0 || p.parallelism == 0 {
+		return nil, errMalformedHash
+	}
+
+	var err error
+	if p.salt, err = base64.RawStdEncoding.DecodeString(parts[4]); err != nil {
+		return nil, errMalformedHash
+	}
+	if p.key, err = base64.RawStdEncoding.DecodeString(parts[5]); err != nil || len(p.key) == 0 {
+		return nil, errMalformedHash
+	}
+	return p, nil
+}
+
+// BcryptHasher covers hashes from before argon2id. bcrypt ignores everything past the
+// 72nd byte of a password, and refuses to hash longer ones.
+type BcryptHasher struct {
+	Cost int
+}
+
+func (b BcryptHasher) Hash(password string) (string, error) {
+	bytes, err := bcrypt.GenerateFromPassword([]byte(password), b.Cost)
+	return string(bytes), err
+}
+
+func (b BcryptHasher) Verify(hash, password string) bool {
+	return bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) == nil
+}
+
+func (b BcryptHasher) Identifies(hash string) bool {
+	return strings.HasPrefix(hash, "$2a$") || strings.HasPrefix(hash, "$2b$") || strings.HasPrefix(hash, "$2y$")
+}
+
+func (b BcryptHasher) Outdated(hash string) bool {
+	cost, err := bcrypt.Cost([]byte(hash))
+	return err != nil || cost != b.Cost
+}
+
+// every algorithm stored hashes may use. parameters are read from the hash when verifying.
+var passwordHashers = []PasswordHasher{Argon2idHasher{}, BcryptHasher{}}
+
+// CurrentPasswordHasher is the hasher picked by PASSWORD_HASH and its parameters
+func CurrentPasswordHasher() PasswordHasher {
+	if config.App.PasswordHash == config.PasswordHashBcrypt {
+		return BcryptHasher{Cost: config.App.BcryptCost}
+	}
+	return Argon2idHasher{
+		Memory:      uint32(config.App.Argon2Memory),
+		Time:        uint32(config.App.Argon2Time),
+		Parallelism: uint8(config.App.Argon2Parallelism),
+	}
+}
+
+func HashPassword(password string) (string, error) {
+	return CurrentPasswordHasher().Hash(password)
+}
+
+// ComparePassword checks the password with whichever algorithm made the hash
+func ComparePassword(hashed, plain string) bool {
+	for _, hasher := range passwordHashers {
+		if hasher.Identifies(hashed) {
+			return hasher.Verify(hashed, plain)
+		}
+	}
+	return false
+}
+
+// PasswordNeedsRehash reports whether a hash was made with another algorithm or other
+// parameters than the current policy
+func PasswordNeedsRehash(hashed string) bool {
+	current := CurrentPasswordHasher()
+	return !current.Identifies(hashed) || current.Outdated(hashed)
+}
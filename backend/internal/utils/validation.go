@@ -1,19 +1,25 @@
 package utils
 
 import (
+	neturl "net/url"
 	"regexp"
 	"strings"
 	"unicode"
+
+	"github.com/akramboussanni/treenode/config"
 )
 
 var (
-	lowerRegex    = regexp.MustCompile(`[a-z]`)
-	upperRegex    = regexp.MustCompile(`[A-Z]`)
-	digitRegex    = regexp.MustCompile(`\d`)
-	emailRegex    = regexp.MustCompile(`^[a-zA-Z0-9._%+\-]+@[a-zA-Z0-9.\-]+\.[a-zA-Z]{2,}$`)
-	usernameRegex = regexp.MustCompile(`^[a-zA-Z0-9_-]{3,30}$`)
-	urlRegex      = regexp.MustCompile(`^https?://[^\s/$.?#].[^\s]*$`)
-	domainRegex   = regexp.MustCompile(`^[a-zA-Z0-9]([a-zA-Z0-9\-]{0,61}[a-zA-Z0-9])?(\.[a-zA-Z0-9]([a-zA-Z0-9\-]{0,61}[a-zA-Z0-9])?)*$`)
+	lowerRegex      = regexp.MustCompile(`[a-z]`)
+	upperRegex      = regexp.MustCompile(`[A-Z]`)
+	digitRegex      = regexp.MustCompile(`\d`)
+	emailRegex      = regexp.MustCompile(`^[a-zA-Z0-9._%+\-]+@[a-zA-Z0-9.\-]+\.[a-zA-Z]{2,}$`)
+	usernameRegex   = regexp.MustCompile(`^[a-zA-Z0-9_-]{3,30}$`)
+	urlRegex        = regexp.MustCompile(`^https?://[^\s/$.?#].[^\s]*$`)
+	domainRegex     = regexp.MustCompile(`^[a-zA-Z0-9]([a-zA-Z0-9\-]{0,61}[a-zA-Z0-9])?(\.[a-zA-Z0-9]([a-zA-Z0-9\-]{0,61}[a-zA-Z0-9])?)*$`)
+	hexColorRegex   = regexp.MustCompile(`^#(?:[0-9a-fA-F]{3,4}|[0-9a-fA-F]{6}|[0-9a-fA-F]{8})$`)
+	funcColorRegex  = regexp.MustCompile(`^(?:rgba?|hsla?)\([0-9.,%/ ]+\)$`)
+	namedColorRegex = regexp.MustCompile(`^[a-zA-Z]{3,30}$`)
 )
 
 func IsValidPassword(pw string) bool {
@@ -25,6 +31,14 @@ func IsValidPassword(pw string) bool {
 		digitRegex.MatchString(pw)
 }
 
+// MeetsPasswordPolicy applies the rules picked by PASSWORD_POLICY
+func MeetsPasswordPolicy(pw string) bool {
+	if config.App.PasswordPolicy == config.PasswordPolicyStrong {
+		return IsStrongPassword(pw)
+	}
+	return IsValidPassword(pw)
+}
+
 func IsValidEmail(email string) bool {
 	return emailRegex.MatchString(email)
 }
@@ -37,10 +51,39 @@ func IsValidURL(url string) bool {
 	return urlRegex.MatchString(url)
 }
 
+// linkSchemes are the schemes a link on a page may use
+var linkSchemes = []string{"http", "https", "mailto", "tel"}
+
+// IsValidLinkURL accepts http(s), mailto and tel urls without XSS patterns, the policy for
+// links brought in by the importers
+func IsValidLinkURL(link string) bool {
+	u, err := neturl.Parse(link)
+	if err != nil || ContainsXSS(link) {
+		return false
+	}
+
+	scheme := strings.ToLower(u.Scheme)
+	if scheme == "http" || scheme == "https" {
+		return u.Host != "" && IsValidURL(link)
+	}
+	for _, s := range linkSchemes {
+		if scheme == s {
+			return u.Opaque != ""
+		}
+	}
+	return false
+}
+
 func IsValidDomain(domain string) bool {
 	return domainRegex.MatchString(domain)
 }
 
+// IsValidColor accepts plain hex, rgb(a), hsl(a) and named css colors, nothing that
+// could escape a css value
+func IsValidColor(color string) bool {
+	return hexColorRegex.MatchString(color) || funcColorRegex.MatchString(color) || namedColorRegex.MatchString(color)
+}
+
 // SanitizeString removes potentially dangerous characters
 func SanitizeString(input string) string {
 	// Remove null bytes and other control characters